@@ -0,0 +1,102 @@
+package alias
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvTagAliases and EnvThemeAliases fold variant spellings into a canonical
+// name, as comma-separated alias=canonical pairs, e.g.
+// "dp=dynamic programming,bfs=breadth-first search". Both sides are compared
+// after CanonicalKey.
+const (
+	EnvTagAliases   = "TAG_ALIASES"
+	EnvThemeAliases = "THEME_ALIASES"
+)
+
+// CanonicalKey lowercases a tag or theme and collapses its whitespace, so
+// "Dynamic  Programming " and "dynamic programming" are the same key
+func CanonicalKey(key string) string {
+	return strings.ToLower(strings.Join(strings.Fields(key), " "))
+}
+
+// Parse reads the value of EnvTagAliases or EnvThemeAliases. An empty
+// value means no aliases.
+func Parse(value string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		alias, canonical, ok := strings.Cut(pair, "=")
+		alias, canonical = CanonicalKey(alias), CanonicalKey(canonical)
+		if !ok || alias == "" || canonical == "" {
+			return nil, fmt.Errorf("alias %q is not of the form alias=canonical", pair)
+		}
+		aliases[alias] = canonical
+	}
+	return aliases, nil
+}
+
+// Canonicalizer maps raw tags or themes to their canonical name and
+// remembers which raw spellings it folded, so the source data can be cleaned
+// up later. It is meant for a single statistics computation.
+type Canonicalizer struct {
+	aliases map[string]string
+	seen    map[string]map[string]bool
+}
+
+func NewCanonicalizer(aliases map[string]string) *Canonicalizer {
+	return &Canonicalizer{aliases: aliases, seen: make(map[string]map[string]bool)}
+}
+
+// Canonical returns the canonical name of key, or "" for a blank key
+func (c *Canonicalizer) Canonical(key string) string {
+	canonical := CanonicalKey(key)
+	if alias, ok := c.aliases[canonical]; ok {
+		canonical = alias
+	}
+	if canonical == "" {
+		return ""
+	}
+	if _, ok := c.seen[canonical]; !ok {
+		c.seen[canonical] = make(map[string]bool)
+	}
+	c.seen[canonical][key] = true
+	return canonical
+}
+
+// CanonicalAll maps keys to their canonical names, dropping blank ones and
+// the duplicates folding creates
+func (c *Canonicalizer) CanonicalAll(keys []string) []string {
+	unique := make(map[string]bool, len(keys))
+	canonical := []string{}
+	for _, key := range keys {
+		name := c.Canonical(key)
+		if name == "" || unique[name] {
+			continue
+		}
+		unique[name] = true
+		canonical = append(canonical, name)
+	}
+	return canonical
+}
+
+// MergedKeys lists, per canonical name, the sorted raw spellings seen for it.
+// Names only ever seen spelled exactly as their canonical form are left out.
+func (c *Canonicalizer) MergedKeys() map[string][]string {
+	merged := make(map[string][]string)
+	for canonical, raws := range c.seen {
+		if len(raws) == 1 && raws[canonical] {
+			continue
+		}
+		spellings := make([]string, 0, len(raws))
+		for raw := range raws {
+			spellings = append(spellings, raw)
+		}
+		sort.Strings(spellings)
+		merged[canonical] = spellings
+	}
+	return merged
+}
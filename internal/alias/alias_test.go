@@ -0,0 +1,38 @@
+package alias
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"dp=Dynamic  Programming, BFS = breadth-first search,", map[string]string{"dp": "dynamic programming", "bfs": "breadth-first search"}, false},
+		{"dp", nil, true},
+		{"=graphs", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.value)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCanonicalizer(t *testing.T) {
+	canonicalizer := NewCanonicalizer(map[string]string{"dp": "dynamic programming"})
+
+	got := canonicalizer.CanonicalAll([]string{"DP", "Dynamic Programming", " ", "graphs", "dynamic programming"})
+	if want := []string{"dynamic programming", "graphs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CanonicalAll = %v, want %v", got, want)
+	}
+	want := map[string][]string{"dynamic programming": {"DP", "Dynamic Programming", "dynamic programming"}}
+	if merged := canonicalizer.MergedKeys(); !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergedKeys = %v, want %v", merged, want)
+	}
+}
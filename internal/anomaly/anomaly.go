@@ -0,0 +1,124 @@
+package anomaly
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EnvK overrides DefaultK, the number of MADs above the median that is still plausible
+const EnvK = "ANOMALY_K"
+
+const DefaultK = 5.0
+
+// WindowDays is how far back the baseline for each day reaches
+const WindowDays = 90
+
+// MinHistory is the number of active days a window needs before a day can be
+// judged against it; below that only the hard limit applies
+const MinHistory = 7
+
+const (
+	// SeverityHigh is well above the usual values but physically possible
+	SeverityHigh = "high"
+	// SeverityImpossible exceeds a hard physical limit, e.g. 1440 minutes in a day
+	SeverityImpossible = "impossible"
+)
+
+// Day is the aggregated value of one calendar day
+type Day struct {
+	Date  time.Time
+	Value int
+}
+
+// Finding is a day whose value looks like a data entry or import mistake
+type Finding struct {
+	Date      time.Time
+	Value     int
+	Threshold float64
+	Severity  string
+}
+
+// KFromEnv reads ANOMALY_K, falling back to DefaultK when it is unset
+func KFromEnv() (float64, error) {
+	value := os.Getenv(EnvK)
+	if value == "" {
+		return DefaultK, nil
+	}
+	return ParseK(value)
+}
+
+// ParseK accepts any positive number
+func ParseK(value string) (float64, error) {
+	k, err := strconv.ParseFloat(value, 64)
+	if err != nil || k <= 0 {
+		return 0, fmt.Errorf("invalid k %q: must be a positive number", value)
+	}
+	return k, nil
+}
+
+// Detect flags days whose value exceeds median + k*MAD of the active days in
+// the WindowDays before them. Days above hardLimit are impossible whatever the
+// history; a hardLimit of 0 disables that check. Findings are in date order.
+func Detect(days []Day, k float64, hardLimit int) []Finding {
+	sorted := append([]Day(nil), days...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	findings := []Finding{}
+	start := 0
+	for i, day := range sorted {
+		windowStart := day.Date.AddDate(0, 0, -WindowDays)
+		for start < i && sorted[start].Date.Before(windowStart) {
+			start++
+		}
+
+		if hardLimit > 0 && day.Value > hardLimit {
+			findings = append(findings, Finding{Date: day.Date, Value: day.Value, Threshold: float64(hardLimit), Severity: SeverityImpossible})
+			continue
+		}
+
+		window := sorted[start:i]
+		if len(window) < MinHistory {
+			continue
+		}
+		if threshold := robustThreshold(window, k); float64(day.Value) > threshold {
+			findings = append(findings, Finding{Date: day.Date, Value: day.Value, Threshold: threshold, Severity: SeverityHigh})
+		}
+	}
+	return findings
+}
+
+// robustThreshold is median + k*MAD. The MAD is floored at 1 so a perfectly
+// regular history does not flag every small deviation.
+func robustThreshold(window []Day, k float64) float64 {
+	values := make([]float64, len(window))
+	for i, day := range window {
+		values[i] = float64(day.Value)
+	}
+	med := median(values)
+
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = value - med
+		if deviations[i] < 0 {
+			deviations[i] = -deviations[i]
+		}
+	}
+	mad := median(deviations)
+	if mad < 1 {
+		mad = 1
+	}
+	return med + k*mad
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
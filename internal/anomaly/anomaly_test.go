@@ -0,0 +1,142 @@
+package anomaly
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// history is one day per value from start, the shape of the daily aggregates
+func history(values ...int) []Day {
+	days := make([]Day, len(values))
+	for i, value := range values {
+		days[i] = Day{Date: start.AddDate(0, 0, i), Value: value}
+	}
+	return days
+}
+
+// steady is n days around 30 minutes, with a median of 30 and a MAD of 2
+func steady(n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = []int{28, 30, 32}[i%3]
+	}
+	return values
+}
+
+func severities(findings []Finding) map[int]string {
+	got := make(map[int]string)
+	for _, finding := range findings {
+		got[int(finding.Date.Sub(start).Hours()/24)] = finding.Severity
+	}
+	return got
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []int
+		k         float64
+		hardLimit int
+		// want maps the index of each flagged day to its severity
+		want map[int]string
+	}{
+		{"steady history", steady(30), 5, 1440, map[int]string{}},
+		{"high outlier", append(steady(20), 300), 5, 1440, map[int]string{20: SeverityHigh}},
+		{"over the hard limit", append(steady(20), 2000), 5, 1440, map[int]string{20: SeverityImpossible}},
+		{"high and impossible", append(append(steady(20), 300), append(steady(5), 1441)...), 5, 1440,
+			map[int]string{20: SeverityHigh, 26: SeverityImpossible}},
+		{"exactly the hard limit is only high", append(steady(20), 1440), 5, 1440, map[int]string{20: SeverityHigh}},
+		// median 30 + 5*2 = 40
+		{"at the threshold", append(steady(20), 40), 5, 1440, map[int]string{}},
+		{"just above the threshold", append(steady(20), 41), 5, 1440, map[int]string{20: SeverityHigh}},
+		{"larger k tolerates more", append(steady(20), 300), 200, 1440, map[int]string{}},
+		{"hard limit disabled", append(steady(20), 2000), 5, 0, map[int]string{20: SeverityHigh}},
+		{"too little history for the threshold", append(steady(MinHistory-1), 300), 5, 1440, map[int]string{}},
+		{"too little history still checks the hard limit", append(steady(3), 2000), 5, 1440, map[int]string{3: SeverityImpossible}},
+		// A regular 10 a day has no deviation; the MAD floor of 1 puts the
+		// threshold at 15
+		{"constant history", append(append(make([]int, 0), 10, 10, 10, 10, 10, 10, 10, 10), 15, 16), 5, 1440,
+			map[int]string{9: SeverityHigh}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := severities(Detect(history(tt.values...), tt.k, tt.hardLimit))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flagged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectWindow(t *testing.T) {
+	// Busy days long ago don't make a later spike plausible
+	days := history(steady(30)...)
+	for i := range days[:20] {
+		days[i].Value = 300
+	}
+	// The window of the spike only reaches back to the last busy day
+	spike := Day{Date: days[19].Date.AddDate(0, 0, WindowDays), Value: 300}
+	days = append(days, spike)
+
+	findings := Detect(days, 5, 1440)
+	if len(findings) != 1 || !findings[0].Date.Equal(spike.Date) {
+		t.Fatalf("findings = %+v, want only the spike", findings)
+	}
+	if findings[0].Threshold != 40 {
+		t.Errorf("threshold = %v, want 40 from the last %d days", findings[0].Threshold, WindowDays)
+	}
+}
+
+func TestDetectOrder(t *testing.T) {
+	days := history(append(append(steady(20), 300), append(steady(5), 2000)...)...)
+	reversed := make([]Day, len(days))
+	for i, day := range days {
+		reversed[len(days)-1-i] = day
+	}
+
+	findings := Detect(reversed, 5, 1440)
+	if len(findings) != 2 || !findings[0].Date.Before(findings[1].Date) {
+		t.Errorf("findings = %+v, want two in date order", findings)
+	}
+	if len(Detect(nil, 5, 1440)) != 0 || Detect(nil, 5, 1440) == nil {
+		t.Error("Detect without days should return an empty, non-nil list")
+	}
+}
+
+func TestParseK(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"3", 3, false},
+		{"2.5", 2.5, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"many", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseK(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseK(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestKFromEnv(t *testing.T) {
+	t.Setenv(EnvK, "")
+	if k, err := KFromEnv(); err != nil || k != DefaultK {
+		t.Errorf("KFromEnv unset = %v, %v, want %v", k, err, DefaultK)
+	}
+	t.Setenv(EnvK, "3.5")
+	if k, err := KFromEnv(); err != nil || k != 3.5 {
+		t.Errorf("KFromEnv = %v, %v, want 3.5", k, err)
+	}
+	t.Setenv(EnvK, "none")
+	if _, err := KFromEnv(); err == nil {
+		t.Error("KFromEnv accepted an invalid k")
+	}
+}
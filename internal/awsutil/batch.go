@@ -0,0 +1,64 @@
+package awsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/ratelimit"
+)
+
+// MaxBatchWriteSize is the most items BatchWriteItem accepts in one call
+const MaxBatchWriteSize = 25
+
+// MaxBatchAttempts bounds the attempts per batch call while DynamoDB keeps
+// throttling or handing items back
+const MaxBatchAttempts = 8
+
+// WriteBatch writes a chunk of at most MaxBatchWriteSize requests to table,
+// paced through limiter, and slows down whenever DynamoDB throttles or hands
+// back unprocessed items. Every retry first waits out a capped, jittered
+// ratelimit.Backoff on clock, with or without a limiter. Whatever is still
+// pending after MaxBatchAttempts is returned rather than dropped.
+func WriteBatch(ctx context.Context, client DynamoAPI, table string, pending []types.WriteRequest, limiter *ratelimit.Limiter, clock ratelimit.Clock) ([]types.WriteRequest, error) {
+	for attempt := 1; len(pending) > 0; attempt++ {
+		if attempt > MaxBatchAttempts {
+			slog.Warn("Gave up writing items", "table", table, "attempts", MaxBatchAttempts, "unprocessed", len(pending))
+			return pending, nil
+		}
+		if attempt > 1 {
+			if err := clock.Sleep(ctx, ratelimit.Backoff(attempt-1)); err != nil {
+				return pending, fmt.Errorf("interrupted backing off: %w", err)
+			}
+		}
+
+		if err := limiter.Wait(ctx, len(pending)); err != nil {
+			return pending, fmt.Errorf("failed waiting for write capacity: %w", err)
+		}
+
+		output, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{table: pending},
+		})
+		var throttled *types.ProvisionedThroughputExceededException
+		if errors.As(err, &throttled) {
+			limiter.Throttled()
+			continue
+		}
+		if err != nil {
+			return pending, fmt.Errorf("failed to batch write items to DynamoDB: %w", err)
+		}
+
+		pending = output.UnprocessedItems[table]
+		if len(pending) > 0 {
+			limiter.Throttled()
+			continue
+		}
+		limiter.Succeeded()
+	}
+
+	return nil, nil
+}
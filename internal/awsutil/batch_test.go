@@ -0,0 +1,103 @@
+package awsutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/ratelimit"
+)
+
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func puts(n int) []types.WriteRequest {
+	requests := make([]types.WriteRequest, n)
+	for i := range requests {
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberN{Value: string(rune('0' + i))},
+		}}}
+	}
+	return requests
+}
+
+func TestWriteBatch(t *testing.T) {
+	writeErr := errors.New("boom")
+	tests := []struct {
+		name        string
+		err         func(string, int) error
+		unprocessed func(int, map[string][]types.WriteRequest) map[string][]types.WriteRequest
+		wantPending int
+		wantErr     error
+		wantWrites  int
+	}{
+		{"written at once", nil, nil, 0, nil, 1},
+		{"throttled then written", func(op string, n int) error {
+			if n == 1 {
+				return &types.ProvisionedThroughputExceededException{}
+			}
+			return nil
+		}, nil, 0, nil, 2},
+		{"items handed back every time", nil, func(_ int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+			return map[string][]types.WriteRequest{"table": requests["table"][:2]}
+		}, 2, nil, awsutil.MaxBatchAttempts},
+		{"other errors end the chunk", func(string, int) error { return writeErr }, nil, 5, writeErr, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New("table")
+			fake.Err = tt.err
+			fake.Unprocessed = tt.unprocessed
+			clock := &fakeClock{now: time.Unix(0, 0)}
+
+			pending, err := awsutil.WriteBatch(context.Background(), fake, "table", puts(5), nil, clock)
+			if !errors.Is(err, tt.wantErr) || len(pending) != tt.wantPending {
+				t.Errorf("WriteBatch = %d pending, %v, want %d, %v", len(pending), err, tt.wantPending, tt.wantErr)
+			}
+			if got := fake.Count("BatchWriteItem"); got != tt.wantWrites {
+				t.Errorf("BatchWriteItem called %d times, want %d", got, tt.wantWrites)
+			}
+			if len(clock.slept) != tt.wantWrites-1 {
+				t.Errorf("slept %d times, want a backoff before each of the %d retries", len(clock.slept), tt.wantWrites-1)
+			}
+		})
+	}
+}
+
+func TestWriteBatchSlowsDown(t *testing.T) {
+	fake := dynamotest.New("table")
+	fake.Unprocessed = func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		if n == 1 {
+			return map[string][]types.WriteRequest{"table": requests["table"][:1]}
+		}
+		return nil
+	}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := ratelimit.NewWithClock(10, clock)
+
+	if pending, err := awsutil.WriteBatch(context.Background(), fake, "table", puts(5), limiter, clock); err != nil || len(pending) != 0 {
+		t.Fatalf("WriteBatch = %d pending, %v, want everything written", len(pending), err)
+	}
+	// Halved by the handed back item, then a tenth of the budget recovered
+	if got := limiter.Rate(); got != 6 {
+		t.Errorf("rate = %v, want 6", got)
+	}
+}
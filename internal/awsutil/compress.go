@@ -0,0 +1,50 @@
+package awsutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CompressThreshold is the body size, in bytes, above which responses are
+// gzipped for clients that accept it
+const CompressThreshold = 4 * 1024
+
+// Compress gzips the body of response when the request accepts gzip and the
+// body is larger than CompressThreshold. Any other response is returned as is.
+func Compress(event events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if response.IsBase64Encoded || len(response.Body) <= CompressThreshold || !acceptsGzip(event.Headers) {
+		return response
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(response.Body))
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		slog.Warn("Failed to gzip response, sending it uncompressed", "error", err)
+		return response
+	}
+
+	response.Body = base64.StdEncoding.EncodeToString(buffer.Bytes())
+	response.IsBase64Encoded = true
+	response.Headers["Content-Encoding"] = "gzip"
+	response.Headers["Vary"] = "Accept-Encoding"
+	return response
+}
+
+func acceptsGzip(headers map[string]string) bool {
+	for _, coding := range strings.Split(HeaderValue(headers, "Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
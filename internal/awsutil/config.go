@@ -0,0 +1,53 @@
+package awsutil
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// DefaultRegion is used when neither RegionEnv nor AWS_REGION is set
+const DefaultRegion = "sa-east-1"
+
+// RegionEnv selects the DynamoDB region. Lambda reserves AWS_REGION for the
+// function's own region, so this is the way to reach a table elsewhere.
+const RegionEnv = "VEET_REGION"
+
+// QuestionsTableEnv overrides the questions table name, e.g. for staging
+const QuestionsTableEnv = "QUESTIONS_TABLE_NAME"
+
+// StudiesTableEnv overrides the studies table name, for handlers like the
+// dashboard that read both tables
+const StudiesTableEnv = "STUDIES_TABLE_NAME"
+
+// DynamoEndpointEnv points the DynamoDB client at DynamoDB Local or LocalStack
+const DynamoEndpointEnv = "DYNAMODB_ENDPOINT"
+
+// LoadConfig loads the default AWS config with the region from RegionEnv,
+// then AWS_REGION, then DefaultRegion. Against a local endpoint without credentials in the
+// environment it falls back to static dummy credentials.
+func LoadConfig(ctx context.Context) (aws.Config, error) {
+	var optFns []func(*config.LoadOptions) error
+	if region := os.Getenv(RegionEnv); region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	} else if os.Getenv("AWS_REGION") == "" {
+		optFns = append(optFns, config.WithRegion(DefaultRegion))
+	}
+	if os.Getenv(DynamoEndpointEnv) != "" && os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		optFns = append(optFns, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider("local", "local", ""),
+		))
+	}
+	return config.LoadDefaultConfig(ctx, optFns...)
+}
+
+// TableName reads a table name from envVar, defaulting to fallback when unset
+func TableName(envVar, fallback string) string {
+	if name := os.Getenv(envVar); name != "" {
+		return name
+	}
+	return fallback
+}
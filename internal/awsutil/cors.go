@@ -0,0 +1,76 @@
+package awsutil
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// AllowedOriginsEnv lists the origins, comma-separated, that may call the API
+// from a browser. Unset keeps allowing any origin.
+const AllowedOriginsEnv = "CORS_ALLOWED_ORIGINS"
+
+// ProxyHandler is the signature of every API Gateway handler
+type ProxyHandler func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// WithCORS answers OPTIONS preflights with a 204 and restricts the
+// Access-Control-Allow-Origin header of handler's responses to the origins in
+// CORS_ALLOWED_ORIGINS. Requests from other origins are still served, only
+// without the header, so browsers refuse to expose the response.
+func WithCORS(handler ProxyHandler) ProxyHandler {
+	allowed := allowedOrigins()
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if event.HTTPMethod == "OPTIONS" {
+			return applyOrigin(allowed, event, events.APIGatewayProxyResponse{StatusCode: 204, Headers: CORSHeaders()}), nil
+		}
+
+		response, err := handler(ctx, event)
+		if err != nil {
+			return response, err
+		}
+		return applyOrigin(allowed, event, response), nil
+	}
+}
+
+func allowedOrigins() map[string]bool {
+	value := os.Getenv(AllowedOriginsEnv)
+	if value == "" {
+		return nil
+	}
+
+	origins := make(map[string]bool)
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins[origin] = true
+		}
+	}
+	return origins
+}
+
+// applyOrigin echoes the request's Origin when it is allowed, leaving the
+// wildcard in place when no allow list is configured
+func applyOrigin(allowed map[string]bool, event events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if allowed == nil {
+		return response
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+
+	response.Headers["Vary"] = appendVary(response.Headers["Vary"], "Origin")
+	if origin := HeaderValue(event.Headers, "Origin"); allowed[origin] {
+		response.Headers["Access-Control-Allow-Origin"] = origin
+	} else {
+		delete(response.Headers, "Access-Control-Allow-Origin")
+	}
+	return response
+}
+
+func appendVary(vary, header string) string {
+	if vary == "" {
+		return header
+	}
+	return vary + ", " + header
+}
@@ -0,0 +1,37 @@
+package awsutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DeadlineMargin is kept free before the Lambda deadline so a handler whose
+// DynamoDB calls were cut short still has time to answer
+const DeadlineMargin = 500 * time.Millisecond
+
+// WithDeadline gives handler a context that expires DeadlineMargin before
+// the invocation's deadline. A server error answered after that context
+// expired is reported as a 503 timeout rather than a generic 500.
+func WithDeadline(handler ProxyHandler) ProxyHandler {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return handler(ctx, event)
+		}
+
+		ctx, cancel := context.WithDeadline(ctx, deadline.Add(-DeadlineMargin))
+		defer cancel()
+
+		response, err := handler(ctx, event)
+		if err == nil && response.StatusCode >= 500 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			slog.Warn("Request ran out of time", "status", response.StatusCode)
+			// The failure was already counted when the 5xx was built
+			return APIError{Code: CodeTimeout, Message: "the request timed out, try again"}.response(), nil
+		}
+		return response, err
+	}
+}
@@ -0,0 +1,41 @@
+package awsutil
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/tracing"
+)
+
+// DynamoAPI is the part of the DynamoDB client the lambdas use. Handlers hold
+// it in a package variable so it can be swapped for an in-memory fake.
+type DynamoAPI interface {
+	dynamodb.ScanAPIClient
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+var _ DynamoAPI = (*dynamodb.Client)(nil)
+
+// NewDynamoClient builds a DynamoDB client from LoadConfig, honoring
+// DYNAMODB_ENDPOINT when set and tracing every call when tracing is enabled
+func NewDynamoClient(ctx context.Context) (*dynamodb.Client, error) {
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint := os.Getenv(DynamoEndpointEnv); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		tracing.InstrumentAWS(&o.APIOptions)
+	}), nil
+}
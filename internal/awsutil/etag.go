@@ -0,0 +1,51 @@
+package awsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WithETag sets an ETag derived from the body of a 200 response and turns the
+// response into an empty 304 when the request's If-None-Match already holds
+// it. encoding/json writes map keys in sorted order, so equal statistics
+// always hash to the same tag.
+func WithETag(event events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if response.StatusCode != 200 {
+		return response
+	}
+
+	sum := sha256.Sum256([]byte(response.Body))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	response.Headers["ETag"] = etag
+
+	if matchesETag(HeaderValue(event.Headers, "If-None-Match"), etag) {
+		response.StatusCode = 304
+		response.Body = ""
+		delete(response.Headers, "Content-Type")
+	}
+	return response
+}
+
+// matchesETag compares weakly, as If-None-Match requires
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderValue looks a header up case-insensitively, as HTTP header names are
+func HeaderValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package awsutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxPageLimit caps the limit query parameter
+const MaxPageLimit = 1000
+
+// Page is the body of a paginated list response. NextToken is empty on the last page.
+type Page[T any] struct {
+	Items     []T    `json:"items"`
+	NextToken string `json:"nextToken,omitempty"`
+}
+
+// ParseLimit reads the limit query parameter; zero means no limit was given
+func ParseLimit(value string) (int32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > MaxPageLimit {
+		return 0, fmt.Errorf("invalid limit %q: use a whole number between 1 and %d", value, MaxPageLimit)
+	}
+	return int32(limit), nil
+}
+
+// tokenValue keeps the attribute type so keys survive the round trip
+type tokenValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+// EncodePageToken turns a LastEvaluatedKey into an opaque URL-safe token
+func EncodePageToken(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	values := make(map[string]tokenValue, len(key))
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = tokenValue{S: &v.Value}
+		case *types.AttributeValueMemberN:
+			values[name] = tokenValue{N: &v.Value}
+		case *types.AttributeValueMemberB:
+			values[name] = tokenValue{B: v.Value}
+		default:
+			return "", fmt.Errorf("unsupported key attribute type %T for %s", value, name)
+		}
+	}
+
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+// DecodePageToken turns a token from EncodePageToken back into an ExclusiveStartKey
+func DecodePageToken(token string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nextToken: %w", err)
+	}
+	var values map[string]tokenValue
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid nextToken: %w", err)
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, value := range values {
+		switch {
+		case value.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *value.S}
+		case value.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *value.N}
+		case value.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: value.B}
+		default:
+			return nil, fmt.Errorf("invalid nextToken: empty value for %s", name)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,175 @@
+package awsutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/quota"
+)
+
+// CORSHeaders returns the headers every endpoint answers with. WithCORS
+// narrows the allowed origin per request.
+func CORSHeaders() map[string]string {
+	return map[string]string{
+		"Access-Control-Allow-Origin":   "*",
+		"Access-Control-Allow-Methods":  "GET, POST, PUT, DELETE, OPTIONS",
+		"Access-Control-Allow-Headers":  "Content-Type, Authorization, X-Tenant, X-User-Id, If-None-Match, Idempotency-Key",
+		"Access-Control-Expose-Headers": "ETag, X-Next-Token, X-Cache-Age, X-Quota-Warning",
+	}
+}
+
+// JSONResponse marshals body into an API Gateway response with CORS headers,
+// falling back to a 500 when the body cannot be marshaled or would exceed
+// the response size limit. Such a 500 is counted without a tenant.
+func JSONResponse(status int, body any) events.APIGatewayProxyResponse {
+	responseBody, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("Failed to marshal response", "error", err)
+		return ErrorResponse(context.Background(), CodeInternal, "Internal Server Error")
+	}
+	if quota.ResponseSize.Exceeded(len(responseBody)) {
+		slog.Error("Response exceeds the size limit", "bytes", len(responseBody), "limit", quota.ResponseSize.Max)
+		return ErrorResponse(context.Background(), CodeInternal, "response too large")
+	}
+
+	headers := CORSHeaders()
+	headers["Content-Type"] = "application/json"
+
+	var warnings quota.Warnings
+	warnings.Check(quota.ResponseSize, len(responseBody))
+	warnings.Apply(headers)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       string(responseBody),
+	}
+}
+
+// WarnedResponse is JSONResponse for bodies that report the quota warnings
+// of a request in a "warnings" field. A response-size warning is listed
+// there too, and the header carries the same warnings as the body.
+func WarnedResponse(status int, body map[string]any, warnings quota.Warnings) events.APIGatewayProxyResponse {
+	if len(warnings) > 0 {
+		body["warnings"] = warnings
+	}
+	if responseBody, err := json.Marshal(body); err == nil {
+		if warning := quota.ResponseSize.Warning(len(responseBody)); warning != "" {
+			warnings = append(warnings, warning)
+			body["warnings"] = warnings
+		}
+	}
+
+	response := JSONResponse(status, body)
+	if len(warnings) > 0 && response.StatusCode == status {
+		delete(response.Headers, quota.WarningHeader)
+		warnings.Apply(response.Headers)
+	}
+	return response
+}
+
+// NextTokenHeader carries the pagination token of responses, like CSV, that
+// have no body field for it
+const NextTokenHeader = "X-Next-Token"
+
+// CSVResponse renders rows as an RFC 4180 CSV attachment named filename,
+// with header as the first row. Like JSONResponse, a failure is counted
+// without a tenant.
+func CSVResponse(status int, filename string, header []string, rows [][]string) events.APIGatewayProxyResponse {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.UseCRLF = true
+	err := writer.Write(header)
+	if err == nil {
+		err = writer.WriteAll(rows)
+	}
+	if err != nil {
+		slog.Error("Failed to write CSV response", "error", err)
+		return ErrorResponse(context.Background(), CodeInternal, "Internal Server Error")
+	}
+
+	headers := CORSHeaders()
+	headers["Content-Type"] = "text/csv; charset=utf-8"
+	headers["Content-Disposition"] = fmt.Sprintf("attachment; filename=%q", filename)
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    headers,
+		Body:       buffer.String(),
+	}
+}
+
+// Error codes of the {"error": {"code": ..., "message": ...}} envelope every
+// failed request answers with. validation.Error uses the same shape for its
+// own 400 codes.
+const (
+	CodeInvalidBody      = "INVALID_BODY"
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeDatabaseError    = "DATABASE_ERROR"
+	CodeInternal         = "INTERNAL_ERROR"
+	CodeTimeout          = "TIMEOUT"
+)
+
+var statusByCode = map[string]int{
+	CodeInvalidBody:      400,
+	CodeInvalidParameter: 400,
+	CodeUnauthorized:     401,
+	CodeForbidden:        403,
+	CodeNotFound:         404,
+	CodeConflict:         409,
+	CodeDatabaseError:    500,
+	CodeInternal:         500,
+	CodeTimeout:          503,
+}
+
+// APIError is the body of an error response. Details carries extra data for
+// the client, like the names behind a conflict.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Status is the HTTP status matching the error code, 500 for unknown codes
+func (e APIError) Status() int {
+	if status, ok := statusByCode[e.Code]; ok {
+		return status
+	}
+	return 500
+}
+
+// Response wraps the error as {"error": {...}} with its status. Server-side
+// failures are counted in the Errors metric.
+func (e APIError) Response(ctx context.Context) events.APIGatewayProxyResponse {
+	if e.Status() >= 500 {
+		metrics.Emit(ctx, metrics.Count(metrics.Errors, 1))
+	}
+	return e.response()
+}
+
+func (e APIError) response() events.APIGatewayProxyResponse {
+	responseBody, _ := json.Marshal(map[string]APIError{"error": e})
+
+	headers := CORSHeaders()
+	headers["Content-Type"] = "application/json"
+	return events.APIGatewayProxyResponse{
+		StatusCode: e.Status(),
+		Headers:    headers,
+		Body:       string(responseBody),
+	}
+}
+
+// ErrorResponse returns the error envelope for code and msg
+func ErrorResponse(ctx context.Context, code, msg string) events.APIGatewayProxyResponse {
+	return APIError{Code: code, Message: msg}.Response(ctx)
+}
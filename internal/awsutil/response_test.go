@@ -0,0 +1,82 @@
+package awsutil
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"veet-code-go/internal/quota"
+)
+
+func TestJSONResponseSizeWarning(t *testing.T) {
+	threshold := quota.ResponseSize.Max * 4 / 5
+	tests := []struct {
+		name       string
+		bytes      int
+		wantStatus int
+		wantWarn   bool
+	}{
+		{"just below the threshold", threshold - 1, 200, false},
+		{"at the threshold", threshold, 200, true},
+		{"at the limit", quota.ResponseSize.Max, 200, true},
+		{"just above the limit", quota.ResponseSize.Max + 1, 500, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A JSON string marshals to its contents plus two quotes
+			response := JSONResponse(200, strings.Repeat("a", tt.bytes-2))
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			warning := response.Headers[quota.WarningHeader]
+			if got := warning != ""; got != tt.wantWarn {
+				t.Errorf("%s = %q, want a warning %v", quota.WarningHeader, warning, tt.wantWarn)
+			}
+			if tt.wantWarn && !strings.HasPrefix(warning, quota.ResponseSize.Name+" ") {
+				t.Errorf("%s = %q, want the response size", quota.WarningHeader, warning)
+			}
+		})
+	}
+}
+
+func TestWarnedResponse(t *testing.T) {
+	threshold := quota.ResponseSize.Max * 4 / 5
+	tests := []struct {
+		name     string
+		warnings quota.Warnings
+		bytes    int
+		want     []string
+	}{
+		{"no warnings", nil, 100, nil},
+		{"request warnings", quota.Warnings{"batch-size 450/500"}, 100, []string{"batch-size 450/500"}},
+		{"response size", nil, threshold, []string{"response-size "}},
+		{"both", quota.Warnings{"batch-size 450/500"}, threshold, []string{"batch-size 450/500", "response-size "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := WarnedResponse(207, map[string]any{"padding": strings.Repeat("a", tt.bytes)}, tt.warnings)
+			if response.StatusCode != 207 {
+				t.Fatalf("status = %d, want 207", response.StatusCode)
+			}
+			var payload struct {
+				Warnings []string `json:"warnings"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &payload); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+
+			// The size in a response-size warning depends on the body, so
+			// warnings are compared by their start
+			matches := len(payload.Warnings) == len(tt.want)
+			for i := 0; matches && i < len(tt.want); i++ {
+				matches = strings.HasPrefix(payload.Warnings[i], tt.want[i])
+			}
+			if !matches {
+				t.Errorf("warnings = %v, want %v", payload.Warnings, tt.want)
+			}
+			if header := response.Headers[quota.WarningHeader]; header != strings.Join(payload.Warnings, ", ") {
+				t.Errorf("%s = %q, want the warnings of the body %v", quota.WarningHeader, header, payload.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package awsutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+)
+
+// ScanAttempts bounds how often a throttled scan page is requested, on top
+// of the retries the SDK makes within each attempt
+const ScanAttempts = 4
+
+const scanBaseBackoff = 200 * time.Millisecond
+
+// retryableCodes are the error codes worth asking again for: throttling and
+// transient server-side failures
+var retryableCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+	"InternalServerError":                    true,
+	"ServiceUnavailable":                     true,
+}
+
+// NextPage fetches the next page of paginator, retrying throttling and
+// transient errors with exponential backoff and full jitter. Other errors are
+// returned right away. A failed call leaves the paginator where it was, so the
+// retry asks for the same page.
+func NextPage(ctx context.Context, paginator *dynamodb.ScanPaginator) (*dynamodb.ScanOutput, error) {
+	for attempt := 1; ; attempt++ {
+		page, err := paginator.NextPage(ctx)
+		if err == nil || attempt == ScanAttempts || !retryable(err) {
+			return page, err
+		}
+
+		delay := rand.N(scanBaseBackoff << (attempt - 1))
+		slog.Warn("Scan page throttled, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func retryable(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && retryableCodes[apiErr.ErrorCode()]
+}
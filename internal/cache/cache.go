@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// EnvTTL sets how many seconds a warm container keeps serving a computed
+// response. Unset or 0 disables the cache.
+const EnvTTL = "STATS_CACHE_TTL_SECONDS"
+
+// AgeHeader tells the client how many seconds old the response is; 0 means
+// it was computed for this request
+const AgeHeader = "X-Cache-Age"
+
+// RefreshParam set to true skips the cached response and replaces it
+const RefreshParam = "refresh"
+
+// Responses keeps successful responses in memory between warm invocations
+type Responses struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]entry
+}
+
+type entry struct {
+	response events.APIGatewayProxyResponse
+	storedAt time.Time
+}
+
+// New returns a cache holding responses for ttl; a zero ttl caches nothing
+func New(ttl time.Duration) *Responses {
+	return &Responses{ttl: ttl, entries: make(map[string]entry)}
+}
+
+// FromEnv builds a cache with the TTL in STATS_CACHE_TTL_SECONDS, disabling it
+// when the value is invalid
+func FromEnv() *Responses {
+	value := os.Getenv(EnvTTL)
+	if value == "" {
+		return New(0)
+	}
+	ttl, err := ParseTTL(value)
+	if err != nil {
+		slog.Warn("Statistics cache disabled", "error", err)
+		return New(0)
+	}
+	return New(ttl)
+}
+
+// ParseTTL accepts a non-negative whole number of seconds
+func ParseTTL(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid %s %q: use a non-negative whole number of seconds", EnvTTL, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Key identifies a response by tenant, user and query string, ignoring refresh
+func Key(ctx context.Context, event events.APIGatewayProxyRequest) string {
+	query := url.Values{}
+	for name, value := range event.QueryStringParameters {
+		query.Set(name, value)
+	}
+	for name, values := range event.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+	query.Del(RefreshParam)
+	return tenant.Key(ctx, user.Key(ctx, query.Encode()))
+}
+
+// Get returns the response stored under key while it is younger than the
+// TTL, unless the request asks for a refresh
+func (c *Responses) Get(key string, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, bool) {
+	if c.ttl <= 0 || event.QueryStringParameters[RefreshParam] == "true" {
+		return events.APIGatewayProxyResponse{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.entries[key]
+	if !ok {
+		return events.APIGatewayProxyResponse{}, false
+	}
+	age := time.Since(cached.storedAt)
+	if age >= c.ttl {
+		delete(c.entries, key)
+		return events.APIGatewayProxyResponse{}, false
+	}
+	return withAge(cached.response, age), true
+}
+
+// Put stores a 200 response under key and returns it with a zero age
+func (c *Responses) Put(key string, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if c.ttl > 0 && response.StatusCode == 200 {
+		c.mu.Lock()
+		c.entries[key] = entry{response: withAge(response, 0), storedAt: time.Now()}
+		c.mu.Unlock()
+	}
+	return withAge(response, 0)
+}
+
+// withAge copies the headers so callers can keep adding their own without
+// touching the stored response
+func withAge(response events.APIGatewayProxyResponse, age time.Duration) events.APIGatewayProxyResponse {
+	headers := make(map[string]string, len(response.Headers)+1)
+	for name, value := range response.Headers {
+		headers[name] = value
+	}
+	headers[AgeHeader] = strconv.Itoa(int(age.Seconds()))
+	response.Headers = headers
+	return response
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+func TestResponses(t *testing.T) {
+	responses := New(time.Minute)
+	event := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"from": "2024-03-01"}}
+
+	if _, ok := responses.Get("k", event); ok {
+		t.Fatal("Get on an empty cache hit")
+	}
+	responses.Put("k", events.APIGatewayProxyResponse{StatusCode: 500, Body: "error"})
+	if _, ok := responses.Get("k", event); ok {
+		t.Fatal("error response was cached")
+	}
+
+	put := responses.Put("k", events.APIGatewayProxyResponse{StatusCode: 200, Body: "stats"})
+	if put.Headers[AgeHeader] != "0" {
+		t.Errorf("put age = %q, want 0", put.Headers[AgeHeader])
+	}
+	if got, ok := responses.Get("k", event); !ok || got.Body != "stats" {
+		t.Errorf("Get = %q, %v, want the stored response", got.Body, ok)
+	}
+
+	refresh := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{RefreshParam: "true"}}
+	if _, ok := responses.Get("k", refresh); ok {
+		t.Error("refresh was served from the cache")
+	}
+
+	disabled := New(0)
+	disabled.Put("k", put)
+	if _, ok := disabled.Get("k", event); ok {
+		t.Error("a zero TTL cached the response")
+	}
+}
+
+func TestKeyIgnoresRefresh(t *testing.T) {
+	plain := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"from": "2024-03-01"}}
+	refresh := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"from": "2024-03-01", RefreshParam: "true"}}
+	if Key(context.Background(), plain) != Key(context.Background(), refresh) {
+		t.Error("refresh changes the key, so it can't replace the cached response")
+	}
+}
+
+// The same request of two tenants, or two users, never shares an entry
+func TestKeyIsolation(t *testing.T) {
+	event := events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"from": "2024-03-01"}}
+	tests := []struct {
+		name      string
+		alice     context.Context
+		bob       context.Context
+		wantEqual bool
+	}{
+		{"tenants", tenant.WithTenant(context.Background(), "alice"), tenant.WithTenant(context.Background(), "bob"), false},
+		{"users", user.WithUser(context.Background(), "alice"), user.WithUser(context.Background(), "bob"), false},
+		{"same user of two tenants",
+			user.WithUser(tenant.WithTenant(context.Background(), "alice"), "carol"),
+			user.WithUser(tenant.WithTenant(context.Background(), "bob"), "carol"), false},
+		{"same tenant", tenant.WithTenant(context.Background(), "alice"), tenant.WithTenant(context.Background(), "alice"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := New(time.Minute)
+			responses.Put(Key(tt.alice, event), events.APIGatewayProxyResponse{StatusCode: 200, Body: "alice's stats"})
+
+			got, ok := responses.Get(Key(tt.bob, event), event)
+			if ok != tt.wantEqual {
+				t.Errorf("Get = %q, %v, want hit %v", got.Body, ok, tt.wantEqual)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateRange bounds statistics to an inclusive window; a zero end is open
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// DateRangeFromQuery reads the from and to query parameters, in any of Layouts
+func DateRangeFromQuery(params map[string]string) (DateRange, error) {
+	var dateRange DateRange
+	for name, target := range map[string]*time.Time{"from": &dateRange.From, "to": &dateRange.To} {
+		value := params[name]
+		if value == "" {
+			continue
+		}
+		date, err := ParseDate(value)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid %s date %q: use YYYY-MM-DD or DD/MM/YYYY", name, value)
+		}
+		*target = date
+	}
+
+	if !dateRange.From.IsZero() && !dateRange.To.IsZero() && dateRange.To.Before(dateRange.From) {
+		return DateRange{}, fmt.Errorf("to date %s is before from date %s", params["to"], params["from"])
+	}
+	return dateRange, nil
+}
+
+// IsZero reports whether the range filters nothing
+func (r DateRange) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains reports whether date falls within the range
+func (r DateRange) Contains(date time.Time) bool {
+	if !r.From.IsZero() && date.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && date.After(r.To) {
+		return false
+	}
+	return true
+}
+
+// ContainsDate is Contains for a stored date; unparseable dates are only
+// contained in an empty range
+func (r DateRange) ContainsDate(value string) bool {
+	if r.IsZero() {
+		return true
+	}
+	date, err := ParseDate(value)
+	return err == nil && r.Contains(date)
+}
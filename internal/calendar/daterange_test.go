@@ -0,0 +1,53 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateRangeFromQuery(t *testing.T) {
+	march := func(day int) time.Time { return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    DateRange
+		wantErr string
+	}{
+		{"no parameters", nil, DateRange{}, ""},
+		{"ISO dates", map[string]string{"from": "2024-03-01", "to": "2024-03-10"}, DateRange{From: march(1), To: march(10)}, ""},
+		{"day-first dates", map[string]string{"from": "01/03/2024", "to": "10/03/2024"}, DateRange{From: march(1), To: march(10)}, ""},
+		{"open end", map[string]string{"from": "2024-03-01"}, DateRange{From: march(1)}, ""},
+		{"invalid date", map[string]string{"to": "March"}, DateRange{}, `invalid to date "March": use YYYY-MM-DD or DD/MM/YYYY`},
+		{"reversed", map[string]string{"from": "2024-03-10", "to": "01/03/2024"}, DateRange{}, "to date 01/03/2024 is before from date 2024-03-10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DateRangeFromQuery(tt.params)
+			if errorString(err) != tt.wantErr || got != tt.want {
+				t.Errorf("DateRangeFromQuery = %+v, %v, want %+v, %q", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainsDate(t *testing.T) {
+	dateRange := DateRange{From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"2024-03-01", true},
+		{"10/03/2024", true},
+		{"2024-02-29", false},
+		{"11/03/2024", false},
+		{"someday", false},
+	}
+	for _, tt := range tests {
+		if got := dateRange.ContainsDate(tt.value); got != tt.want {
+			t.Errorf("ContainsDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if !(DateRange{}).ContainsDate("someday") {
+		t.Error("an empty range should contain every date")
+	}
+}
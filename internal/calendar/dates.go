@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// EnvTimezone names the IANA zone that decides which calendar day is "today"
+const EnvTimezone = "TIMEZONE"
+
+// DefaultTimezone matches the sa-east-1 deployment
+const DefaultTimezone = "America/Sao_Paulo"
+
+// ISOLayout is the YYYY-MM-DD format question_solved_date is stored with
+const ISOLayout = "2006-01-02"
+
+// DayFirstLayout is the dd/MM/yyyy format study_date is stored with, and the
+// one older questions were written with
+const DayFirstLayout = "02/01/2006"
+
+// Layouts are the date layouts ParseDate accepts
+var Layouts = []string{ISOLayout, DayFirstLayout}
+
+// ParseDate parses a date in any of Layouts. Dates are calendar days already,
+// so no timezone conversion is involved.
+func ParseDate(value string) (time.Time, error) {
+	return parseIn(value, Layouts)
+}
+
+func parseIn(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+}
+
+// NormalizeDate rewrites a date in ISOLayout, leaving values it cannot parse
+// untouched
+func NormalizeDate(value string) string {
+	date, err := ParseDate(value)
+	if err != nil {
+		return value
+	}
+	return date.Format(ISOLayout)
+}
+
+// Location returns the configured timezone, falling back to the default when
+// TIMEZONE is unset or invalid. It is loaded once per container.
+var Location = sync.OnceValue(func() *time.Location {
+	name := os.Getenv(EnvTimezone)
+	if name == "" {
+		name = DefaultTimezone
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		slog.Warn("Invalid timezone, using the default", "variable", EnvTimezone, "value", name, "default", DefaultTimezone, "error", err)
+		location, _ = time.LoadLocation(DefaultTimezone)
+	}
+	return location
+})
+
+// Today returns the calendar day of now in the configured timezone, at UTC
+// midnight so it compares directly with dates returned by ParseDate
+func Today(now time.Time) time.Time {
+	year, month, day := now.In(Location()).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// DaysBetween counts calendar days from start to end, both from ParseDate or Today
+func DaysBetween(start, end time.Time) int {
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// MaxDaysAhead is how far past today the date of a new record may be, which
+// leaves room for a client in a timezone ahead of the configured one
+const MaxDaysAhead = 1
+
+// DateOrToday returns value, or today in layout when value is blank, so
+// records can be added without a date
+func DateOrToday(value, layout string, now time.Time) string {
+	if strings.TrimSpace(value) == "" {
+		return Today(now).Format(layout)
+	}
+	return value
+}
+
+// CheckNewDate validates the date of a record being added, which must be in
+// one of layouts; the first is the one it is stored with. A date further
+// ahead than MaxDaysAhead is rejected as most likely a timezone mix-up.
+func CheckNewDate(value string, now time.Time, layouts ...string) error {
+	date, err := parseIn(value, layouts)
+	if err != nil {
+		names := make([]string, len(layouts))
+		for i, layout := range layouts {
+			names[i] = layoutName(layout)
+		}
+		return fmt.Errorf("must be %s", strings.Join(names, " or "))
+	}
+	if DaysBetween(Today(now), date) > MaxDaysAhead {
+		return fmt.Errorf("must be at most %d day after today, %s", MaxDaysAhead, Today(now).Format(layouts[0]))
+	}
+	return nil
+}
+
+// layoutName spells a layout the way clients are told to write dates
+func layoutName(layout string) string {
+	return strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD").Replace(layout)
+}
@@ -0,0 +1,173 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// noonInSaoPaulo is 2024-03-10 in the default timezone
+var noonInSaoPaulo = time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), false},
+		{"05/03/2024", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), false},
+		{"2024-02-29", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), false},
+		{"29/02/2024", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), false},
+		{"2023-02-29", time.Time{}, true},
+		{"29/02/2023", time.Time{}, true},
+		{"03/25/2024", time.Time{}, true},
+		{"2024/03/05", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.value)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"2024-03-05", "2024-03-05"},
+		{"05/03/2024", "2024-03-05"},
+		{"31/12/2023", "2023-12-31"},
+		{"yesterday", "yesterday"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeDate(tt.value); got != tt.want {
+			t.Errorf("NormalizeDate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       int
+	}{
+		{"2024-03-05", "2024-03-05", 0},
+		{"2024-03-05", "2024-03-06", 1},
+		{"2024-03-06", "2024-03-05", -1},
+		{"2024-02-28", "2024-03-01", 2},
+		{"2023-12-31", "2024-12-31", 366},
+	}
+	for _, tt := range tests {
+		start, _ := ParseDate(tt.start)
+		end, _ := ParseDate(tt.end)
+		if got := DaysBetween(start, end); got != tt.want {
+			t.Errorf("DaysBetween(%s, %s) = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestDateOrToday(t *testing.T) {
+	tests := []struct {
+		value  string
+		layout string
+		want   string
+	}{
+		{"", ISOLayout, "2024-03-10"},
+		{"   ", ISOLayout, "2024-03-10"},
+		{"", DayFirstLayout, "10/03/2024"},
+		{"2024-01-01", ISOLayout, "2024-01-01"},
+		{"01/01/2024", ISOLayout, "01/01/2024"},
+		{"not a date", ISOLayout, "not a date"},
+	}
+	for _, tt := range tests {
+		if got := DateOrToday(tt.value, tt.layout, noonInSaoPaulo); got != tt.want {
+			t.Errorf("DateOrToday(%q, %q) = %q, want %q", tt.value, tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestCheckNewDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		layouts []string
+		wantErr string
+	}{
+		{"2024-03-10", Layouts, ""},
+		{"10/03/2024", Layouts, ""},
+		{"2020-01-01", Layouts, ""},
+		{"2024-03-11", Layouts, ""},
+		{"2024-03-12", Layouts, "must be at most 1 day after today, 2024-03-10"},
+		{"2025-03-10", Layouts, "must be at most 1 day after today, 2024-03-10"},
+		{"10-03-2024", Layouts, "must be YYYY-MM-DD or DD/MM/YYYY"},
+		{"", Layouts, "must be YYYY-MM-DD or DD/MM/YYYY"},
+		{"11/03/2024", []string{DayFirstLayout}, ""},
+		{"12/03/2024", []string{DayFirstLayout}, "must be at most 1 day after today, 10/03/2024"},
+		{"2024-03-10", []string{DayFirstLayout}, "must be DD/MM/YYYY"},
+	}
+	for _, tt := range tests {
+		err := CheckNewDate(tt.value, noonInSaoPaulo, tt.layouts...)
+		if got := errorString(err); got != tt.wantErr {
+			t.Errorf("CheckNewDate(%q, %v) = %q, want %q", tt.value, tt.layouts, got, tt.wantErr)
+		}
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// The default timezone is three hours behind UTC, so between 00:00 and 03:00
+// UTC its calendar day is still the previous one
+func TestToday(t *testing.T) {
+	if name := Location().String(); name != DefaultTimezone {
+		t.Skipf("TIMEZONE is %s, want the default %s", name, DefaultTimezone)
+	}
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 10, 2, 59, 0, 0, time.UTC), "2024-03-09"},
+		{time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC), "2024-03-10"},
+		{time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC), "2024-03-10"},
+		{time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC), "2024-02-29"},
+		// Only the instant matters, not the zone it is expressed in
+		{time.Date(2024, 3, 10, 7, 30, 0, 0, time.FixedZone("UTC+5", 5*3600)), "2024-03-09"},
+		{time.Date(2024, 3, 10, 8, 30, 0, 0, time.FixedZone("UTC+5", 5*3600)), "2024-03-10"},
+	}
+	for _, tt := range tests {
+		got := Today(tt.now)
+		if got.Format(ISOLayout) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("Today(%s) = %s, want %s at UTC midnight", tt.now.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
+
+func TestWeekendDays(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[time.Weekday]bool
+		wantErr bool
+	}{
+		{"", map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}, false},
+		{"Friday,Saturday", map[time.Weekday]bool{time.Friday: true, time.Saturday: true}, false},
+		{"fri, SAT", map[time.Weekday]bool{time.Friday: true, time.Saturday: true}, false},
+		{"Sunday", map[time.Weekday]bool{time.Sunday: true}, false},
+		{"Funday", nil, true},
+		{"Saturday,", nil, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvWeekendDays, tt.value)
+		got, err := WeekendDays()
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WeekendDays(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
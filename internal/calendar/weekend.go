@@ -0,0 +1,40 @@
+package calendar
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// EnvWeekendDays overrides the weekend, e.g. "Friday,Saturday"
+const EnvWeekendDays = "WEEKEND_DAYS"
+
+// WeekendDays returns the configured weekend, Saturday and Sunday by default.
+// Day names are case-insensitive and may be abbreviated to three letters.
+func WeekendDays() (map[time.Weekday]bool, error) {
+	value := os.Getenv(EnvWeekendDays)
+	if value == "" {
+		return map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}, nil
+	}
+
+	weekend := make(map[time.Weekday]bool)
+	for _, name := range strings.Split(value, ",") {
+		day, err := parseWeekday(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		weekend[day] = true
+	}
+	return weekend, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := day.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid %s day %q", EnvWeekendDays, name)
+}
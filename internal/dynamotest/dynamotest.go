@@ -0,0 +1,384 @@
+package dynamotest
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+)
+
+// offsetAttribute carries the position of the next Scan page in
+// LastEvaluatedKey
+const offsetAttribute = "dynamotest_offset"
+
+// Call is one recorded call to the fake
+type Call struct {
+	Operation string
+	Input     any
+}
+
+// Fake is an in-memory awsutil.DynamoAPI for unit tests of the handlers. It
+// keeps every table as a list of items in insertion order. Filters and
+// projections are ignored, but like DynamoDB every call fails with a
+// ValidationException when it passes expression names or values that none
+// of its expressions use. On tables whose Keys are known, UpdateItem applies
+// the subset of expressions described in expression.go and PutItem checks a
+// lone attribute_not_exists(...); no other condition is evaluated.
+type Fake struct {
+	mu sync.Mutex
+
+	// Tables holds the items of each table, by table name
+	Tables map[string][]map[string]types.AttributeValue
+	// Keys names the key attributes of a table. Without them, PutItem always
+	// appends and conditions are never checked.
+	Keys map[string][]string
+	// PageSize caps the items of a Scan page; 0 returns a table in one page
+	PageSize int
+
+	// Err, when it returns an error, makes the call fail without touching the
+	// tables. n counts the calls of operation so far, starting at 1.
+	Err func(operation string, n int) error
+	// Unprocessed, when set, picks the requests of the nth BatchWriteItem call
+	// to hand back as UnprocessedItems; the rest are applied
+	Unprocessed func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest
+
+	// Calls records every call, in order
+	Calls  []Call
+	counts map[string]int
+}
+
+var _ awsutil.DynamoAPI = (*Fake)(nil)
+
+// New returns a fake holding items in table
+func New(table string, items ...map[string]types.AttributeValue) *Fake {
+	return &Fake{Tables: map[string][]map[string]types.AttributeValue{table: items}}
+}
+
+// Count returns how many times operation was called
+func (f *Fake) Count(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[operation]
+}
+
+// Items returns a copy of the items of table
+func (f *Fake) Items(table string) []map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make([]map[string]types.AttributeValue, 0, len(f.Tables[table]))
+	for _, item := range f.Tables[table] {
+		items = append(items, clone(item))
+	}
+	return items
+}
+
+// record logs the call and returns the error Err picks for it, if any.
+// f.mu must be held.
+func (f *Fake) record(operation string, input any) error {
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	if f.Tables == nil {
+		f.Tables = make(map[string][]map[string]types.AttributeValue)
+	}
+	f.counts[operation]++
+	f.Calls = append(f.Calls, Call{Operation: operation, Input: input})
+	if f.Err != nil {
+		return f.Err(operation, f.counts[operation])
+	}
+	return nil
+}
+
+// Scan implements dynamodb.ScanAPIClient
+func (f *Fake) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Scan", params); err != nil {
+		return nil, err
+	}
+	if err := checkUsed(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.FilterExpression, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+
+	items := f.Tables[aws.ToString(params.TableName)]
+	start := 0
+	if offset, ok := params.ExclusiveStartKey[offsetAttribute].(*types.AttributeValueMemberN); ok {
+		start, _ = strconv.Atoi(offset.Value)
+	}
+	end := len(items)
+	if limit := f.pageLimit(params.Limit); limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	if start > end {
+		start = end
+	}
+
+	output := &dynamodb.ScanOutput{}
+	for _, item := range items[start:end] {
+		output.Items = append(output.Items, clone(item))
+	}
+	output.Count = int32(len(output.Items))
+	output.ScannedCount = output.Count
+	if end < len(items) {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{
+			offsetAttribute: &types.AttributeValueMemberN{Value: strconv.Itoa(end)},
+		}
+	}
+	return output, nil
+}
+
+func (f *Fake) pageLimit(limit *int32) int {
+	if limit != nil && (f.PageSize == 0 || int(*limit) < f.PageSize) {
+		return int(*limit)
+	}
+	return f.PageSize
+}
+
+// GetItem implements awsutil.DynamoAPI
+func (f *Fake) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GetItem", params); err != nil {
+		return nil, err
+	}
+	if err := checkUsed(params.ExpressionAttributeNames, nil, params.ProjectionExpression); err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.GetItemOutput{}
+	if i := f.find(aws.ToString(params.TableName), params.Key); i >= 0 {
+		output.Item = clone(f.Tables[aws.ToString(params.TableName)][i])
+	}
+	return output, nil
+}
+
+// PutItem implements awsutil.DynamoAPI
+func (f *Fake) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("PutItem", params); err != nil {
+		return nil, err
+	}
+	if err := checkUsed(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+
+	table := aws.ToString(params.TableName)
+	existing := f.find(table, f.key(table, params.Item))
+	condition := aws.ToString(params.ConditionExpression)
+	if existing >= 0 && strings.HasPrefix(condition, "attribute_not_exists(") && !strings.Contains(condition, " ") {
+		err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		if params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld {
+			err.Item = clone(f.Tables[table][existing])
+		}
+		return nil, err
+	}
+	f.put(table, params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// UpdateItem implements awsutil.DynamoAPI. Unless the Keys of the table are
+// known it only records the call, for tests to read what was asked for.
+func (f *Fake) UpdateItem(_ context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("UpdateItem", params); err != nil {
+		return nil, err
+	}
+	if err := checkUsed(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.UpdateExpression, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+
+	table := aws.ToString(params.TableName)
+	if len(f.Keys[table]) == 0 {
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	// A missing item has no attributes to check, not even its key
+	item := map[string]types.AttributeValue{}
+	existing := f.find(table, params.Key)
+	if existing >= 0 {
+		item = clone(f.Tables[table][existing])
+	}
+	e := expression{names: params.ExpressionAttributeNames, values: params.ExpressionAttributeValues}
+	ok, err := e.check(aws.ToString(params.ConditionExpression), item)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		if existing >= 0 && params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld {
+			err.Item = clone(item)
+		}
+		return nil, err
+	}
+	for name, value := range params.Key {
+		item[name] = value
+	}
+	before := clone(item)
+	if err := e.apply(aws.ToString(params.UpdateExpression), item); err != nil {
+		return nil, err
+	}
+	f.put(table, item)
+	return &dynamodb.UpdateItemOutput{Attributes: returned(params.ReturnValues, before, item)}, nil
+}
+
+// returned picks the attributes an update returns: the whole new item, or
+// those the update changed
+func returned(returnValues types.ReturnValue, before, after map[string]types.AttributeValue) map[string]types.AttributeValue {
+	switch returnValues {
+	case types.ReturnValueAllNew:
+		return clone(after)
+	case types.ReturnValueUpdatedNew:
+		updated := map[string]types.AttributeValue{}
+		for name, value := range after {
+			if !reflect.DeepEqual(before[name], value) {
+				updated[name] = value
+			}
+		}
+		return updated
+	}
+	return nil
+}
+
+// DeleteItem implements awsutil.DynamoAPI
+func (f *Fake) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("DeleteItem", params); err != nil {
+		return nil, err
+	}
+	if err := checkUsed(params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ConditionExpression); err != nil {
+		return nil, err
+	}
+	f.delete(aws.ToString(params.TableName), params.Key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// BatchGetItem implements awsutil.DynamoAPI
+func (f *Fake) BatchGetItem(_ context.Context, params *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("BatchGetItem", params); err != nil {
+		return nil, err
+	}
+	for _, request := range params.RequestItems {
+		if err := checkUsed(request.ExpressionAttributeNames, nil, request.ProjectionExpression); err != nil {
+			return nil, err
+		}
+	}
+
+	output := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	for table, keys := range params.RequestItems {
+		for _, key := range keys.Keys {
+			if i := f.find(table, key); i >= 0 {
+				output.Responses[table] = append(output.Responses[table], clone(f.Tables[table][i]))
+			}
+		}
+	}
+	return output, nil
+}
+
+// BatchWriteItem implements awsutil.DynamoAPI, applying every request that
+// Unprocessed doesn't hand back
+func (f *Fake) BatchWriteItem(_ context.Context, params *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("BatchWriteItem", params); err != nil {
+		return nil, err
+	}
+
+	var unprocessed map[string][]types.WriteRequest
+	if f.Unprocessed != nil {
+		unprocessed = f.Unprocessed(f.counts["BatchWriteItem"], params.RequestItems)
+	}
+	for table, requests := range params.RequestItems {
+		for _, request := range requests {
+			if contains(unprocessed[table], request) {
+				continue
+			}
+			switch {
+			case request.PutRequest != nil:
+				f.put(table, request.PutRequest.Item)
+			case request.DeleteRequest != nil:
+				f.delete(table, request.DeleteRequest.Key)
+			}
+		}
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+// key picks the key attributes of item, or returns nil when the table's key
+// is unknown
+func (f *Fake) key(table string, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	names := f.Keys[table]
+	if len(names) == 0 {
+		return nil
+	}
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		key[name] = item[name]
+	}
+	return key
+}
+
+// find returns the index of the first item of table holding every attribute
+// of key, or -1
+func (f *Fake) find(table string, key map[string]types.AttributeValue) int {
+	if len(key) == 0 {
+		return -1
+	}
+	for i, item := range f.Tables[table] {
+		if matches(item, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *Fake) put(table string, item map[string]types.AttributeValue) {
+	if i := f.find(table, f.key(table, item)); i >= 0 {
+		f.Tables[table][i] = clone(item)
+		return
+	}
+	f.Tables[table] = append(f.Tables[table], clone(item))
+}
+
+func (f *Fake) delete(table string, key map[string]types.AttributeValue) {
+	if i := f.find(table, key); i >= 0 {
+		f.Tables[table] = append(f.Tables[table][:i], f.Tables[table][i+1:]...)
+	}
+}
+
+func matches(item, key map[string]types.AttributeValue) bool {
+	for name, value := range key {
+		if !reflect.DeepEqual(item[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(requests []types.WriteRequest, request types.WriteRequest) bool {
+	for _, r := range requests {
+		if reflect.DeepEqual(r, request) {
+			return true
+		}
+	}
+	return false
+}
+
+func clone(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	copied := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = value
+	}
+	return copied
+}
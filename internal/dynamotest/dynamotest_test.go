@@ -0,0 +1,278 @@
+package dynamotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+func named(name string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: name}}
+}
+
+func TestScanPages(t *testing.T) {
+	tests := []struct {
+		pageSize  int
+		limit     *int32
+		wantPages int
+	}{
+		{0, nil, 1},
+		{2, nil, 3},
+		{5, nil, 1},
+		{0, aws.Int32(4), 2},
+		{2, aws.Int32(1), 5},
+	}
+	for _, tt := range tests {
+		fake := New("t", named("a"), named("b"), named("c"), named("d"), named("e"))
+		fake.PageSize = tt.pageSize
+
+		paginator := dynamodb.NewScanPaginator(fake, &dynamodb.ScanInput{TableName: aws.String("t"), Limit: tt.limit})
+		pages, items := 0, 0
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.Background())
+			if err != nil {
+				t.Fatalf("NextPage: %v", err)
+			}
+			pages++
+			items += len(page.Items)
+		}
+		if pages != tt.wantPages || items != 5 {
+			t.Errorf("page size %d, limit %v: %d pages of %d items, want %d pages of 5", tt.pageSize, aws.ToInt32(tt.limit), pages, items, tt.wantPages)
+		}
+	}
+}
+
+func TestPutItemCondition(t *testing.T) {
+	fake := New("t", named("a"))
+	fake.Keys = map[string][]string{"t": {"name"}}
+
+	_, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName:                           aws.String("t"),
+		Item:                                named("a"),
+		ConditionExpression:                 aws.String("attribute_not_exists(name)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionFailed) || conditionFailed.Item == nil {
+		t.Fatalf("err = %v, want ConditionalCheckFailedException with the stored item", err)
+	}
+
+	if _, err := fake.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("t"), Item: named("a")}); err != nil {
+		t.Fatalf("unconditional PutItem: %v", err)
+	}
+	if got := len(fake.Items("t")); got != 1 {
+		t.Errorf("table holds %d items, want the one replaced", got)
+	}
+}
+
+func TestBatchWriteItemUnprocessed(t *testing.T) {
+	fake := New("t")
+	fake.Unprocessed = func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		if n == 1 {
+			return map[string][]types.WriteRequest{"t": requests["t"][:1]}
+		}
+		return nil
+	}
+
+	requests := []types.WriteRequest{{PutRequest: &types.PutRequest{Item: named("a")}}, {PutRequest: &types.PutRequest{Item: named("b")}}}
+	output, err := fake.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"t": requests}})
+	if err != nil {
+		t.Fatalf("BatchWriteItem: %v", err)
+	}
+	if len(output.UnprocessedItems["t"]) != 1 || len(fake.Items("t")) != 1 {
+		t.Fatalf("unprocessed %d, stored %d, want 1 and 1", len(output.UnprocessedItems["t"]), len(fake.Items("t")))
+	}
+
+	output, err = fake.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{RequestItems: output.UnprocessedItems})
+	if err != nil || len(output.UnprocessedItems) != 0 || len(fake.Items("t")) != 2 {
+		t.Fatalf("retry: err %v, unprocessed %d, stored %d", err, len(output.UnprocessedItems), len(fake.Items("t")))
+	}
+}
+
+func TestUpdateItemExpressions(t *testing.T) {
+	counter := func(values ...string) map[string]types.AttributeValue {
+		item := named("a")
+		for i := 0; i+1 < len(values); i += 2 {
+			item[values[i]] = &types.AttributeValueMemberN{Value: values[i+1]}
+		}
+		return item
+	}
+	n := func(value string) types.AttributeValue { return &types.AttributeValueMemberN{Value: value} }
+
+	tests := []struct {
+		name       string
+		stored     []map[string]types.AttributeValue
+		update     string
+		condition  string
+		values     map[string]types.AttributeValue
+		want       map[string]types.AttributeValue
+		wantFailed bool
+	}{
+		{"ADD creates and increments", []map[string]types.AttributeValue{counter("total", "2")},
+			"ADD #total :one, #day :one", "attribute_exists(#key)",
+			map[string]types.AttributeValue{":one": n("1")}, counter("total", "3", "day", "1"), false},
+		{"ADD on a missing item creates it", nil,
+			"ADD #total :one", "", map[string]types.AttributeValue{":one": n("1")}, counter("total", "1"), false},
+		{"condition on a missing item fails", nil,
+			"ADD #total :one", "attribute_exists(#key)", map[string]types.AttributeValue{":one": n("1")}, nil, true},
+		{"SET and REMOVE", []map[string]types.AttributeValue{counter("total", "2", "day", "1")},
+			"SET #total = :five REMOVE #day", "", map[string]types.AttributeValue{":five": n("5")}, counter("total", "5"), false},
+		{"version matches", []map[string]types.AttributeValue{counter("version", "4")},
+			"ADD #version :one", "#version = :version",
+			map[string]types.AttributeValue{":one": n("1"), ":version": n("4")}, counter("version", "5"), false},
+		{"version changed", []map[string]types.AttributeValue{counter("version", "5")},
+			"ADD #version :one", "#version = :version",
+			map[string]types.AttributeValue{":one": n("1"), ":version": n("4")}, counter("version", "5"), true},
+		{"OR, NOT and parentheses", []map[string]types.AttributeValue{counter("total", "2")},
+			"ADD #total :one", "attribute_exists(#key) AND (attribute_not_exists(#day) OR NOT #total > :one)",
+			map[string]types.AttributeValue{":one": n("1")}, counter("total", "3"), false},
+		{"numbers compare as numbers", []map[string]types.AttributeValue{counter("total", "10")},
+			"ADD #total :one", "#total > :nine",
+			map[string]types.AttributeValue{":one": n("1"), ":nine": n("9")}, counter("total", "11"), false},
+		{"if_not_exists and + on a missing attribute", []map[string]types.AttributeValue{counter()},
+			"SET #total = if_not_exists(#total, :one) + :one", "",
+			map[string]types.AttributeValue{":one": n("1")}, counter("total", "2"), false},
+		{"if_not_exists and - on a stored attribute", []map[string]types.AttributeValue{counter("total", "7")},
+			"SET #total = if_not_exists(#total, :one) - :one", "",
+			map[string]types.AttributeValue{":one": n("1")}, counter("total", "6"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := New("t", tt.stored...)
+			fake.Keys = map[string][]string{"t": {"name"}}
+
+			// Only the names the expressions use, which DynamoDB insists on
+			names := map[string]string{}
+			for token, name := range map[string]string{"#key": "name", "#total": "total", "#day": "day", "#version": "version"} {
+				if strings.Contains(tt.update+" "+tt.condition, token) {
+					names[token] = name
+				}
+			}
+			_, err := fake.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("t"),
+				Key:                       named("a"),
+				UpdateExpression:          aws.String(tt.update),
+				ConditionExpression:       aws.String(tt.condition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: tt.values,
+			})
+			var conditionFailed *types.ConditionalCheckFailedException
+			if failed := errors.As(err, &conditionFailed); failed != tt.wantFailed {
+				t.Fatalf("err = %v, want condition failed %v", err, tt.wantFailed)
+			}
+			if err != nil && !tt.wantFailed {
+				t.Fatalf("UpdateItem: %v", err)
+			}
+
+			items := fake.Items("t")
+			if tt.want == nil {
+				if len(items) != 0 {
+					t.Errorf("items = %v, want none", items)
+				}
+				return
+			}
+			if len(items) != 1 || !reflect.DeepEqual(items[0], tt.want) {
+				t.Errorf("items = %v, want %v", items, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateItemListAppendAndReturnValues(t *testing.T) {
+	s := func(value string) types.AttributeValue { return &types.AttributeValueMemberS{Value: value} }
+	fake := New("t", named("a"))
+	fake.Keys = map[string][]string{"t": {"name"}}
+
+	for i, date := range []string{"2024-03-01", "2024-03-02"} {
+		output, err := fake.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:        aws.String("t"),
+			Key:              named("a"),
+			UpdateExpression: aws.String("SET attempts = if_not_exists(attempts, :one) + :one, dates = list_append(if_not_exists(dates, :empty), :dates)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":one":   &types.AttributeValueMemberN{Value: "1"},
+				":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+				":dates": &types.AttributeValueMemberL{Value: []types.AttributeValue{s(date)}},
+			},
+			ReturnValues: types.ReturnValueUpdatedNew,
+		})
+		if err != nil {
+			t.Fatalf("UpdateItem: %v", err)
+		}
+		if _, ok := output.Attributes["name"]; ok {
+			t.Errorf("returned %v, want only the updated attributes", output.Attributes)
+		}
+		want := &types.AttributeValueMemberN{Value: fmt.Sprint(i + 2)}
+		if got := output.Attributes["attempts"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("attempts = %v, want %v", got, want)
+		}
+	}
+
+	want := &types.AttributeValueMemberL{Value: []types.AttributeValue{s("2024-03-01"), s("2024-03-02")}}
+	if got := fake.Tables["t"][0]["dates"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("dates = %v, want %v", got, want)
+	}
+}
+
+func TestUnusedExpressionAttributes(t *testing.T) {
+	n := func(value string) types.AttributeValue { return &types.AttributeValueMemberN{Value: value} }
+	tests := []struct {
+		name    string
+		names   map[string]string
+		values  map[string]types.AttributeValue
+		wantErr string
+	}{
+		{"every one used", map[string]string{"#key": "name", "#version": "version"}, map[string]types.AttributeValue{":one": n("1")}, ""},
+		{"unused name", map[string]string{"#key": "name", "#version": "version", "#day": "day"}, map[string]types.AttributeValue{":one": n("1")},
+			"Value provided in ExpressionAttributeNames unused in expressions: keys: {#day}"},
+		{"unused value", map[string]string{"#key": "name", "#version": "version"}, map[string]types.AttributeValue{":one": n("1"), ":two": n("2")},
+			"Value provided in ExpressionAttributeValues unused in expressions: keys: {:two}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := New("t", named("a"))
+			fake.Keys = map[string][]string{"t": {"name"}}
+			_, err := fake.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("t"),
+				Key:                       named("a"),
+				UpdateExpression:          aws.String("ADD #version :one"),
+				ConditionExpression:       aws.String("attribute_exists(#key)"),
+				ExpressionAttributeNames:  tt.names,
+				ExpressionAttributeValues: tt.values,
+			})
+
+			var apiErr smithy.APIError
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("UpdateItem: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "ValidationException" || apiErr.ErrorMessage() != tt.wantErr {
+				t.Errorf("err = %v, want a ValidationException %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateItemWithoutKeysOnlyRecords(t *testing.T) {
+	fake := New("t", named("a"))
+	_, err := fake.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName:        aws.String("t"),
+		Key:              named("a"),
+		UpdateExpression: aws.String("SET anything = :unknown"),
+	})
+	if err != nil || fake.Count("UpdateItem") != 1 {
+		t.Fatalf("UpdateItem = %v, %d calls", err, fake.Count("UpdateItem"))
+	}
+	if items := fake.Items("t"); !reflect.DeepEqual(items[0], named("a")) {
+		t.Errorf("item = %v, want it untouched", items[0])
+	}
+}
@@ -0,0 +1,454 @@
+package dynamotest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// The subset of expressions UpdateItem applies: top-level attributes only,
+// SET a = :v, ADD a :n (numbers and string sets) and REMOVE a, under
+// conditions built from attribute_exists, attribute_not_exists, the
+// comparison operators, AND, OR, NOT and parentheses.
+
+type expression struct {
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func (e expression) name(token string) (string, error) {
+	if strings.HasPrefix(token, "#") {
+		name, ok := e.names[token]
+		if !ok {
+			return "", fmt.Errorf("dynamotest: undefined name %s", token)
+		}
+		return name, nil
+	}
+	return token, nil
+}
+
+func (e expression) value(token string) (types.AttributeValue, error) {
+	value, ok := e.values[token]
+	if !ok {
+		return nil, fmt.Errorf("dynamotest: undefined value %s", token)
+	}
+	return value, nil
+}
+
+// checkUsed rejects names and values that none of expressions refer to, as
+// DynamoDB does with a ValidationException
+func checkUsed(names map[string]string, values map[string]types.AttributeValue, expressions ...*string) error {
+	used := make(map[string]bool)
+	for _, expression := range expressions {
+		if expression == nil {
+			continue
+		}
+		for _, token := range tokenize(*expression) {
+			used[token] = true
+		}
+	}
+
+	var unusedNames, unusedValues []string
+	for name := range names {
+		if !used[name] {
+			unusedNames = append(unusedNames, name)
+		}
+	}
+	for value := range values {
+		if !used[value] {
+			unusedValues = append(unusedValues, value)
+		}
+	}
+	switch {
+	case len(unusedNames) > 0:
+		sort.Strings(unusedNames)
+		return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", strings.Join(unusedNames, ", "))
+	case len(unusedValues) > 0:
+		sort.Strings(unusedValues)
+		return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", strings.Join(unusedValues, ", "))
+	}
+	return nil
+}
+
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: fmt.Sprintf(format, args...)}
+}
+
+// tokenize splits an expression into names, values, operators and parentheses
+func tokenize(input string) []string {
+	var tokens []string
+	for i := 0; i < len(input); {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),", c):
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("=<>", c):
+			j := i + 1
+			for j < len(input) && strings.ContainsRune("=<>", rune(input[j])) {
+				j++
+			}
+			tokens = append(tokens, input[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(input) && !unicode.IsSpace(rune(input[j])) && !strings.ContainsRune("(),=<>", rune(input[j])) {
+				j++
+			}
+			tokens = append(tokens, input[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// condition evaluates a condition expression against item
+type condition struct {
+	expression
+	tokens []string
+	item   map[string]types.AttributeValue
+}
+
+func (e expression) check(input string, item map[string]types.AttributeValue) (bool, error) {
+	if strings.TrimSpace(input) == "" {
+		return true, nil
+	}
+	c := &condition{expression: e, tokens: tokenize(input), item: item}
+	ok, err := c.or()
+	if err == nil && len(c.tokens) > 0 {
+		err = fmt.Errorf("dynamotest: unexpected %q in condition %q", c.tokens[0], input)
+	}
+	return ok, err
+}
+
+func (c *condition) next() string {
+	if len(c.tokens) == 0 {
+		return ""
+	}
+	token := c.tokens[0]
+	c.tokens = c.tokens[1:]
+	return token
+}
+
+func (c *condition) peek() string {
+	if len(c.tokens) == 0 {
+		return ""
+	}
+	return c.tokens[0]
+}
+
+func (c *condition) or() (bool, error) {
+	ok, err := c.and()
+	for err == nil && strings.EqualFold(c.peek(), "OR") {
+		c.next()
+		var right bool
+		right, err = c.and()
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (c *condition) and() (bool, error) {
+	ok, err := c.not()
+	for err == nil && strings.EqualFold(c.peek(), "AND") {
+		c.next()
+		var right bool
+		right, err = c.not()
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (c *condition) not() (bool, error) {
+	if strings.EqualFold(c.peek(), "NOT") {
+		c.next()
+		ok, err := c.not()
+		return !ok, err
+	}
+	return c.operand()
+}
+
+func (c *condition) operand() (bool, error) {
+	token := c.next()
+	switch {
+	case token == "(":
+		ok, err := c.or()
+		if err == nil && c.next() != ")" {
+			err = fmt.Errorf("dynamotest: missing ) in condition")
+		}
+		return ok, err
+	case token == "attribute_exists" || token == "attribute_not_exists":
+		if c.next() != "(" {
+			return false, fmt.Errorf("dynamotest: %s without (", token)
+		}
+		name, err := c.name(c.next())
+		if err != nil {
+			return false, err
+		}
+		if c.next() != ")" {
+			return false, fmt.Errorf("dynamotest: %s without )", token)
+		}
+		_, exists := c.item[name]
+		return exists == (token == "attribute_exists"), nil
+	}
+
+	left, err := c.side(token)
+	if err != nil {
+		return false, err
+	}
+	operator := c.next()
+	right, err := c.side(c.next())
+	if err != nil {
+		return false, err
+	}
+	if left == nil || right == nil {
+		return operator == "<>", nil
+	}
+	order, comparable := compare(left, right)
+	switch operator {
+	case "=":
+		return comparable && order == 0, nil
+	case "<>":
+		return !comparable || order != 0, nil
+	case "<":
+		return comparable && order < 0, nil
+	case "<=":
+		return comparable && order <= 0, nil
+	case ">":
+		return comparable && order > 0, nil
+	case ">=":
+		return comparable && order >= 0, nil
+	}
+	return false, fmt.Errorf("dynamotest: unsupported operator %q", operator)
+}
+
+// side resolves one side of a comparison, nil for a missing attribute
+func (c *condition) side(token string) (types.AttributeValue, error) {
+	if strings.HasPrefix(token, ":") {
+		return c.value(token)
+	}
+	name, err := c.name(token)
+	if err != nil {
+		return nil, err
+	}
+	return c.item[name], nil
+}
+
+// compare orders two strings or two numbers, reporting false for anything else
+func compare(left, right types.AttributeValue) (int, bool) {
+	switch l := left.(type) {
+	case *types.AttributeValueMemberS:
+		if r, ok := right.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(l.Value, r.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if r, ok := right.(*types.AttributeValueMemberN); ok {
+			a, errA := strconv.ParseFloat(l.Value, 64)
+			b, errB := strconv.ParseFloat(r.Value, 64)
+			if errA != nil || errB != nil {
+				return 0, false
+			}
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// apply runs an update expression on item in place
+func (e expression) apply(input string, item map[string]types.AttributeValue) error {
+	tokens := tokenize(input)
+	clause := ""
+	for len(tokens) > 0 {
+		switch keyword := strings.ToUpper(tokens[0]); keyword {
+		case "SET", "ADD", "REMOVE":
+			clause = keyword
+			tokens = tokens[1:]
+			continue
+		case ",":
+			tokens = tokens[1:]
+			continue
+		}
+
+		name, err := e.name(tokens[0])
+		if err != nil {
+			return err
+		}
+		switch clause {
+		case "REMOVE":
+			delete(item, name)
+			tokens = tokens[1:]
+		case "SET":
+			if len(tokens) < 3 || tokens[1] != "=" {
+				return fmt.Errorf("dynamotest: unsupported SET in %q", input)
+			}
+			value, rest, err := e.sum(tokens[2:], item)
+			if err != nil {
+				return err
+			}
+			item[name] = value
+			tokens = rest
+		case "ADD":
+			if len(tokens) < 2 {
+				return fmt.Errorf("dynamotest: ADD without a value in %q", input)
+			}
+			value, err := e.value(tokens[1])
+			if err != nil {
+				return err
+			}
+			if item[name], err = add(item[name], value); err != nil {
+				return err
+			}
+			tokens = tokens[2:]
+		default:
+			return fmt.Errorf("dynamotest: unsupported update expression %q", input)
+		}
+	}
+	return nil
+}
+
+// sum evaluates the value of a SET action, an operand optionally followed by
+// + or - and another operand, returning the tokens after it
+func (e expression) sum(tokens []string, item map[string]types.AttributeValue) (types.AttributeValue, []string, error) {
+	left, tokens, err := e.operand(tokens, item)
+	if err != nil || len(tokens) == 0 || (tokens[0] != "+" && tokens[0] != "-") {
+		return left, tokens, err
+	}
+	sign := tokens[0]
+	right, tokens, err := e.operand(tokens[1:], item)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sign == "-" {
+		n, ok := right.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, nil, fmt.Errorf("dynamotest: - of %T", right)
+		}
+		right = &types.AttributeValueMemberN{Value: "-" + n.Value}
+	}
+	if _, ok := left.(*types.AttributeValueMemberN); !ok {
+		return nil, nil, fmt.Errorf("dynamotest: %s on %T", sign, left)
+	}
+	value, err := add(left, right)
+	return value, tokens, err
+}
+
+// operand evaluates a value, an attribute, if_not_exists or list_append
+func (e expression) operand(tokens []string, item map[string]types.AttributeValue) (types.AttributeValue, []string, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("dynamotest: missing operand")
+	}
+	function := tokens[0]
+	if function != "if_not_exists" && function != "list_append" {
+		if strings.HasPrefix(function, ":") {
+			value, err := e.value(function)
+			return value, tokens[1:], err
+		}
+		name, err := e.name(function)
+		if err != nil {
+			return nil, nil, err
+		}
+		if item[name] == nil {
+			return nil, nil, fmt.Errorf("dynamotest: attribute %s does not exist", name)
+		}
+		return item[name], tokens[1:], nil
+	}
+
+	if len(tokens) < 2 || tokens[1] != "(" {
+		return nil, nil, fmt.Errorf("dynamotest: %s without arguments", function)
+	}
+	var first types.AttributeValue
+	tokens = tokens[2:]
+	if function == "if_not_exists" && len(tokens) > 0 {
+		name, err := e.name(tokens[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		first, tokens = item[name], tokens[1:]
+	} else {
+		var err error
+		if first, tokens, err = e.operand(tokens, item); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(tokens) == 0 || tokens[0] != "," {
+		return nil, nil, fmt.Errorf("dynamotest: %s needs two arguments", function)
+	}
+	second, tokens, err := e.operand(tokens[1:], item)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 || tokens[0] != ")" {
+		return nil, nil, fmt.Errorf("dynamotest: unclosed %s", function)
+	}
+	tokens = tokens[1:]
+
+	if function == "if_not_exists" {
+		if first != nil {
+			return first, tokens, nil
+		}
+		return second, tokens, nil
+	}
+	head, ok := first.(*types.AttributeValueMemberL)
+	tail, ok2 := second.(*types.AttributeValueMemberL)
+	if !ok || !ok2 {
+		return nil, nil, fmt.Errorf("dynamotest: list_append of %T and %T", first, second)
+	}
+	list := append(append([]types.AttributeValue{}, head.Value...), tail.Value...)
+	return &types.AttributeValueMemberL{Value: list}, tokens, nil
+}
+
+func add(current, value types.AttributeValue) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberN:
+		sum, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("dynamotest: only whole numbers can be added, got %q", v.Value)
+		}
+		if n, ok := current.(*types.AttributeValueMemberN); ok {
+			existing, err := strconv.Atoi(n.Value)
+			if err != nil {
+				return nil, fmt.Errorf("dynamotest: only whole numbers can be added to, got %q", n.Value)
+			}
+			sum += existing
+		} else if current != nil {
+			return nil, fmt.Errorf("dynamotest: ADD of a number to %T", current)
+		}
+		return &types.AttributeValueMemberN{Value: strconv.Itoa(sum)}, nil
+	case *types.AttributeValueMemberSS:
+		set := &types.AttributeValueMemberSS{}
+		if s, ok := current.(*types.AttributeValueMemberSS); ok {
+			set.Value = append(set.Value, s.Value...)
+		} else if current != nil {
+			return nil, fmt.Errorf("dynamotest: ADD of a string set to %T", current)
+		}
+		for _, element := range v.Value {
+			if !containsString(set.Value, element) {
+				set.Value = append(set.Value, element)
+			}
+		}
+		return set, nil
+	}
+	return nil, fmt.Errorf("dynamotest: ADD of %T is not supported", value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+//go:build integration
+
+package dynamotest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+)
+
+// Schema is the key of a table created in DynamoDB Local; every key attribute
+// is a string
+type Schema struct {
+	Table string
+	Hash  string
+	// Range is "" for tables with a hash key only
+	Range string
+}
+
+// QuestionsSchema and StudiesSchema match the deployed tables
+var (
+	QuestionsSchema = Schema{Table: "veet_code_questions_table", Hash: "user_id", Range: "question_name"}
+	StudiesSchema   = Schema{Table: "studies_table", Hash: "study_theme", Range: "study_id"}
+)
+
+// tableWait bounds how long a table may take to be created or deleted
+const tableWait = 30 * time.Second
+
+// Local connects to the DynamoDB at DYNAMODB_ENDPOINT, skipping the test when
+// it is unset, and creates the tables of schemas, replacing any left over by
+// an earlier run. The tables are deleted when the test ends.
+func Local(t testing.TB, schemas ...Schema) *dynamodb.Client {
+	t.Helper()
+	if os.Getenv(awsutil.DynamoEndpointEnv) == "" {
+		t.Skipf("%s is not set", awsutil.DynamoEndpointEnv)
+	}
+
+	ctx := context.Background()
+	client, err := awsutil.NewDynamoClient(ctx)
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB client: %v", err)
+	}
+
+	for _, schema := range schemas {
+		if err := deleteTable(ctx, client, schema.Table); err != nil {
+			t.Fatalf("failed to delete leftover table %s: %v", schema.Table, err)
+		}
+		if err := createTable(ctx, client, schema); err != nil {
+			t.Fatalf("failed to create table %s: %v", schema.Table, err)
+		}
+		t.Cleanup(func() {
+			if err := deleteTable(context.Background(), client, schema.Table); err != nil {
+				t.Errorf("failed to delete table %s: %v", schema.Table, err)
+			}
+		})
+	}
+	return client
+}
+
+func createTable(ctx context.Context, client *dynamodb.Client, schema Schema) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(schema.Table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(schema.Hash), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(schema.Hash), KeyType: types.KeyTypeHash},
+		},
+	}
+	if schema.Range != "" {
+		input.AttributeDefinitions = append(input.AttributeDefinitions,
+			types.AttributeDefinition{AttributeName: aws.String(schema.Range), AttributeType: types.ScalarAttributeTypeS})
+		input.KeySchema = append(input.KeySchema,
+			types.KeySchemaElement{AttributeName: aws.String(schema.Range), KeyType: types.KeyTypeRange})
+	}
+
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return err
+	}
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(schema.Table)}, tableWait)
+}
+
+// deleteTable deletes table, doing nothing when it doesn't exist
+func deleteTable(ctx context.Context, client *dynamodb.Client, table string) error {
+	_, err := client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(table)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	waiter := dynamodb.NewTableNotExistsWaiter(client)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, tableWait)
+}
@@ -0,0 +1,143 @@
+package goal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// TableEnv overrides the table holding the daily goal
+const TableEnv = "GOALS_TABLE_NAME"
+
+const DefaultTable = "veet_code_goals_table"
+
+// KeyAttribute is the table's key; each tenant table holds a single goal
+// under goalID, or one per user when data is per user
+const KeyAttribute = "goal_id"
+
+const goalID = "daily"
+
+// DateLayout is the format of the goal's dates, in requests and in storage
+const DateLayout = "2006-01-02"
+
+// WindowDays is how many past days the met percentage looks at
+const WindowDays = 30
+
+// Goal is the daily target for solved questions and study minutes. A zero
+// target means there is no goal for it.
+type Goal struct {
+	QuestionsPerDay int `json:"questionsPerDay" dynamodbav:"questions_per_day"`
+	MinutesPerDay   int `json:"minutesPerDay" dynamodbav:"minutes_per_day"`
+	// StartDate is the day the goal was set; days before it are not judged
+	StartDate string `json:"startDate" dynamodbav:"start_date"`
+	// EndDate is optional; days after it are not judged
+	EndDate string `json:"endDate,omitempty" dynamodbav:"end_date,omitempty"`
+}
+
+func key(ctx context.Context) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		KeyAttribute: &types.AttributeValueMemberS{Value: user.Key(ctx, goalID)},
+	}
+}
+
+// Load reads the goal of the tenant in ctx, reporting false when none is set
+func Load(ctx context.Context, client awsutil.DynamoAPI, table string) (Goal, bool, error) {
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tenant.Table(ctx, table)),
+		Key:       key(ctx),
+	})
+	if err != nil {
+		return Goal{}, false, fmt.Errorf("failed to get goal item: %w", err)
+	}
+	if output.Item == nil {
+		return Goal{}, false, nil
+	}
+
+	var g Goal
+	if err := attributevalue.UnmarshalMap(output.Item, &g); err != nil {
+		return Goal{}, false, fmt.Errorf("failed to unmarshal goal item: %w", err)
+	}
+	return g, true, nil
+}
+
+// Save replaces the goal of the tenant in ctx
+func Save(ctx context.Context, client awsutil.DynamoAPI, table string, g Goal) error {
+	item, err := attributevalue.MarshalMap(g)
+	if err != nil {
+		return fmt.Errorf("failed to marshal goal item: %w", err)
+	}
+	item[KeyAttribute] = key(ctx)[KeyAttribute]
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tenant.Table(ctx, table)),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put goal item: %w", err)
+	}
+	return nil
+}
+
+// Progress compares daily amounts with one target of the goal
+type Progress struct {
+	Target   int  `json:"target"`
+	Today    int  `json:"today"`
+	TodayMet bool `json:"todayMet"`
+	// DaysJudged counts the completed days of the last WindowDays that fall
+	// within the goal; DaysMetPercentage is the share of them meeting it
+	DaysJudged        int     `json:"daysJudged"`
+	DaysMetPercentage float64 `json:"daysMetPercentage"`
+	// CumulativeSurplus sums amount minus target over every completed day of
+	// the goal; negative values are a deficit
+	CumulativeSurplus int    `json:"cumulativeSurplus"`
+	EndDate           string `json:"endDate,omitempty"`
+}
+
+// Track computes the progress of perDay, keyed by calendar day at UTC
+// midnight, against target. today is the current calendar day in the same
+// form; it is still in progress, so it is reported but not judged. It
+// returns nil without a target.
+func (g Goal) Track(target int, perDay map[time.Time]int, today time.Time) *Progress {
+	if target <= 0 {
+		return nil
+	}
+
+	progress := &Progress{Target: target, Today: perDay[today], EndDate: g.EndDate}
+	progress.TodayMet = progress.Today >= target
+
+	start, err := time.Parse(DateLayout, g.StartDate)
+	if err != nil {
+		start = today
+	}
+	last := today.AddDate(0, 0, -1)
+	if end, err := time.Parse(DateLayout, g.EndDate); err == nil && end.Before(last) {
+		last = end
+	}
+	windowStart := today.AddDate(0, 0, -WindowDays)
+
+	met := 0
+	for day := start; !day.After(last); day = day.AddDate(0, 0, 1) {
+		progress.CumulativeSurplus += perDay[day] - target
+		if day.Before(windowStart) {
+			continue
+		}
+		progress.DaysJudged++
+		if perDay[day] >= target {
+			met++
+		}
+	}
+	if progress.DaysJudged > 0 {
+		progress.DaysMetPercentage = math.Round(float64(met)/float64(progress.DaysJudged)*1000) / 10
+	}
+	return progress
+}
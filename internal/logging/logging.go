@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// EnvLevel sets the minimum level logged: debug, info, warn or error. Raw
+// events and items are only logged at debug.
+const EnvLevel = "LOG_LEVEL"
+
+var base *slog.Logger
+
+// Importing the package is enough to switch slog, and the log package
+// through it, to JSON lines on stdout
+func init() {
+	base = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: levelFromEnv()}))
+	slog.SetDefault(base)
+}
+
+func levelFromEnv() slog.Level {
+	switch strings.ToLower(os.Getenv(EnvLevel)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithRequestIDs resets the default logger at the start of every invocation
+// so each line carries the Lambda request ID and, for API Gateway events, the
+// API Gateway request ID. Warm containers reuse the logger, which is why
+// anything added to it during an invocation, like the tenant, is dropped here.
+func WithRequestIDs[E, R any](handler func(context.Context, E) (R, error)) func(context.Context, E) (R, error) {
+	return func(ctx context.Context, event E) (R, error) {
+		logger := base
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			logger = logger.With("requestId", lc.AwsRequestID)
+		}
+		if request, ok := any(event).(events.APIGatewayProxyRequest); ok && request.RequestContext.RequestID != "" {
+			logger = logger.With("apiRequestId", request.RequestContext.RequestID)
+		}
+		slog.SetDefault(logger)
+		return handler(ctx, event)
+	}
+}
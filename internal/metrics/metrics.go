@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// Namespace groups the metrics of every lambda in CloudWatch
+const Namespace = "VeetCode"
+
+// DimensionName is the dimension every metric is reported under
+const DimensionName = "FunctionName"
+
+// TenantDimension is added to the metrics of requests made for a tenant
+const TenantDimension = "Tenant"
+
+type contextKey struct{}
+
+// WithTenant returns a context whose metrics are also reported per tenant.
+// The tenant package calls it, as it cannot be imported from here.
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// Metric names shared across the lambdas
+const (
+	QuestionsWritten  = "QuestionsWritten"
+	StudiesWritten    = "StudiesWritten"
+	ScanItemCount     = "ScanItemCount"
+	ScanDurationMs    = "ScanDurationMs"
+	StatsGenerationMs = "StatsGenerationMs"
+	Errors            = "Errors"
+)
+
+const (
+	unitCount        = "Count"
+	unitMilliseconds = "Milliseconds"
+)
+
+// Metric is a single value of an EMF line
+type Metric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+func Count(name string, value int) Metric {
+	return Metric{Name: name, Unit: unitCount, Value: float64(value)}
+}
+
+func Duration(name string, d time.Duration) Metric {
+	return Metric{Name: name, Unit: unitMilliseconds, Value: float64(d.Microseconds()) / 1000}
+}
+
+// Emit writes the metrics as one CloudWatch Embedded Metric Format line on
+// stdout, which Lambda ships to CloudWatch Logs. Outside Lambda the function
+// name is "local" and the line is just printed. With a tenant in ctx they are
+// reported both per function and per function and tenant.
+func Emit(ctx context.Context, metrics ...Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	functionName := lambdacontext.FunctionName
+	if functionName == "" {
+		functionName = "local"
+	}
+
+	definitions := make([]map[string]string, 0, len(metrics))
+	line := map[string]any{DimensionName: functionName}
+	dimensions := [][]string{{DimensionName}}
+	if tenant, _ := ctx.Value(contextKey{}).(string); tenant != "" {
+		line[TenantDimension] = tenant
+		dimensions = append(dimensions, []string{DimensionName, TenantDimension})
+	}
+	for _, metric := range metrics {
+		definitions = append(definitions, map[string]string{"Name": metric.Name, "Unit": metric.Unit})
+		line[metric.Name] = metric.Value
+	}
+	line["_aws"] = map[string]any{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  Namespace,
+			"Dimensions": dimensions,
+			"Metrics":    definitions,
+		}},
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		slog.Warn("Failed to encode metrics", "error", err)
+		return
+	}
+	fmt.Fprintln(os.Stdout, string(encoded))
+}
+
+// Scan reports how many items a full scan read and how long it took
+func Scan(ctx context.Context, start time.Time, items int) {
+	Emit(ctx, Count(ScanItemCount, items), Duration(ScanDurationMs, time.Since(start)))
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// emitted runs Emit and decodes the line it printed
+func emitted(t *testing.T, ctx context.Context, metrics ...Metric) map[string]any {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	Emit(ctx, metrics...)
+	os.Stdout = stdout
+	writer.Close()
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	var line map[string]any
+	if err := json.Unmarshal(output, &line); err != nil {
+		t.Fatalf("unmarshal %q: %v", output, err)
+	}
+	return line
+}
+
+func TestEmitDimensions(t *testing.T) {
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		wantTenant     any
+		wantDimensions []any
+	}{
+		{"without a tenant", context.Background(), nil,
+			[]any{[]any{DimensionName}}},
+		{"with a tenant", WithTenant(context.Background(), "alice"), "alice",
+			[]any{[]any{DimensionName}, []any{DimensionName, TenantDimension}}},
+		{"with the single-tenant name", WithTenant(context.Background(), ""), nil,
+			[]any{[]any{DimensionName}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := emitted(t, tt.ctx, Count(Errors, 1))
+			if line[Errors] != 1.0 || line[DimensionName] != "local" {
+				t.Errorf("line = %v, want one error for the local function", line)
+			}
+			if line[TenantDimension] != tt.wantTenant {
+				t.Errorf("%s = %v, want %v", TenantDimension, line[TenantDimension], tt.wantTenant)
+			}
+			directives := line["_aws"].(map[string]any)["CloudWatchMetrics"].([]any)[0].(map[string]any)
+			if !reflect.DeepEqual(directives["Dimensions"], tt.wantDimensions) {
+				t.Errorf("dimensions = %v, want %v", directives["Dimensions"], tt.wantDimensions)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WarningHeader lists the limits a successful request came close to
+const WarningHeader = "X-Quota-Warning"
+
+// warningRatio is how full a limit must be before requests carry a warning
+const warningRatio = 0.8
+
+// Limit is a hard limit that is enforced and warned about from the same numbers
+type Limit struct {
+	Name string
+	Max  int
+}
+
+// BatchSize caps the number of items in one bulk add request
+var BatchSize = Limit{Name: "batch-size", Max: 500}
+
+// ResponseSize stays below the 6 MB Lambda response payload limit, leaving
+// room for headers
+var ResponseSize = Limit{Name: "response-size", Max: 6_000_000}
+
+// Exceeded reports whether used goes over the limit
+func (l Limit) Exceeded(used int) bool {
+	return used > l.Max
+}
+
+// Warning describes usage within 20% of the limit, e.g. "batch-size 410/500",
+// and is empty otherwise
+func (l Limit) Warning(used int) string {
+	if l.Exceeded(used) || float64(used) < warningRatio*float64(l.Max) {
+		return ""
+	}
+	return fmt.Sprintf("%s %d/%d", l.Name, used, l.Max)
+}
+
+// Warnings collects the warnings of a single request
+type Warnings []string
+
+// Check records a warning when used is close to the limit
+func (w *Warnings) Check(l Limit, used int) {
+	if warning := l.Warning(used); warning != "" {
+		*w = append(*w, warning)
+	}
+}
+
+// Apply adds the warnings to the X-Quota-Warning header, keeping any already set
+func (w Warnings) Apply(headers map[string]string) {
+	if len(w) == 0 {
+		return
+	}
+	all := w
+	if existing := headers[WarningHeader]; existing != "" {
+		all = append(Warnings{existing}, w...)
+	}
+	headers[WarningHeader] = strings.Join(all, ", ")
+}
@@ -0,0 +1,64 @@
+package quota
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWarning(t *testing.T) {
+	for _, limit := range []Limit{BatchSize, ResponseSize} {
+		// The first usage warned about is 80% of the limit
+		threshold := limit.Max * 4 / 5
+		tests := []struct {
+			name string
+			used int
+			want string
+		}{
+			{"well below", 0, ""},
+			{"just below the threshold", threshold - 1, ""},
+			{"at the threshold", threshold, fmt.Sprintf("%s %d/%d", limit.Name, threshold, limit.Max)},
+			{"just above the threshold", threshold + 1, fmt.Sprintf("%s %d/%d", limit.Name, threshold+1, limit.Max)},
+			{"at the limit", limit.Max, fmt.Sprintf("%s %d/%d", limit.Name, limit.Max, limit.Max)},
+			// Requests over the limit are rejected rather than warned about
+			{"just above the limit", limit.Max + 1, ""},
+		}
+		for _, tt := range tests {
+			t.Run(limit.Name+" "+tt.name, func(t *testing.T) {
+				if got := limit.Warning(tt.used); got != tt.want {
+					t.Errorf("Warning(%d) = %q, want %q", tt.used, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestBatchSizeWarning(t *testing.T) {
+	if got, want := BatchSize.Warning(410), "batch-size 410/500"; got != want {
+		t.Errorf("Warning(410) = %q, want %q", got, want)
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	limit := Limit{Name: "test", Max: 10}
+	if limit.Exceeded(10) || !limit.Exceeded(11) {
+		t.Errorf("Exceeded(10), Exceeded(11) = %v, %v, want false, true", limit.Exceeded(10), limit.Exceeded(11))
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	var warnings Warnings
+	warnings.Check(BatchSize, 399)
+	headers := map[string]string{}
+	warnings.Apply(headers)
+	if _, ok := headers[WarningHeader]; ok {
+		t.Errorf("headers = %v, want no warning below the threshold", headers)
+	}
+
+	warnings.Check(BatchSize, 450)
+	warnings.Check(ResponseSize, 100)
+	headers = map[string]string{WarningHeader: "response-size 5000000/6000000"}
+	warnings.Apply(headers)
+	if want := "response-size 5000000/6000000, batch-size 450/500"; headers[WarningHeader] != want {
+		t.Errorf("%s = %q, want %q", WarningHeader, headers[WarningHeader], want)
+	}
+}
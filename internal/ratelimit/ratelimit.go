@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvWritesPerSecond holds the write budget for bulk imports. When it is unset
+// writes are not paced at all.
+const EnvWritesPerSecond = "WRITES_PER_SECOND"
+
+// EnvMaxWritesPerSecond caps the budget a request may ask for.
+const EnvMaxWritesPerSecond = "MAX_WRITES_PER_SECOND"
+
+// DefaultMaxWritesPerSecond is the cap when MAX_WRITES_PER_SECOND is unset.
+const DefaultMaxWritesPerSecond = 100
+
+// Clock abstracts time so the limiter can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type realClock struct{}
+
+// SystemClock is the wall clock, for callers that sleep between retries
+// themselves.
+var SystemClock Clock = realClock{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+const (
+	// BaseBackoff is the wait before the first retry.
+	BaseBackoff = 100 * time.Millisecond
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff = 5 * time.Second
+)
+
+// Backoff returns how long to wait before retry number attempt (from 1):
+// BaseBackoff doubled per attempt and capped at MaxBackoff, of which a random
+// half is jitter so retries of concurrent imports don't line up.
+func Backoff(attempt int) time.Duration {
+	backoff := MaxBackoff
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt < 32 {
+		backoff = min(BaseBackoff<<(attempt-1), MaxBackoff)
+	}
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// Limiter is a token bucket that paces writes to a writes-per-second budget.
+// The effective rate is halved every time DynamoDB pushes back and climbs back
+// towards the budget in small steps after each successful write.
+type Limiter struct {
+	clock   Clock
+	maxRate float64
+	minRate float64
+	rate    float64
+	tokens  float64
+	last    time.Time
+}
+
+// New returns a limiter allowing writesPerSecond writes per second.
+func New(writesPerSecond float64) *Limiter {
+	return NewWithClock(writesPerSecond, realClock{})
+}
+
+// NewWithClock is like New but reads time from the given clock.
+func NewWithClock(writesPerSecond float64, clock Clock) *Limiter {
+	return &Limiter{
+		clock:   clock,
+		maxRate: writesPerSecond,
+		minRate: writesPerSecond / 16,
+		rate:    writesPerSecond,
+		tokens:  writesPerSecond,
+		last:    clock.Now(),
+	}
+}
+
+// FromEnv builds a limiter from WRITES_PER_SECOND, returning nil when the
+// variable is unset so callers keep writing at full speed.
+func FromEnv() (*Limiter, error) {
+	value := os.Getenv(EnvWritesPerSecond)
+	if value == "" {
+		return nil, nil
+	}
+
+	writesPerSecond, err := strconv.ParseFloat(value, 64)
+	if err != nil || writesPerSecond <= 0 {
+		return nil, fmt.Errorf("invalid %s %q", EnvWritesPerSecond, value)
+	}
+	return New(writesPerSecond), nil
+}
+
+// MaxFromEnv reads MAX_WRITES_PER_SECOND, falling back to
+// DefaultMaxWritesPerSecond.
+func MaxFromEnv() (float64, error) {
+	value := os.Getenv(EnvMaxWritesPerSecond)
+	if value == "" {
+		return DefaultMaxWritesPerSecond, nil
+	}
+
+	maxWritesPerSecond, err := strconv.ParseFloat(value, 64)
+	if err != nil || maxWritesPerSecond <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", EnvMaxWritesPerSecond, value)
+	}
+	return maxWritesPerSecond, nil
+}
+
+// ForRequest returns the limiter for an import that asked for requested writes
+// per second, clamped to maxWritesPerSecond, or fallback when it asked for
+// none. requested must be positive.
+func ForRequest(requested *float64, maxWritesPerSecond float64, fallback *Limiter, clock Clock) *Limiter {
+	if requested == nil {
+		return fallback
+	}
+
+	writesPerSecond := *requested
+	if writesPerSecond > maxWritesPerSecond {
+		slog.Info("Clamped requested write rate", "requested", writesPerSecond, "max", maxWritesPerSecond)
+		writesPerSecond = maxWritesPerSecond
+	}
+	return NewWithClock(writesPerSecond, clock)
+}
+
+// Wait blocks until n writes fit in the budget. A nil limiter never blocks.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.refill()
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return nil
+	}
+
+	// Borrow from the future and sleep until the debt is repaid
+	deficit := -l.tokens / l.rate
+	if err := l.clock.Sleep(ctx, time.Duration(deficit*float64(time.Second))); err != nil {
+		return err
+	}
+	l.refill()
+	return nil
+}
+
+// Throttled halves the effective rate after a throttling error or unprocessed items.
+func (l *Limiter) Throttled() {
+	if l == nil {
+		return
+	}
+
+	l.refill()
+	l.rate /= 2
+	if l.rate < l.minRate {
+		l.rate = l.minRate
+	}
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+}
+
+// Succeeded recovers a tenth of the budget after a fully processed write.
+func (l *Limiter) Succeeded() {
+	if l == nil || l.rate >= l.maxRate {
+		return
+	}
+
+	l.refill()
+	l.rate += l.maxRate / 10
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+}
+
+// Rate returns the current effective writes per second, or 0 when unlimited.
+func (l *Limiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	return l.rate
+}
+
+func (l *Limiter) refill() {
+	now := l.clock.Now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+
+	if elapsed <= 0 {
+		return
+	}
+	l.tokens += elapsed * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+}
@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when slept on
+type fakeClock struct {
+	now    time.Time
+	slept  []time.Duration
+	cancel bool
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func (c *fakeClock) total() time.Duration {
+	var total time.Duration
+	for _, d := range c.slept {
+		total += d
+	}
+	return total
+}
+
+func TestWaitPacesToBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      float64
+		writes    []int
+		wantSleep time.Duration
+	}{
+		{"within the initial burst", 10, []int{5, 5}, 0},
+		{"one over the burst", 10, []int{10, 1}, 100 * time.Millisecond},
+		{"a chunk of 25 at 5 per second", 5, []int{25}, 4 * time.Second},
+		{"two chunks at 25 per second", 25, []int{25, 25}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			limiter := NewWithClock(tt.rate, clock)
+			for _, n := range tt.writes {
+				if err := limiter.Wait(context.Background(), n); err != nil {
+					t.Fatalf("Wait(%d): %v", n, err)
+				}
+			}
+			if got := clock.total(); got != tt.wantSleep {
+				t.Errorf("slept %v, want %v", got, tt.wantSleep)
+			}
+		})
+	}
+}
+
+func TestWaitHonoursContext(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewWithClock(1, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.Wait(ctx, 5); err != context.Canceled {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+func TestThrottledAndSucceeded(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewWithClock(16, clock)
+
+	steps := []struct {
+		step     func()
+		wantRate float64
+	}{
+		{limiter.Throttled, 8},
+		{limiter.Throttled, 4},
+		{limiter.Throttled, 2},
+		{limiter.Throttled, 1},
+		// Floored at a sixteenth of the budget
+		{limiter.Throttled, 1},
+		{limiter.Succeeded, 2.6},
+		{limiter.Succeeded, 4.2},
+	}
+	for i, s := range steps {
+		s.step()
+		if got := limiter.Rate(); got < s.wantRate-1e-9 || got > s.wantRate+1e-9 {
+			t.Fatalf("step %d: rate = %v, want %v", i, got, s.wantRate)
+		}
+	}
+
+	for range 20 {
+		limiter.Succeeded()
+	}
+	if got := limiter.Rate(); got != 16 {
+		t.Errorf("rate = %v, want it capped at the budget of 16", got)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var limiter *Limiter
+	if err := limiter.Wait(context.Background(), 100); err != nil {
+		t.Errorf("Wait = %v, want nil", err)
+	}
+	limiter.Throttled()
+	limiter.Succeeded()
+	if got := limiter.Rate(); got != 0 {
+		t.Errorf("Rate = %v, want 0", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, BaseBackoff},
+		{1, BaseBackoff},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{6, 3200 * time.Millisecond},
+		{7, MaxBackoff},
+		{50, MaxBackoff},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			got := Backoff(tt.attempt)
+			if got < tt.ceiling/2 || got > tt.ceiling {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantRate float64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"12.5", 12.5, false},
+		{"0", 0, true},
+		{"-3", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvWritesPerSecond, tt.value)
+		limiter, err := FromEnv()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("FromEnv(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got := limiter.Rate(); got != tt.wantRate {
+			t.Errorf("FromEnv(%q) rate = %v, want %v", tt.value, got, tt.wantRate)
+		}
+	}
+}
+
+func TestMaxFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"", DefaultMaxWritesPerSecond, false},
+		{"250", 250, false},
+		{"0", 0, true},
+		{"lots", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvMaxWritesPerSecond, tt.value)
+		got, err := MaxFromEnv()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("MaxFromEnv(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestForRequest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	fallback := NewWithClock(10, clock)
+	requested := func(value float64) *float64 { return &value }
+
+	if got := ForRequest(nil, 50, fallback, clock); got != fallback {
+		t.Errorf("ForRequest(nil) = %p, want the fallback %p", got, fallback)
+	}
+	if got := ForRequest(nil, 50, nil, clock); got != nil {
+		t.Errorf("ForRequest(nil) without a fallback = %v, want no pacing", got)
+	}
+	if got := ForRequest(requested(20), 50, fallback, clock).Rate(); got != 20 {
+		t.Errorf("rate = %v, want the requested 20", got)
+	}
+	if got := ForRequest(requested(500), 50, fallback, clock).Rate(); got != 50 {
+		t.Errorf("rate = %v, want 500 clamped to 50", got)
+	}
+}
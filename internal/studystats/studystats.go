@@ -0,0 +1,139 @@
+package studystats
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/studytime"
+)
+
+// Study is the part of a studies table item the statistics read
+type Study struct {
+	StudyTheme     string            `dynamodbav:"study_theme"`
+	StudyDate      string            `dynamodbav:"study_date"`
+	MinutesOfStudy studytime.Minutes `dynamodbav:"minutes_of_study"`
+}
+
+// Statistics is what the study statistics endpoint and the dashboard report
+// for a list of studies
+type Statistics struct {
+	StudiesPerDay       map[string]int `json:"studiesPerDay"`
+	StudiesPerTheme     map[string]int `json:"studiesPerTheme"`
+	TotalMinutesStudied int            `json:"totalMinutesStudied"`
+	TotalMinutesPerDay  map[string]int `json:"totalMinutesPerDay"`
+	// Averages are rounded to one decimal and 0 without any studies
+	AverageMinutesPerSession    float64 `json:"averageMinutesPerSession"`
+	AverageSessionsPerActiveDay float64 `json:"averageSessionsPerActiveDay"`
+	// MostStudiedTheme and BusiestDay have the most minutes; ties go to the
+	// alphabetically first theme and the earliest day. Both are empty
+	// without studies.
+	MostStudiedTheme string `json:"mostStudiedTheme"`
+	BusiestDay       string `json:"busiestDay"`
+	// GoalProgress is omitted when no minutes goal is set. It always looks at
+	// the whole history, whatever the date range.
+	GoalProgress *goal.Progress `json:"goalProgress,omitempty"`
+	// MergedKeys lists the raw theme spellings folded into each canonical theme
+	MergedKeys map[string][]string `json:"mergedKeys"`
+}
+
+// Generate counts every study, folding its theme with themeAliases first
+func Generate(studies []Study, themeAliases map[string]string) Statistics {
+	stats := Statistics{
+		StudiesPerDay:      make(map[string]int),
+		StudiesPerTheme:    make(map[string]int),
+		TotalMinutesPerDay: make(map[string]int),
+	}
+
+	themes := alias.NewCanonicalizer(themeAliases)
+	minutesPerTheme := make(map[string]int)
+	for _, study := range studies {
+		study.StudyTheme = themes.Canonical(study.StudyTheme)
+		stats.StudiesPerDay[study.StudyDate]++
+		stats.StudiesPerTheme[study.StudyTheme]++
+
+		stats.TotalMinutesStudied += int(study.MinutesOfStudy)
+
+		stats.TotalMinutesPerDay[study.StudyDate] += int(study.MinutesOfStudy)
+		minutesPerTheme[study.StudyTheme] += int(study.MinutesOfStudy)
+	}
+
+	stats.MostStudiedTheme = mostMinutes(minutesPerTheme, func(a, b string) bool { return a < b })
+	stats.BusiestDay = mostMinutes(stats.TotalMinutesPerDay, earlierDate)
+
+	if len(studies) > 0 {
+		stats.AverageMinutesPerSession = roundToTenth(float64(stats.TotalMinutesStudied) / float64(len(studies)))
+		stats.AverageSessionsPerActiveDay = roundToTenth(float64(len(studies)) / float64(len(stats.StudiesPerDay)))
+	}
+
+	stats.MergedKeys = themes.MergedKeys()
+	return stats
+}
+
+// Filter keeps the studies within dateRange
+func Filter(studies []Study, dateRange calendar.DateRange) []Study {
+	filtered := []Study{}
+	for _, study := range studies {
+		if dateRange.ContainsDate(study.StudyDate) {
+			filtered = append(filtered, study)
+		}
+	}
+	return filtered
+}
+
+// TrackGoal measures the minutes of studies per day against the daily goal
+// in goalsTable. A goal that can't be read is logged and reported as nil,
+// like a goal that isn't set.
+func TrackGoal(ctx context.Context, client awsutil.DynamoAPI, goalsTable string, studies []Study) *goal.Progress {
+	g, ok, err := goal.Load(ctx, client, goalsTable)
+	if err != nil {
+		slog.Warn("Failed to load goal, omitting progress", "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	minutesPerDay := make(map[time.Time]int)
+	for _, study := range studies {
+		if day, err := calendar.ParseDate(study.StudyDate); err == nil {
+			minutesPerDay[day] += int(study.MinutesOfStudy)
+		}
+	}
+	return g.Track(g.MinutesPerDay, minutesPerDay, calendar.Today(time.Now()))
+}
+
+// mostMinutes returns the key with the most minutes, breaking ties with
+// before, or "" for an empty map
+func mostMinutes(minutes map[string]int, before func(a, b string) bool) string {
+	best := ""
+	for key, value := range minutes {
+		if best == "" || value > minutes[best] || (value == minutes[best] && before(key, best)) {
+			best = key
+		}
+	}
+	return best
+}
+
+// earlierDate orders dates chronologically, with unparseable ones after the
+// rest in text order
+func earlierDate(a, b string) bool {
+	dateA, errA := calendar.ParseDate(a)
+	dateB, errB := calendar.ParseDate(b)
+	if errA != nil || errB != nil {
+		if errA != nil && errB != nil {
+			return a < b
+		}
+		return errA == nil
+	}
+	return dateA.Before(dateB)
+}
+
+func roundToTenth(value float64) float64 {
+	return math.Round(value*10) / 10
+}
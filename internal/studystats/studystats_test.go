@@ -0,0 +1,119 @@
+package studystats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		studies []Study
+		aliases map[string]string
+		want    Statistics
+	}{
+		{"no studies", nil, nil, Statistics{
+			StudiesPerDay:      map[string]int{},
+			StudiesPerTheme:    map[string]int{},
+			TotalMinutesPerDay: map[string]int{},
+			MergedKeys:         map[string][]string{},
+		}},
+		{"averages rounded to a tenth", []Study{
+			{"Go", "01/03/2024", 30},
+			{"Go", "01/03/2024", 20},
+			{"SQL", "02/03/2024", 25},
+		}, nil, Statistics{
+			StudiesPerDay:               map[string]int{"01/03/2024": 2, "02/03/2024": 1},
+			StudiesPerTheme:             map[string]int{"go": 2, "sql": 1},
+			TotalMinutesStudied:         75,
+			TotalMinutesPerDay:          map[string]int{"01/03/2024": 50, "02/03/2024": 25},
+			AverageMinutesPerSession:    25,
+			AverageSessionsPerActiveDay: 1.5,
+			MostStudiedTheme:            "go",
+			BusiestDay:                  "01/03/2024",
+			MergedKeys:                  map[string][]string{"go": {"Go"}, "sql": {"SQL"}},
+		}},
+		{"ties go to the first theme and the earliest day", []Study{
+			{"sql", "05/03/2024", 40},
+			{"go", "04/03/2024", 40},
+			{"rust", "03/03/2024", 10},
+		}, nil, Statistics{
+			StudiesPerDay:               map[string]int{"03/03/2024": 1, "04/03/2024": 1, "05/03/2024": 1},
+			StudiesPerTheme:             map[string]int{"go": 1, "rust": 1, "sql": 1},
+			TotalMinutesStudied:         90,
+			TotalMinutesPerDay:          map[string]int{"03/03/2024": 10, "04/03/2024": 40, "05/03/2024": 40},
+			AverageMinutesPerSession:    30,
+			AverageSessionsPerActiveDay: 1,
+			MostStudiedTheme:            "go",
+			BusiestDay:                  "04/03/2024",
+			MergedKeys:                  map[string][]string{},
+		}},
+		{"aliases fold themes", []Study{
+			{"k8s", "01/03/2024", 10},
+			{"Kubernetes", "01/03/2024", 20},
+		}, map[string]string{"k8s": "kubernetes"}, Statistics{
+			StudiesPerDay:               map[string]int{"01/03/2024": 2},
+			StudiesPerTheme:             map[string]int{"kubernetes": 2},
+			TotalMinutesStudied:         30,
+			TotalMinutesPerDay:          map[string]int{"01/03/2024": 30},
+			AverageMinutesPerSession:    15,
+			AverageSessionsPerActiveDay: 2,
+			MostStudiedTheme:            "kubernetes",
+			BusiestDay:                  "01/03/2024",
+			MergedKeys:                  map[string][]string{"kubernetes": {"Kubernetes", "k8s"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Generate(tt.studies, tt.aliases); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Generate =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMostMinutes(t *testing.T) {
+	alphabetical := func(a, b string) bool { return a < b }
+	tests := []struct {
+		name    string
+		minutes map[string]int
+		before  func(a, b string) bool
+		want    string
+	}{
+		{"empty", map[string]int{}, alphabetical, ""},
+		{"single", map[string]int{"go": 5}, alphabetical, "go"},
+		{"most wins", map[string]int{"go": 5, "sql": 9, "rust": 1}, alphabetical, "sql"},
+		{"tie broken alphabetically", map[string]int{"sql": 9, "go": 9, "rust": 9}, alphabetical, "go"},
+		{"tie broken by date, not text", map[string]int{"10/01/2024": 9, "02/02/2024": 9}, earlierDate, "10/01/2024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order varies, so run each case a few times
+			for range 10 {
+				if got := mostMinutes(tt.minutes, tt.before); got != tt.want {
+					t.Fatalf("mostMinutes = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEarlierDate(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"01/03/2024", "02/03/2024", true},
+		{"02/03/2024", "01/03/2024", false},
+		{"31/12/2023", "01/01/2024", true},
+		{"01/01/2024", "someday", true},
+		{"someday", "01/01/2024", false},
+		{"a day", "someday", true},
+		{"01/01/2024", "01/01/2024", false},
+	}
+	for _, tt := range tests {
+		if got := earlierDate(tt.a, tt.b); got != tt.want {
+			t.Errorf("earlierDate(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
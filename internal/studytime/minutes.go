@@ -0,0 +1,73 @@
+package studytime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxMinutes caps the minutes of a single study at one full day
+const MaxMinutes = 24 * 60
+
+// ParseMinutes accepts whole minutes between 1 and MaxMinutes
+func ParseMinutes(value string) (int, error) {
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("must be a whole number of minutes, got %q", value)
+	}
+	if minutes <= 0 || minutes > MaxMinutes {
+		return 0, fmt.Errorf("must be between 1 and %d, the minutes in a day, got %d", MaxMinutes, minutes)
+	}
+	return minutes, nil
+}
+
+// MinutesInput is the minutes of a study in a request body, sent either as a
+// JSON number or as a string like "45". Anything else is kept as sent, so
+// ParseMinutes can reject it as a field of the request rather than failing
+// the whole body.
+type MinutesInput string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *MinutesInput) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*m = MinutesInput(text)
+		return nil
+	}
+	*m = MinutesInput(data)
+	return nil
+}
+
+// Minutes reads minutes_of_study, which the add lambdas store as a Number.
+// Items written before that kept it as a String; those are read too, and
+// one that doesn't hold a whole number reads as 0.
+type Minutes int
+
+// UnmarshalDynamoDBAttributeValue implements attributevalue.Unmarshaler
+func (m *Minutes) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	var value string
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		value = v.Value
+	case *types.AttributeValueMemberS:
+		value = v.Value
+	case *types.AttributeValueMemberNULL:
+		*m = 0
+		return nil
+	default:
+		return fmt.Errorf("minutes_of_study must be a number, got %T", av)
+	}
+
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		if _, ok := av.(*types.AttributeValueMemberN); ok {
+			return fmt.Errorf("minutes_of_study is not a whole number: %q", value)
+		}
+		minutes = 0
+	}
+	*m = Minutes(minutes)
+	return nil
+}
@@ -0,0 +1,86 @@
+package studytime
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseMinutes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"45", 45, false},
+		{" 45 ", 45, false},
+		{"1", 1, false},
+		{"1440", MaxMinutes, false},
+		{"1441", 0, true},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"4.5", 0, true},
+		{"forty", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMinutes(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMinutes(%q) = %d, %v, want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMinutesInputUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want MinutesInput
+	}{
+		{`45`, "45"},
+		{`"45"`, "45"},
+		{`4.5`, "4.5"},
+		{`null`, ""},
+		{`true`, "true"},
+		{`[30]`, "[30]"},
+	}
+	for _, tt := range tests {
+		var got MinutesInput
+		if err := json.Unmarshal([]byte(tt.body), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.body, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMinutesUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		av      types.AttributeValue
+		want    Minutes
+		wantErr bool
+	}{
+		{"number", &types.AttributeValueMemberN{Value: "45"}, 45, false},
+		{"number that isn't whole", &types.AttributeValueMemberN{Value: "4.5"}, 0, true},
+		{"legacy string", &types.AttributeValueMemberS{Value: "30"}, 30, false},
+		{"legacy string with spaces", &types.AttributeValueMemberS{Value: " 30 "}, 30, false},
+		{"legacy string that isn't a number", &types.AttributeValueMemberS{Value: "half an hour"}, 0, false},
+		{"null", &types.AttributeValueMemberNULL{Value: true}, 0, false},
+		{"wrong type", &types.AttributeValueMemberSS{Value: []string{"30"}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minutes := Minutes(-1)
+			err := minutes.UnmarshalDynamoDBAttributeValue(tt.av)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && minutes != tt.want {
+				t.Errorf("minutes = %d, want %d", minutes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/metrics"
+)
+
+// EnvTenants lists the known tenants, comma-separated. When it is unset the
+// deployment is single-tenant and tables are used without a prefix.
+const EnvTenants = "TENANTS"
+
+// Header names the tenant explicitly, mostly for testing
+const Header = "X-Tenant"
+
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+type contextKey struct{}
+
+// FromRequest resolves the tenant of an API Gateway request, stores it in the
+// returned context and adds it to the default logger. The tenant is read,
+// in order, from the X-Tenant header, a {tenant} path parameter, the first
+// path segment, and the first label of the Host subdomain; the last two are
+// only considered when they name a known tenant.
+func FromRequest(ctx context.Context, event events.APIGatewayProxyRequest) (context.Context, error) {
+	name, err := resolve(event)
+	if err != nil {
+		return ctx, err
+	}
+
+	// logging.WithRequestIDs replaces the default logger on every
+	// invocation, so the tenant never leaks into the next one
+	if name != "" {
+		slog.SetDefault(slog.Default().With("tenant", name))
+	}
+	return WithTenant(ctx, name), nil
+}
+
+// WithTenant returns a context carrying the tenant name, which its metrics
+// are also reported under
+func WithTenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(metrics.WithTenant(ctx, name), contextKey{}, name)
+}
+
+// FromContext returns the tenant stored in ctx, or "" for single-tenant use
+func FromContext(ctx context.Context) string {
+	name, _ := ctx.Value(contextKey{}).(string)
+	return name
+}
+
+// Table prefixes a base table name with the tenant in ctx, e.g.
+// alice_veet_code_questions_table
+func Table(ctx context.Context, base string) string {
+	return Key(ctx, base)
+}
+
+// Key scopes any per-tenant identifier (tables, cache entries, counters)
+func Key(ctx context.Context, key string) string {
+	if name := FromContext(ctx); name != "" {
+		return name + "_" + key
+	}
+	return key
+}
+
+func resolve(event events.APIGatewayProxyRequest) (string, error) {
+	tenants := knownTenants()
+	if tenants == nil {
+		return "", nil
+	}
+
+	explicit := []string{awsutil.HeaderValue(event.Headers, Header), event.PathParameters["tenant"]}
+	for _, name := range explicit {
+		if name == "" {
+			continue
+		}
+		if !tenants[name] {
+			return "", ErrUnknownTenant
+		}
+		return name, nil
+	}
+
+	inferred := []string{firstPathSegment(event.Path), subdomain(awsutil.HeaderValue(event.Headers, "Host"))}
+	for _, name := range inferred {
+		if tenants[name] {
+			return name, nil
+		}
+	}
+
+	return "", ErrUnknownTenant
+}
+
+func knownTenants() map[string]bool {
+	value := os.Getenv(EnvTenants)
+	if value == "" {
+		return nil
+	}
+
+	tenants := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			tenants[name] = true
+		}
+	}
+	return tenants
+}
+
+func firstPathSegment(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return segment
+}
+
+func subdomain(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) < 3 {
+		return ""
+	}
+	return labels[0]
+}
@@ -0,0 +1,107 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const questionsTable = "veet_code_questions_table"
+
+func TestFromRequest(t *testing.T) {
+	t.Setenv(EnvTenants, "alice, bob")
+
+	tests := []struct {
+		name    string
+		event   events.APIGatewayProxyRequest
+		want    string
+		wantErr error
+	}{
+		{"header", events.APIGatewayProxyRequest{Headers: map[string]string{"x-tenant": "alice"}}, "alice", nil},
+		{"path parameter", events.APIGatewayProxyRequest{PathParameters: map[string]string{"tenant": "bob"}}, "bob", nil},
+		{"path segment", events.APIGatewayProxyRequest{Path: "/bob/questions"}, "bob", nil},
+		{"subdomain", events.APIGatewayProxyRequest{Headers: map[string]string{"Host": "alice.veet.example.com"}}, "alice", nil},
+		{"header before path segment", events.APIGatewayProxyRequest{
+			Headers: map[string]string{"X-Tenant": "alice"}, Path: "/bob/questions",
+		}, "alice", nil},
+		{"path segment before subdomain", events.APIGatewayProxyRequest{
+			Headers: map[string]string{"Host": "alice.veet.example.com"}, Path: "/bob/questions",
+		}, "bob", nil},
+		{"unknown header", events.APIGatewayProxyRequest{
+			Headers: map[string]string{"X-Tenant": "mallory"}, Path: "/alice/questions",
+		}, "", ErrUnknownTenant},
+		{"unknown path parameter", events.APIGatewayProxyRequest{PathParameters: map[string]string{"tenant": "mallory"}}, "", ErrUnknownTenant},
+		{"path segment that isn't a tenant", events.APIGatewayProxyRequest{Path: "/questions"}, "", ErrUnknownTenant},
+		{"host without a subdomain", events.APIGatewayProxyRequest{Headers: map[string]string{"Host": "example.com"}}, "", ErrUnknownTenant},
+		{"nothing to resolve", events.APIGatewayProxyRequest{}, "", ErrUnknownTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := FromRequest(context.Background(), tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromRequest error = %v, want %v", err, tt.wantErr)
+			}
+			if got := FromContext(ctx); got != tt.want {
+				t.Errorf("tenant = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromRequestSingleTenant(t *testing.T) {
+	t.Setenv(EnvTenants, "")
+
+	ctx, err := FromRequest(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"X-Tenant": "alice"}, Path: "/alice/questions",
+	})
+	if err != nil {
+		t.Fatalf("FromRequest: %v", err)
+	}
+	if got := Table(ctx, questionsTable); got != questionsTable {
+		t.Errorf("Table = %q, want the table unprefixed", got)
+	}
+}
+
+// Two tenants resolved the same way never share a table or a key
+func TestIsolation(t *testing.T) {
+	t.Setenv(EnvTenants, "alice,bob")
+
+	resolutions := map[string]func(name string) events.APIGatewayProxyRequest{
+		"header": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Headers: map[string]string{"X-Tenant": name}}
+		},
+		"path parameter": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{PathParameters: map[string]string{"tenant": name}}
+		},
+		"path segment": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Path: "/" + name + "/statistics"}
+		},
+		"subdomain": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Headers: map[string]string{"Host": name + ".veet.example.com"}}
+		},
+	}
+	for resolution, event := range resolutions {
+		t.Run(resolution, func(t *testing.T) {
+			alice, err := FromRequest(context.Background(), event("alice"))
+			if err != nil {
+				t.Fatalf("FromRequest alice: %v", err)
+			}
+			bob, err := FromRequest(context.Background(), event("bob"))
+			if err != nil {
+				t.Fatalf("FromRequest bob: %v", err)
+			}
+
+			if got := Table(alice, questionsTable); got != "alice_"+questionsTable {
+				t.Errorf("alice's table = %q", got)
+			}
+			if got := Table(bob, questionsTable); got != "bob_"+questionsTable {
+				t.Errorf("bob's table = %q", got)
+			}
+			if Key(alice, "counter") == Key(bob, "counter") {
+				t.Errorf("alice and bob share the key %q", Key(alice, "counter"))
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// EnvEnabled turns tracing on when set to "true". It is off by default so
+// local runs don't need an X-Ray daemon.
+const EnvEnabled = "XRAY_TRACING_ENABLED"
+
+var enabled = os.Getenv(EnvEnabled) == "true"
+
+func init() {
+	// Outside Lambda, or in code called without the invocation's context,
+	// there is no segment to attach to; that is not worth logging every time
+	_ = xray.Configure(xray.Config{ContextMissingStrategy: ctxmissing.NewDefaultIgnoreErrorStrategy()})
+}
+
+// Enabled reports whether subsegments are sent to the X-Ray daemon
+func Enabled() bool {
+	return enabled
+}
+
+// Subsegment is a timed section of an invocation, reported to X-Ray as a
+// child of the Lambda function segment or of another subsegment
+type Subsegment struct {
+	segment *xray.Segment
+}
+
+// Start opens a subsegment named name. The returned context makes it the
+// parent of subsegments started from it, including those of SDK calls. When
+// tracing is disabled, or ctx carries no trace, it returns a nil subsegment,
+// which AddMetadata and End ignore.
+func Start(ctx context.Context, name string) (context.Context, *Subsegment) {
+	if !enabled {
+		return ctx, nil
+	}
+
+	ctx, segment := xray.BeginSubsegment(ctx, name)
+	if segment == nil {
+		return ctx, nil
+	}
+	return ctx, &Subsegment{segment: segment}
+}
+
+// AddMetadata attaches a value shown in the X-Ray console under the
+// subsegment's metadata
+func (s *Subsegment) AddMetadata(key string, value any) {
+	if s == nil {
+		return
+	}
+	_ = s.segment.AddMetadata(key, value)
+}
+
+// End closes the subsegment, marking it as a fault when err is set
+func (s *Subsegment) End(err error) {
+	if s == nil {
+		return
+	}
+	s.segment.Close(err)
+}
+
+// InstrumentAWS adds the X-Ray middleware to the API options of an AWS SDK
+// client when tracing is enabled, so each call, page by page for paginated
+// scans, is recorded as its own subsegment
+func InstrumentAWS(apiOptions *[]func(*middleware.Stack) error) {
+	if !enabled {
+		return
+	}
+	awsv2.AWSV2Instrumentor(apiOptions)
+}
@@ -0,0 +1,242 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+)
+
+// EnvEnabled turns on per-user data when set to "true". Every request must
+// then identify a user, new items are stamped with it and reads only return
+// the user's own items. Off, the tables stay single-user as before.
+const EnvEnabled = "MULTI_USER_ENABLED"
+
+// EnvLegacyOwner names the user that owns the items written before
+// multi-user was enabled, which carry no Attribute. Other users never see
+// them.
+const EnvLegacyOwner = "LEGACY_USER_ID"
+
+// EnvHeaderEnabled lets the Header stand in for an authorizer, for testing
+// without Cognito. Never set it where the API is public: anyone could send
+// any user ID.
+const EnvHeaderEnabled = "USER_ID_HEADER_ENABLED"
+
+const Header = "X-User-Id"
+
+// EnvAdmins lists, comma-separated, the user IDs allowed through RequireAdmin.
+// Unset, no one is.
+const EnvAdmins = "ADMIN_USER_IDS"
+
+// Attribute holds the owner of every question and study item. It is the
+// partition key of the questions table, so each user has their own names.
+const Attribute = "user_id"
+
+// DefaultOwner owns the questions of single-user deployments, and the ones
+// written before multi-user was enabled when EnvLegacyOwner is unset
+const DefaultOwner = "default"
+
+var ErrNoUser = errors.New("no user in the request")
+
+var (
+	enabled       = os.Getenv(EnvEnabled) == "true"
+	headerEnabled = os.Getenv(EnvHeaderEnabled) == "true"
+	legacyOwner   = os.Getenv(EnvLegacyOwner)
+	admins        = parseAdmins(os.Getenv(EnvAdmins))
+)
+
+func parseAdmins(value string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// Enabled reports whether data is partitioned by user
+func Enabled() bool {
+	return enabled
+}
+
+type contextKey struct{}
+
+// Require resolves the user of every request before calling handler, and
+// answers 401 when there is none. It does nothing unless EnvEnabled is set.
+func Require(handler awsutil.ProxyHandler) awsutil.ProxyHandler {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if !enabled {
+			return handler(ctx, event)
+		}
+
+		id, err := fromRequest(event)
+		if err != nil {
+			slog.Warn("Failed to identify user", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeUnauthorized, "sign in to use this API"), nil
+		}
+
+		// logging.WithRequestIDs replaces the default logger on every
+		// invocation, so the user never leaks into the next one
+		slog.SetDefault(slog.Default().With("user", id))
+		return handler(WithUser(ctx, id), event)
+	}
+}
+
+// RequireAdmin only calls handler for the users listed in EnvAdmins,
+// answering 401 without a user and 403 for anyone else. Unlike Require it
+// always identifies the user, multi-user or not.
+func RequireAdmin(handler awsutil.ProxyHandler) awsutil.ProxyHandler {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		id, err := fromRequest(event)
+		if err != nil {
+			slog.Warn("Failed to identify user", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeUnauthorized, "sign in to use this API"), nil
+		}
+		if !admins[id] {
+			slog.Warn("Refused a user that isn't an admin", "user", id)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeForbidden, "only admins can use this API"), nil
+		}
+		return handler(ctx, event)
+	}
+}
+
+// WithUser returns a context carrying the user ID
+func WithUser(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the user stored in ctx, or "" when data is not per user
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Owner is the user_id keying the questions of the user in ctx. Without one,
+// questions belong to the legacy owner, as items written before multi-user
+// was enabled do.
+func Owner(ctx context.Context) string {
+	if id := FromContext(ctx); id != "" {
+		return id
+	}
+	return LegacyOwner()
+}
+
+// LegacyOwner is the user the items without an Attribute are migrated to
+func LegacyOwner() string {
+	if legacyOwner != "" {
+		return legacyOwner
+	}
+	return DefaultOwner
+}
+
+// Key scopes an identifier, like a cache entry or the goal item, to the user
+// in ctx
+func Key(ctx context.Context, key string) string {
+	if id := FromContext(ctx); id != "" {
+		return id + "#" + key
+	}
+	return key
+}
+
+// fromRequest reads the sub claim a Cognito user pool authorizer passes on,
+// then the Header when it is allowed
+func fromRequest(event events.APIGatewayProxyRequest) (string, error) {
+	if claims, ok := event.RequestContext.Authorizer["claims"].(map[string]any); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return sub, nil
+		}
+	}
+	if headerEnabled {
+		if id := strings.TrimSpace(awsutil.HeaderValue(event.Headers, Header)); id != "" {
+			return id, nil
+		}
+	}
+	return "", ErrNoUser
+}
+
+// Stamp marks a new item as owned by the user in ctx
+func Stamp(ctx context.Context, item map[string]types.AttributeValue) {
+	if id := FromContext(ctx); id != "" {
+		item[Attribute] = &types.AttributeValueMemberS{Value: id}
+	}
+}
+
+// Owns reports whether an item read by key belongs to the user in ctx
+func Owns(ctx context.Context, item map[string]types.AttributeValue) bool {
+	id := FromContext(ctx)
+	if id == "" {
+		return true
+	}
+	owner, ok := item[Attribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return id == legacyOwner
+	}
+	return owner.Value == id
+}
+
+// ScopeScan filters a scan down to the items of the user in ctx
+func ScopeScan(ctx context.Context, input *dynamodb.ScanInput) {
+	input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues =
+		scope(ctx, ownedCondition(ctx), input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+}
+
+// ScopeUpdate makes an update fail with ConditionalCheckFailedException on
+// items of other users, as if they did not exist
+func ScopeUpdate(ctx context.Context, input *dynamodb.UpdateItemInput) {
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues =
+		scope(ctx, ownedCondition(ctx), input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+}
+
+// ScopeDelete makes a delete fail like ScopeUpdate does
+func ScopeDelete(ctx context.Context, input *dynamodb.DeleteItemInput) {
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues =
+		scope(ctx, ownedCondition(ctx), input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+}
+
+// ScopePut lets a put create an item or replace one of the user's own, and
+// fail with ConditionalCheckFailedException on items of other users
+func ScopePut(ctx context.Context, input *dynamodb.PutItemInput) {
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues =
+		scope(ctx, "(attribute_not_exists(#userID) OR #userID = :userID)", input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+}
+
+// ownedCondition matches the items of the user in ctx, counting items
+// without an owner for the legacy owner
+func ownedCondition(ctx context.Context) string {
+	if FromContext(ctx) == legacyOwner {
+		return "(attribute_not_exists(#userID) OR #userID = :userID)"
+	}
+	return "#userID = :userID"
+}
+
+// scope ANDs condition onto an existing expression, adding the placeholders
+// it uses. Without a user in ctx everything is returned unchanged.
+func scope(ctx context.Context, condition string, expression *string, names map[string]string, values map[string]types.AttributeValue) (*string, map[string]string, map[string]types.AttributeValue) {
+	id := FromContext(ctx)
+	if id == "" {
+		return expression, names, values
+	}
+
+	if expression != nil && *expression != "" {
+		condition = fmt.Sprintf("(%s) AND %s", *expression, condition)
+	}
+	if names == nil {
+		names = make(map[string]string)
+	}
+	names["#userID"] = Attribute
+	if values == nil {
+		values = make(map[string]types.AttributeValue)
+	}
+	values[":userID"] = &types.AttributeValueMemberS{Value: id}
+	return aws.String(condition), names, values
+}
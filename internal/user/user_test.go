@@ -0,0 +1,244 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+// ownedBy returns an item owned by owner, or by no one when owner is ""
+func ownedBy(owner string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}}
+	if owner != "" {
+		item[Attribute] = &types.AttributeValueMemberS{Value: owner}
+	}
+	return item
+}
+
+func TestRequire(t *testing.T) {
+	headerEnabled = true
+	t.Cleanup(func() { enabled, headerEnabled = false, false })
+
+	var got string
+	handler := Require(func(ctx context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = FromContext(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	})
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		event      events.APIGatewayProxyRequest
+		wantStatus int
+		wantUser   string
+	}{
+		{"claim", true, events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]any{"claims": map[string]any{"sub": "alice"}},
+		}}, 200, "alice"},
+		{"header", true, events.APIGatewayProxyRequest{Headers: map[string]string{"X-User-Id": " bob "}}, 200, "bob"},
+		{"no user", true, events.APIGatewayProxyRequest{}, 401, ""},
+		{"disabled", false, events.APIGatewayProxyRequest{Headers: map[string]string{"X-User-Id": "bob"}}, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, got = tt.enabled, ""
+			response, err := handler(context.Background(), tt.event)
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Fatalf("Require = %d, %v, want %d", response.StatusCode, err, tt.wantStatus)
+			}
+			if got != tt.wantUser {
+				t.Errorf("user = %q, want %q", got, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	t.Cleanup(func() { legacyOwner = "" })
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		legacyOwner string
+		want        string
+	}{
+		{"user", WithUser(context.Background(), "bob"), "alice", "bob"},
+		{"legacy owner", context.Background(), "alice", "alice"},
+		{"single user", context.Background(), "", DefaultOwner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legacyOwner = tt.legacyOwner
+			if got := Owner(tt.ctx); got != tt.want {
+				t.Errorf("Owner = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwns(t *testing.T) {
+	legacyOwner = "alice"
+	t.Cleanup(func() { legacyOwner = "" })
+
+	tests := []struct {
+		name string
+		user string
+		item map[string]types.AttributeValue
+		want bool
+	}{
+		{"own item", "bob", ownedBy("bob"), true},
+		{"another user's item", "bob", ownedBy("carol"), false},
+		{"legacy item of the legacy owner", "alice", ownedBy(""), true},
+		{"legacy item of another user", "bob", ownedBy(""), false},
+		{"without a user", "", ownedBy("carol"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Owns(WithUser(context.Background(), tt.user), tt.item); got != tt.want {
+				t.Errorf("Owns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeScan(t *testing.T) {
+	legacyOwner = "alice"
+	t.Cleanup(func() { legacyOwner = "" })
+
+	tests := []struct {
+		name          string
+		user          string
+		filter        *string
+		wantFilter    *string
+		wantUserValue bool
+	}{
+		{"user", "bob", nil, aws.String("#userID = :userID"), true},
+		{"existing filter", "bob", aws.String("#date >= :from"), aws.String("(#date >= :from) AND #userID = :userID"), true},
+		{"legacy owner", "alice", nil, aws.String("(attribute_not_exists(#userID) OR #userID = :userID)"), true},
+		{"without a user", "", aws.String("#date >= :from"), aws.String("#date >= :from"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &dynamodb.ScanInput{FilterExpression: tt.filter}
+			ScopeScan(WithUser(context.Background(), tt.user), input)
+			if aws.ToString(input.FilterExpression) != aws.ToString(tt.wantFilter) {
+				t.Errorf("filter = %q, want %q", aws.ToString(input.FilterExpression), aws.ToString(tt.wantFilter))
+			}
+			want := map[string]types.AttributeValue(nil)
+			if tt.wantUserValue {
+				want = map[string]types.AttributeValue{":userID": &types.AttributeValueMemberS{Value: tt.user}}
+			}
+			if !reflect.DeepEqual(input.ExpressionAttributeValues, want) {
+				t.Errorf("values = %v, want %v", input.ExpressionAttributeValues, want)
+			}
+		})
+	}
+}
+
+func TestScopePut(t *testing.T) {
+	input := &dynamodb.PutItemInput{ConditionExpression: aws.String("attribute_not_exists(id)")}
+	ScopePut(WithUser(context.Background(), "bob"), input)
+
+	want := "(attribute_not_exists(id)) AND (attribute_not_exists(#userID) OR #userID = :userID)"
+	if got := aws.ToString(input.ConditionExpression); got != want {
+		t.Errorf("condition = %q, want %q", got, want)
+	}
+	if got := input.ExpressionAttributeNames["#userID"]; got != Attribute {
+		t.Errorf("#userID = %q, want %q", got, Attribute)
+	}
+}
+
+func TestScopeUpdate(t *testing.T) {
+	legacyOwner = "alice"
+	t.Cleanup(func() { legacyOwner = "" })
+
+	tests := []struct {
+		name        string
+		user        string
+		owner       string
+		wantUpdated bool
+	}{
+		{"own item", "bob", "bob", true},
+		{"another user's item", "bob", "carol", false},
+		{"legacy item of the legacy owner", "alice", "", true},
+		{"legacy item of another user", "bob", "", false},
+		{"without a user", "", "carol", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New("table", ownedBy(tt.owner))
+			fake.Keys = map[string][]string{"table": {"id"}}
+
+			input := &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("table"),
+				Key:                       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "1"}},
+				UpdateExpression:          aws.String("SET #flag = :flag"),
+				ExpressionAttributeNames:  map[string]string{"#flag": "flag"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":flag": &types.AttributeValueMemberBOOL{Value: true}},
+			}
+			ScopeUpdate(WithUser(context.Background(), tt.user), input)
+			_, err := fake.UpdateItem(context.Background(), input)
+
+			var conditionFailed *types.ConditionalCheckFailedException
+			if updated := err == nil; updated != tt.wantUpdated || (err != nil && !errors.As(err, &conditionFailed)) {
+				t.Errorf("UpdateItem = %v, want updated %v", err, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	admins = parseAdmins(" alice, ,carol ")
+	headerEnabled = true
+	t.Cleanup(func() { admins, headerEnabled = map[string]bool{}, false })
+
+	ok := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+	claims := func(sub string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]any{"claims": map[string]any{"sub": sub}},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		event      events.APIGatewayProxyRequest
+		wantStatus int
+	}{
+		{"admin by claim", claims("alice"), 200},
+		{"admin by header", events.APIGatewayProxyRequest{Headers: map[string]string{"x-user-id": "carol"}}, 200},
+		{"other user", claims("bob"), 403},
+		{"blank entries aren't admins", claims(" "), 403},
+		{"no user", events.APIGatewayProxyRequest{}, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := RequireAdmin(ok)(context.Background(), tt.event)
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Errorf("RequireAdmin = %d, %v, want %d", response.StatusCode, err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireAdminWithoutAdmins(t *testing.T) {
+	admins = parseAdmins("")
+	response, _ := RequireAdmin(func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		t.Fatal("handler called without any admin configured")
+		return events.APIGatewayProxyResponse{}, nil
+	})(context.Background(), events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{
+		Authorizer: map[string]any{"claims": map[string]any{"sub": "alice"}},
+	}})
+	if response.StatusCode != 403 {
+		t.Errorf("status = %d, want 403", response.StatusCode)
+	}
+}
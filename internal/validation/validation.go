@@ -0,0 +1,81 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"veet-code-go/internal/quota"
+)
+
+const (
+	CodeEmptyBody        = "EMPTY_BODY"
+	CodeEmptyBatch       = "EMPTY_BATCH"
+	CodeBatchTooLarge    = "BATCH_TOO_LARGE"
+	CodeValidationFailed = "VALIDATION_FAILED"
+)
+
+// FieldError describes a single offending field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a client error that handlers turn into a 400 response
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Envelope wraps the error as {"error": {...}} for the response body
+func (e *Error) Envelope() map[string]*Error {
+	return map[string]*Error{"error": e}
+}
+
+// CheckBody rejects bodies that are empty or the JSON literal null
+func CheckBody(body string) *Error {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" || trimmed == "null" {
+		return &Error{Code: CodeEmptyBody, Message: "request body is empty"}
+	}
+	return nil
+}
+
+// CheckBatch rejects bulk requests without any items or with more than
+// quota.BatchSize allows
+func CheckBatch(size int) *Error {
+	if size == 0 {
+		return &Error{Code: CodeEmptyBatch, Message: "request contains no items"}
+	}
+	if quota.BatchSize.Exceeded(size) {
+		return &Error{Code: CodeBatchTooLarge, Message: fmt.Sprintf("request contains %d items, the limit is %d", size, quota.BatchSize.Max)}
+	}
+	return nil
+}
+
+// Fields accumulates field-level errors for a request
+type Fields []FieldError
+
+// Require records an error when value is blank
+func (f *Fields) Require(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		f.Add(field, "is required")
+	}
+}
+
+// Add records an error for field
+func (f *Fields) Add(field, message string) {
+	*f = append(*f, FieldError{Field: field, Message: message})
+}
+
+// Err returns nil when no field errors were recorded
+func (f Fields) Err() *Error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &Error{Code: CodeValidationFailed, Message: "request has invalid fields", Fields: f}
+}
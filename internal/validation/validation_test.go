@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"veet-code-go/internal/quota"
+)
+
+func code(err *Error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Code
+}
+
+func TestCheckBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"", CodeEmptyBody},
+		{" \n\t", CodeEmptyBody},
+		{"null", CodeEmptyBody},
+		{" null\n", CodeEmptyBody},
+		{"{}", ""},
+		{"[]", ""},
+		{`{"name":"two-sum"}`, ""},
+	}
+	for _, tt := range tests {
+		if got := code(CheckBody(tt.body)); got != tt.want {
+			t.Errorf("CheckBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBatch(t *testing.T) {
+	tests := []struct {
+		size int
+		want string
+	}{
+		{0, CodeEmptyBatch},
+		{1, ""},
+		{quota.BatchSize.Max, ""},
+		{quota.BatchSize.Max + 1, CodeBatchTooLarge},
+	}
+	for _, tt := range tests {
+		if got := code(CheckBatch(tt.size)); got != tt.want {
+			t.Errorf("CheckBatch(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	var fields Fields
+	if err := fields.Err(); err != nil {
+		t.Fatalf("Err with no fields = %v, want nil", err)
+	}
+
+	fields.Require("name", " ")
+	fields.Require("date", "2024-03-01")
+	fields.Add("url", "must be an http or https URL")
+	err := fields.Err()
+	if code(err) != CodeValidationFailed {
+		t.Fatalf("Err = %v, want %s", err, CodeValidationFailed)
+	}
+	want := []FieldError{{"name", "is required"}, {"url", "must be an http or https URL"}}
+	if !reflect.DeepEqual(err.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", err.Fields, want)
+	}
+}
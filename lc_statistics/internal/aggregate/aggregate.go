@@ -0,0 +1,527 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/model"
+)
+
+// StatsTableEnv overrides the table holding the precomputed statistics
+const StatsTableEnv = "STATS_TABLE_NAME"
+
+const DefaultStatsTable = "veet_code_statistics_table"
+
+// KeyAttribute holds the questions table an item aggregates, so every tenant
+// table gets an item of its own
+const KeyAttribute = "source_table"
+
+// versionAttribute counts the writes to an item. Every stream update bumps it
+// and Seed only replaces the version it read before scanning, so a full scan
+// never overwrites updates applied while it ran.
+const versionAttribute = "version"
+
+// staleFromAttribute marks the daily counters from a date on as
+// untrustworthy, after a backdated edit. "" covers every counter, for a
+// stream record that could not be applied.
+const staleFromAttribute = "stale_from"
+
+// maxCorrectionsPerUpdate keeps a Recalculate update well under the 4KB
+// limit of an update expression
+const maxCorrectionsPerUpdate = 50
+
+// ErrSeedConflict is returned by Seed and Recalculate when the item changed
+// since its version was read, so the scan may be stale
+var ErrSeedConflict = errors.New("statistics item changed while seeding")
+
+// Every counter is a top-level number attribute, so a single ADD can create
+// and update any of them without the enclosing maps having to exist
+const (
+	totalAttribute       = "total"
+	unknownAttribute     = "unknown_difficulty"
+	favoritesAttribute   = "favorites"
+	solvedAgainAttribute = "solved_more_than_once"
+	reviewDayPrefix      = "review_day#"
+	dayPrefix            = "day#"
+	difficultyPrefix     = "difficulty#"
+	tagPrefix            = "tag#"
+	tagDifficultyPrefix  = "tag_difficulty#"
+	minutesDayPrefix     = "minutes_day#"
+	// Minutes and the number of questions they were spent on, per difficulty
+	minutesDifficultyPrefix = "minutes_difficulty#"
+	timedDifficultyPrefix   = "timed_difficulty#"
+)
+
+// Snapshot is the precomputed statistics of one questions table
+type Snapshot struct {
+	Totals model.QuestionTotals
+	PerDay map[string]int
+	// Version is what Seed expects to replace; 0 when the item doesn't exist
+	// or predates versioning
+	Version int
+	// Stale is set when the counters from StaleFrom on need a Recalculate
+	// before they can be served
+	Stale     bool
+	StaleFrom string
+}
+
+// NewestDay is the latest day any daily counter is kept for, "" when there
+// are none
+func (s Snapshot) NewestDay() string {
+	newest := ""
+	for _, perDay := range []map[string]int{s.PerDay, s.Totals.ReviewsPerDay, s.Totals.MinutesSolvingPerDay} {
+		for day := range perDay {
+			newest = max(newest, day)
+		}
+	}
+	return newest
+}
+
+// Deltas maps counter attributes of the statistics item to the amount they
+// change by
+type Deltas map[string]int
+
+// Add counts q towards the deltas with sign 1, or takes it away with sign -1
+func (d Deltas) Add(q model.Question, sign int) {
+	totals := model.NewQuestionTotals()
+	totals.Add(q)
+
+	d[totalAttribute] += sign
+	d[dayPrefix+q.Date] += sign
+	d[unknownAttribute] += sign * totals.UnknownDifficultyCount
+	d[favoritesAttribute] += sign * totals.FavoritesCount
+	d[solvedAgainAttribute] += sign * totals.QuestionsSolvedMoreThanOnce
+	for date, count := range totals.ReviewsPerDay {
+		d[reviewDayPrefix+date] += sign * count
+	}
+	for difficulty, count := range totals.QuestionsCrackedPerDifficulty {
+		d[difficultyPrefix+difficulty] += sign * count
+	}
+	for tag, count := range totals.QuestionsCrackedPerTag {
+		d[tagPrefix+tag] += sign * count
+	}
+	for tag, perDifficulty := range totals.QuestionsPerTagPerDifficulty {
+		for difficulty, count := range perDifficulty {
+			d[tagDifficultyPrefix+tag+"#"+difficulty] += sign * count
+		}
+	}
+	if q.MinutesToSolve != nil {
+		d[minutesDayPrefix+q.Date] += sign * *q.MinutesToSolve
+		if !model.IsUnknownDifficulty(q.Difficulty) {
+			d[minutesDifficultyPrefix+q.Difficulty] += sign * *q.MinutesToSolve
+			d[timedDifficultyPrefix+q.Difficulty] += sign
+		}
+	}
+}
+
+// Update builds an atomic ADD of the non-zero deltas to the item of
+// sourceTable, bumping its version, or nil when nothing changes. The item must
+// already exist: until Seed creates it from a full scan, the scan covers these
+// changes anyway.
+func (d Deltas) Update(statsTable, sourceTable string) *dynamodb.UpdateItemInput {
+	var names []string
+	for name, delta := range d {
+		if delta != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	attributeNames := map[string]string{"#key": KeyAttribute, "#version": versionAttribute}
+	values := map[string]types.AttributeValue{":one": &types.AttributeValueMemberN{Value: "1"}}
+	additions := []string{"#version :one"}
+	for i, name := range names {
+		attributeNames[fmt.Sprintf("#a%d", i)] = name
+		values[fmt.Sprintf(":v%d", i)] = &types.AttributeValueMemberN{Value: strconv.Itoa(d[name])}
+		additions = append(additions, fmt.Sprintf("#a%d :v%d", i, i))
+	}
+
+	return &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(statsTable),
+		Key:                       key(sourceTable),
+		UpdateExpression:          aws.String("ADD " + strings.Join(additions, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(#key)"),
+		ExpressionAttributeNames:  attributeNames,
+		ExpressionAttributeValues: values,
+	}
+}
+
+func (d Deltas) snapshot() Snapshot {
+	snapshot := Snapshot{Totals: model.NewQuestionTotals(), PerDay: make(map[string]int)}
+	for name, count := range d {
+		if count == 0 {
+			continue
+		}
+		switch {
+		case name == totalAttribute:
+			snapshot.Totals.TotalQuestionsCracked = count
+		case name == unknownAttribute:
+			snapshot.Totals.UnknownDifficultyCount = count
+		case name == favoritesAttribute:
+			snapshot.Totals.FavoritesCount = count
+		case name == solvedAgainAttribute:
+			snapshot.Totals.QuestionsSolvedMoreThanOnce = count
+		case strings.HasPrefix(name, reviewDayPrefix):
+			snapshot.Totals.ReviewsPerDay[strings.TrimPrefix(name, reviewDayPrefix)] = count
+		case strings.HasPrefix(name, dayPrefix):
+			snapshot.PerDay[strings.TrimPrefix(name, dayPrefix)] = count
+		case strings.HasPrefix(name, difficultyPrefix):
+			snapshot.Totals.QuestionsCrackedPerDifficulty[strings.TrimPrefix(name, difficultyPrefix)] = count
+		case strings.HasPrefix(name, minutesDayPrefix):
+			snapshot.Totals.MinutesSolvingPerDay[strings.TrimPrefix(name, minutesDayPrefix)] = count
+			snapshot.Totals.TotalMinutesSolving += count
+		case strings.HasPrefix(name, minutesDifficultyPrefix):
+			snapshot.Totals.AddMinutes(strings.TrimPrefix(name, minutesDifficultyPrefix), count, 0)
+		case strings.HasPrefix(name, timedDifficultyPrefix):
+			snapshot.Totals.AddMinutes(strings.TrimPrefix(name, timedDifficultyPrefix), 0, count)
+		case strings.HasPrefix(name, tagPrefix):
+			snapshot.Totals.QuestionsCrackedPerTag[strings.TrimPrefix(name, tagPrefix)] = count
+		case strings.HasPrefix(name, tagDifficultyPrefix):
+			// Difficulties never contain '#', tags might
+			rest := strings.TrimPrefix(name, tagDifficultyPrefix)
+			separator := strings.LastIndex(rest, "#")
+			if separator < 0 {
+				continue
+			}
+			tag, difficulty := rest[:separator], rest[separator+1:]
+			if _, ok := snapshot.Totals.QuestionsPerTagPerDifficulty[tag]; !ok {
+				snapshot.Totals.QuestionsPerTagPerDifficulty[tag] = make(map[string]int)
+			}
+			snapshot.Totals.QuestionsPerTagPerDifficulty[tag][difficulty] = count
+		}
+	}
+	return snapshot
+}
+
+// FromQuestions computes in memory the snapshot Seed would store for
+// questions
+func FromQuestions(questions []model.Question) Snapshot {
+	deltas := Deltas{}
+	for _, q := range questions {
+		deltas.Add(q, 1)
+	}
+	return deltas.snapshot()
+}
+
+// Load reads the statistics item of sourceTable, reporting false when it has
+// not been seeded yet
+func Load(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable string) (Snapshot, bool, error) {
+	item, ok, err := load(ctx, client, statsTable, sourceTable)
+	if err != nil || !ok {
+		return Snapshot{}, ok, err
+	}
+
+	snapshot := item.counters.snapshot()
+	snapshot.Version = item.version
+	snapshot.Stale, snapshot.StaleFrom = item.stale, item.staleFrom
+	return snapshot, true, nil
+}
+
+// storedItem is a statistics item as stored, before it becomes a Snapshot
+type storedItem struct {
+	counters  Deltas
+	version   int
+	stale     bool
+	staleFrom string
+}
+
+func load(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable string) (storedItem, bool, error) {
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(statsTable),
+		Key:            key(sourceTable),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return storedItem{}, false, fmt.Errorf("failed to get statistics item: %w", err)
+	}
+	if output.Item == nil {
+		return storedItem{}, false, nil
+	}
+
+	item := storedItem{counters: Deltas{}}
+	for name, value := range output.Item {
+		n, ok := value.(*types.AttributeValueMemberN)
+		if !ok {
+			continue
+		}
+		count, err := strconv.Atoi(n.Value)
+		if err != nil {
+			return storedItem{}, false, fmt.Errorf("invalid counter %s=%q: %w", name, n.Value, err)
+		}
+		item.counters[name] = count
+	}
+	item.version = item.counters[versionAttribute]
+	delete(item.counters, versionAttribute)
+	if staleFrom, ok := output.Item[staleFromAttribute].(*types.AttributeValueMemberS); ok {
+		item.stale, item.staleFrom = true, staleFrom.Value
+	}
+	return item, true, nil
+}
+
+// Seed replaces the statistics item of sourceTable with questions, which must
+// come from a full scan of it started after version was read with Load. It fails with ErrSeedConflict when the item
+// has been created or updated since, leaving it as it is.
+func Seed(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable string, version int, questions []model.Question) error {
+	deltas := Deltas{}
+	for _, q := range questions {
+		deltas.Add(q, 1)
+	}
+
+	item := key(sourceTable)
+	for name, count := range deltas {
+		if count != 0 {
+			item[name] = &types.AttributeValueMemberN{Value: strconv.Itoa(count)}
+		}
+	}
+	// The stream consumer only updates existing items, so an empty table
+	// still needs its total
+	item[totalAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(deltas[totalAttribute])}
+	item[versionAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(version + 1)}
+
+	// Items seeded before versioning have no version either; the first stream
+	// update gives them one
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(statsTable),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]string{"#version": versionAttribute},
+	}
+	if version > 0 {
+		input.ConditionExpression = aws.String("attribute_exists(#key) AND #version = :version")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		}
+	}
+
+	_, err := client.PutItem(ctx, input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrSeedConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put statistics item: %w", err)
+	}
+	return nil
+}
+
+// MarkStale flags the daily counters of sourceTable from date on, in
+// DateLayout, as needing a Recalculate; "" flags every counter. An earlier
+// mark is kept, and an item that isn't seeded yet is left alone, since
+// seeding covers it.
+func MarkStale(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable, date string) error {
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(statsTable),
+		Key:                 key(sourceTable),
+		UpdateExpression:    aws.String("SET #staleFrom = :date ADD #version :one"),
+		ConditionExpression: aws.String("attribute_exists(#key) AND (attribute_not_exists(#staleFrom) OR #staleFrom > :date)"),
+		ExpressionAttributeNames: map[string]string{
+			"#key": KeyAttribute, "#staleFrom": staleFromAttribute, "#version": versionAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":date": &types.AttributeValueMemberS{Value: date},
+			":one":  &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark statistics item stale: %w", err)
+	}
+	return nil
+}
+
+// MarkStaleIfBackdated marks the daily counters of sourceTable stale from
+// date, for the update and delete handlers, when the item already counts a
+// later day. Edits of the newest days need no recalculation.
+func MarkStaleIfBackdated(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable, date string) error {
+	snapshot, ok, err := Load(ctx, client, statsTable, sourceTable)
+	if err != nil || !ok || date >= snapshot.NewestDay() {
+		return err
+	}
+	return MarkStale(ctx, client, statsTable, sourceTable, date)
+}
+
+// Recalculate brings the statistics item of sourceTable back in line with
+// questions, read after version was read with Load, without rewriting the
+// whole item: only the daily counters from date from on are recomputed, so
+// questions need only hold those solved or reviewed since. With from "" every
+// counter is, from a full scan. The stale mark is cleared once every
+// correction is written. Like Seed, it fails with ErrSeedConflict when the
+// item changed since version.
+func Recalculate(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable string, version int, from string, questions []model.Question) error {
+	stored, ok, err := load(ctx, client, statsTable, sourceTable)
+	if err != nil {
+		return err
+	}
+	if !ok || stored.version != version {
+		return ErrSeedConflict
+	}
+
+	fresh := Deltas{}
+	for _, q := range questions {
+		fresh.Add(q, 1)
+	}
+	corrections := Deltas{}
+	for _, counters := range []Deltas{fresh, stored.counters} {
+		for name := range counters {
+			if inRange(name, from) {
+				corrections[name] = fresh[name] - stored.counters[name]
+			}
+		}
+	}
+
+	var names []string
+	for name, correction := range corrections {
+		if correction != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// Every update bumps the version, so each chunk expects the one before
+	for start := 0; start == 0 || start < len(names); start += maxCorrectionsPerUpdate {
+		chunk := Deltas{}
+		for _, name := range names[start:min(start+maxCorrectionsPerUpdate, len(names))] {
+			chunk[name] = corrections[name]
+		}
+		last := start+maxCorrectionsPerUpdate >= len(names)
+		if err := writeCorrections(ctx, client, statsTable, sourceTable, version, chunk, last); err != nil {
+			return err
+		}
+		version++
+	}
+	return nil
+}
+
+// writeCorrections adds chunk to the counters if the item is still at
+// version, clearing the stale mark when last is set
+func writeCorrections(ctx context.Context, client awsutil.DynamoAPI, statsTable, sourceTable string, version int, chunk Deltas, last bool) error {
+	input := chunk.Update(statsTable, sourceTable)
+	if input == nil {
+		// Nothing to correct, but the version still moves so that a
+		// concurrent Seed or Recalculate starts over
+		input = &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(statsTable),
+			Key:                       key(sourceTable),
+			UpdateExpression:          aws.String("ADD #version :one"),
+			ExpressionAttributeNames:  map[string]string{"#key": KeyAttribute, "#version": versionAttribute},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": &types.AttributeValueMemberN{Value: "1"}},
+		}
+	}
+	if last {
+		input.UpdateExpression = aws.String(aws.ToString(input.UpdateExpression) + " REMOVE #staleFrom")
+		input.ExpressionAttributeNames["#staleFrom"] = staleFromAttribute
+	}
+	input.ConditionExpression = aws.String("attribute_exists(#key) AND #version = :version")
+	input.ExpressionAttributeValues[":version"] = &types.AttributeValueMemberN{Value: strconv.Itoa(version)}
+	if version == 0 {
+		input.ConditionExpression = aws.String("attribute_exists(#key) AND attribute_not_exists(#version)")
+		delete(input.ExpressionAttributeValues, ":version")
+	}
+
+	_, err := client.UpdateItem(ctx, input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrSeedConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to correct statistics item: %w", err)
+	}
+	return nil
+}
+
+// inRange reports whether Recalculate from date from rewrites a counter:
+// daily counters dated from on, or every counter when from is ""
+func inRange(name, from string) bool {
+	if from == "" {
+		return true
+	}
+	for _, prefix := range []string{dayPrefix, reviewDayPrefix, minutesDayPrefix} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix) >= from
+		}
+	}
+	return false
+}
+
+func key(sourceTable string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		KeyAttribute: &types.AttributeValueMemberS{Value: sourceTable},
+	}
+}
+
+// TableFromARN extracts the table name from a stream's event source ARN,
+// arn:aws:dynamodb:region:account:table/NAME/stream/LABEL
+func TableFromARN(arn string) (string, error) {
+	_, resource, ok := strings.Cut(arn, ":table/")
+	if !ok {
+		return "", fmt.Errorf("not a DynamoDB table ARN: %q", arn)
+	}
+	table, _, _ := strings.Cut(resource, "/")
+	return table, nil
+}
+
+// QuestionFromImage decodes a stream image with the same rules as a scan
+func QuestionFromImage(image map[string]events.DynamoDBAttributeValue) (model.Question, error) {
+	if len(image) == 0 {
+		return model.Question{}, errors.New("record has no image, the stream must use NEW_AND_OLD_IMAGES")
+	}
+
+	item := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		item[name] = attributeValue(value)
+	}
+	questions, err := model.QuestionsFromItems([]map[string]types.AttributeValue{item})
+	if err != nil {
+		return model.Question{}, err
+	}
+	return questions[0], nil
+}
+
+func attributeValue(value events.DynamoDBAttributeValue) types.AttributeValue {
+	switch value.DataType() {
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, 0, len(value.List()))
+		for _, element := range value.List() {
+			list = append(list, attributeValue(element))
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case events.DataTypeMap:
+		m := make(map[string]types.AttributeValue, len(value.Map()))
+		for name, element := range value.Map() {
+			m[name] = attributeValue(element)
+		}
+		return &types.AttributeValueMemberM{Value: m}
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}
+	default:
+		return &types.AttributeValueMemberNULL{Value: true}
+	}
+}
@@ -0,0 +1,271 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+)
+
+const (
+	statsTable  = "stats"
+	sourceTable = "questions"
+)
+
+func minutes(n int) *int { return &n }
+
+func history() []model.Question {
+	return []model.Question{
+		{Name: "two-sum", Date: "2024-03-01", Difficulty: "Easy", Tags: []string{"Array"}, Attempts: 1, MinutesToSolve: minutes(10)},
+		{Name: "clone-graph", Date: "2024-03-03", Difficulty: "Medium", Tags: []string{"Graph", "BFS"}, Attempts: 2,
+			ReviewDates: []string{"2024-03-09"}, MinutesToSolve: minutes(30)},
+		{Name: "lru-cache", Date: "2024-03-05", Difficulty: "Medium", Tags: []string{"Design"}, Attempts: 1},
+		{Name: "word-ladder", Date: "2024-03-08", Difficulty: "Hard", Tags: []string{"Graph"}, Attempts: 1, MinutesToSolve: minutes(55)},
+	}
+}
+
+func seeded(t *testing.T, questions []model.Question) *dynamotest.Fake {
+	t.Helper()
+	fake := dynamotest.New(statsTable)
+	fake.Keys = map[string][]string{statsTable: {KeyAttribute}}
+	if err := Seed(context.Background(), fake, statsTable, sourceTable, 0, questions); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	return fake
+}
+
+func mustLoad(t *testing.T, fake *dynamotest.Fake) Snapshot {
+	t.Helper()
+	snapshot, ok, err := Load(context.Background(), fake, statsTable, sourceTable)
+	if err != nil || !ok {
+		t.Fatalf("Load = %v, %v", ok, err)
+	}
+	return snapshot
+}
+
+func assertMatches(t *testing.T, got Snapshot, questions []model.Question) {
+	t.Helper()
+	want := FromQuestions(questions)
+	if !reflect.DeepEqual(got.Totals, want.Totals) {
+		t.Errorf("totals = %+v, want %+v", got.Totals, want.Totals)
+	}
+	if !reflect.DeepEqual(got.PerDay, want.PerDay) {
+		t.Errorf("per day = %v, want %v", got.PerDay, want.PerDay)
+	}
+}
+
+// since keeps the questions a range read from date from returns: those
+// solved from then on and those with reviews
+func since(questions []model.Question, from string) []model.Question {
+	var recent []model.Question
+	for _, q := range questions {
+		if q.Date >= from || len(q.ReviewDates) > 0 {
+			recent = append(recent, q)
+		}
+	}
+	return recent
+}
+
+func TestRecalculateAfterMissedDelete(t *testing.T) {
+	ctx := context.Background()
+	fake := seeded(t, history())
+
+	// The stream record of the delete could not be applied, so every
+	// counter is suspect
+	remaining := append(history()[:1], history()[2:]...)
+	if err := MarkStale(ctx, fake, statsTable, sourceTable, ""); err != nil {
+		t.Fatalf("MarkStale: %v", err)
+	}
+	snapshot := mustLoad(t, fake)
+	if !snapshot.Stale || snapshot.StaleFrom != "" {
+		t.Fatalf("stale %v from %q, want the whole history", snapshot.Stale, snapshot.StaleFrom)
+	}
+
+	if err := Recalculate(ctx, fake, statsTable, sourceTable, snapshot.Version, snapshot.StaleFrom, remaining); err != nil {
+		t.Fatalf("Recalculate: %v", err)
+	}
+	recalculated := mustLoad(t, fake)
+	if recalculated.Stale {
+		t.Error("still stale after Recalculate")
+	}
+	if recalculated.Version <= snapshot.Version {
+		t.Errorf("version %d, want past %d", recalculated.Version, snapshot.Version)
+	}
+	assertMatches(t, recalculated, remaining)
+}
+
+func TestRecalculateRange(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+	}{
+		{"from a solved date", "2024-03-05"},
+		{"from a day with a review only", "2024-03-09"},
+		{"from before every question", "2024-02-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := seeded(t, history())
+
+			// The daily counters from every mark on drifted
+			drift := Deltas{dayPrefix + "2024-03-09": 2, reviewDayPrefix + "2024-03-09": -1, minutesDayPrefix + "2024-03-10": 7}
+			if _, err := fake.UpdateItem(ctx, drift.Update(statsTable, sourceTable)); err != nil {
+				t.Fatalf("UpdateItem: %v", err)
+			}
+			if err := MarkStale(ctx, fake, statsTable, sourceTable, tt.from); err != nil {
+				t.Fatalf("MarkStale: %v", err)
+			}
+			snapshot := mustLoad(t, fake)
+
+			// Only what a range read returns, yet the result matches a rebuild
+			if err := Recalculate(ctx, fake, statsTable, sourceTable, snapshot.Version, tt.from, since(history(), tt.from)); err != nil {
+				t.Fatalf("Recalculate: %v", err)
+			}
+			recalculated := mustLoad(t, fake)
+			if recalculated.Stale {
+				t.Error("still stale after Recalculate")
+			}
+			assertMatches(t, recalculated, history())
+		})
+	}
+}
+
+func TestRecalculateOnlyRewritesDaysFromTheMark(t *testing.T) {
+	ctx := context.Background()
+	fake := seeded(t, history())
+	if err := MarkStale(ctx, fake, statsTable, sourceTable, "2024-03-05"); err != nil {
+		t.Fatalf("MarkStale: %v", err)
+	}
+	snapshot := mustLoad(t, fake)
+
+	// A question dropped before the mark keeps its day and its whole-history
+	// counts, which only the stream or a full recalculation change
+	remaining := history()[1:]
+	if err := Recalculate(ctx, fake, statsTable, sourceTable, snapshot.Version, snapshot.StaleFrom, remaining); err != nil {
+		t.Fatalf("Recalculate: %v", err)
+	}
+	got := mustLoad(t, fake)
+	if got.PerDay["2024-03-01"] != 1 {
+		t.Errorf("per day before the mark = %v, want it untouched", got.PerDay)
+	}
+	if got.Totals.TotalQuestionsCracked != 4 || got.Totals.QuestionsCrackedPerDifficulty["Easy"] != 1 {
+		t.Errorf("totals = %+v, want them untouched", got.Totals)
+	}
+}
+
+func TestRecalculateConflict(t *testing.T) {
+	ctx := context.Background()
+	fake := seeded(t, history())
+	if err := MarkStale(ctx, fake, statsTable, sourceTable, "2024-03-03"); err != nil {
+		t.Fatalf("MarkStale: %v", err)
+	}
+	snapshot := mustLoad(t, fake)
+
+	// The stream applies a record while the scan runs
+	deltas := Deltas{}
+	deltas.Add(model.Question{Name: "jump-game", Date: "2024-03-10", Difficulty: "Medium", Attempts: 1}, 1)
+	if _, err := fake.UpdateItem(ctx, deltas.Update(statsTable, sourceTable)); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+
+	err := Recalculate(ctx, fake, statsTable, sourceTable, snapshot.Version, snapshot.StaleFrom, history())
+	if !errors.Is(err, ErrSeedConflict) {
+		t.Fatalf("Recalculate = %v, want ErrSeedConflict", err)
+	}
+	if !mustLoad(t, fake).Stale {
+		t.Error("stale mark cleared by a conflicting Recalculate")
+	}
+}
+
+func TestRecalculateInChunks(t *testing.T) {
+	ctx := context.Background()
+	fake := seeded(t, nil)
+	if err := MarkStale(ctx, fake, statsTable, sourceTable, ""); err != nil {
+		t.Fatalf("MarkStale: %v", err)
+	}
+	snapshot := mustLoad(t, fake)
+
+	var questions []model.Question
+	for day := 1; day <= 28; day++ {
+		date := fmt.Sprintf("2024-02-%02d", day)
+		questions = append(questions, model.Question{Name: date, Date: date, Difficulty: "Easy", Attempts: 1, MinutesToSolve: minutes(day)})
+	}
+	if err := Recalculate(ctx, fake, statsTable, sourceTable, snapshot.Version, "", questions); err != nil {
+		t.Fatalf("Recalculate: %v", err)
+	}
+	if got := fake.Count("UpdateItem"); got < 3 {
+		t.Errorf("UpdateItem called %d times, want the corrections split", got)
+	}
+	got := mustLoad(t, fake)
+	if got.Stale {
+		t.Error("still stale after Recalculate")
+	}
+	assertMatches(t, got, questions)
+}
+
+func TestMarkStale(t *testing.T) {
+	ctx := context.Background()
+
+	unseeded := dynamotest.New(statsTable)
+	unseeded.Keys = map[string][]string{statsTable: {KeyAttribute}}
+	if err := MarkStale(ctx, unseeded, statsTable, sourceTable, "2024-03-03"); err != nil {
+		t.Fatalf("MarkStale on an unseeded item: %v", err)
+	}
+	if items := unseeded.Items(statsTable); len(items) != 0 {
+		t.Errorf("items = %v, want none, seeding covers it", items)
+	}
+
+	fake := seeded(t, history())
+	for _, date := range []string{"2024-03-05", "2024-03-08", "2024-03-03"} {
+		if err := MarkStale(ctx, fake, statsTable, sourceTable, date); err != nil {
+			t.Fatalf("MarkStale %s: %v", date, err)
+		}
+	}
+	if got := mustLoad(t, fake).StaleFrom; got != "2024-03-03" {
+		t.Errorf("stale from %q, want the earliest mark", got)
+	}
+	if err := MarkStale(ctx, fake, statsTable, sourceTable, ""); err != nil {
+		t.Fatalf("MarkStale whole history: %v", err)
+	}
+	if got := mustLoad(t, fake).StaleFrom; got != "" {
+		t.Errorf("stale from %q, want the whole history", got)
+	}
+}
+
+func TestMarkStaleIfBackdated(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      string
+		wantStale bool
+	}{
+		{"before the newest solve", "2024-03-05", true},
+		{"before the newest review", "2024-03-08", true},
+		{"on the newest day", "2024-03-09", false},
+		{"after it", "2024-03-12", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := seeded(t, history())
+			if err := MarkStaleIfBackdated(context.Background(), fake, statsTable, sourceTable, tt.date); err != nil {
+				t.Fatalf("MarkStaleIfBackdated: %v", err)
+			}
+			snapshot := mustLoad(t, fake)
+			if snapshot.Stale != tt.wantStale || (tt.wantStale && snapshot.StaleFrom != tt.date) {
+				t.Errorf("stale %v from %q, want %v from %q", snapshot.Stale, snapshot.StaleFrom, tt.wantStale, tt.date)
+			}
+		})
+	}
+
+	unseeded := dynamotest.New(statsTable)
+	if err := MarkStaleIfBackdated(context.Background(), unseeded, statsTable, sourceTable, "2024-03-05"); err != nil {
+		t.Fatalf("MarkStaleIfBackdated on an unseeded item: %v", err)
+	}
+	if got := unseeded.Count("UpdateItem"); got != 0 {
+		t.Errorf("UpdateItem called %d times, want none before seeding", got)
+	}
+}
@@ -0,0 +1,149 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	KindMissing    = "missing"
+	KindUnexpected = "unexpected"
+	KindChanged    = "changed"
+)
+
+// epsilon keeps a difference of exactly the tolerance, like 12.4 against
+// 12.35, from failing on the error of the subtraction itself
+const epsilon = 1e-9
+
+// Difference is one disagreement between the two documents, located by a
+// JSON-path style path such as $.questionsCrackedPerDay[3].count
+type Difference struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Left  any    `json:"left,omitempty"`
+	Right any    `json:"right,omitempty"`
+}
+
+// Result summarizes a comparison. Sections are the top-level fields.
+type Result struct {
+	Pass              bool         `json:"pass"`
+	IdenticalSections int          `json:"identicalSections"`
+	DifferentSections int          `json:"differentSections"`
+	Differences       []Difference `json:"differences"`
+}
+
+// Compare marshals both values to JSON and walks them side by side. Numbers
+// within tolerance of each other are considered equal, which absorbs float
+// rounding between implementations.
+func Compare(left, right any, tolerance float64) (Result, error) {
+	leftDoc, err := normalize(left)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to normalize left value: %w", err)
+	}
+	rightDoc, err := normalize(right)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to normalize right value: %w", err)
+	}
+
+	result := Result{Differences: []Difference{}}
+	leftObject, leftOK := leftDoc.(map[string]any)
+	rightObject, rightOK := rightDoc.(map[string]any)
+	if !leftOK || !rightOK {
+		result.Differences = walk("$", leftDoc, rightDoc, tolerance, result.Differences)
+		result.Pass = len(result.Differences) == 0
+		return result, nil
+	}
+
+	for _, key := range keys(leftObject, rightObject) {
+		before := len(result.Differences)
+		result.Differences = walkField("$", key, leftObject, rightObject, tolerance, result.Differences)
+		if len(result.Differences) == before {
+			result.IdenticalSections++
+		} else {
+			result.DifferentSections++
+		}
+	}
+	result.Pass = len(result.Differences) == 0
+	return result, nil
+}
+
+func normalize(value any) (any, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	err = json.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+func walkField(path, key string, left, right map[string]any, tolerance float64, diffs []Difference) []Difference {
+	fieldPath := path + "." + key
+	leftValue, inLeft := left[key]
+	rightValue, inRight := right[key]
+	switch {
+	case !inRight:
+		return append(diffs, Difference{Path: fieldPath, Kind: KindMissing, Left: leftValue})
+	case !inLeft:
+		return append(diffs, Difference{Path: fieldPath, Kind: KindUnexpected, Right: rightValue})
+	}
+	return walk(fieldPath, leftValue, rightValue, tolerance, diffs)
+}
+
+func walk(path string, left, right any, tolerance float64, diffs []Difference) []Difference {
+	switch l := left.(type) {
+	case map[string]any:
+		r, ok := right.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, key := range keys(l, r) {
+			diffs = walkField(path, key, l, r, tolerance, diffs)
+		}
+		return diffs
+	case []any:
+		r, ok := right.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(l) || i < len(r); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(r):
+				diffs = append(diffs, Difference{Path: itemPath, Kind: KindMissing, Left: l[i]})
+			case i >= len(l):
+				diffs = append(diffs, Difference{Path: itemPath, Kind: KindUnexpected, Right: r[i]})
+			default:
+				diffs = walk(itemPath, l[i], r[i], tolerance, diffs)
+			}
+		}
+		return diffs
+	case float64:
+		if r, ok := right.(float64); ok && math.Abs(l-r) <= tolerance+epsilon {
+			return diffs
+		}
+	default:
+		if left == right {
+			return diffs
+		}
+	}
+	return append(diffs, Difference{Path: path, Kind: KindChanged, Left: left, Right: right})
+}
+
+// keys returns the union of both objects' keys in sorted order
+func keys(left, right map[string]any) []string {
+	seen := make(map[string]bool)
+	var all []string
+	for _, object := range []map[string]any{left, right} {
+		for key := range object {
+			if !seen[key] {
+				seen[key] = true
+				all = append(all, key)
+			}
+		}
+	}
+	sort.Strings(all)
+	return all
+}
@@ -0,0 +1,88 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name          string
+		left, right   any
+		tolerance     float64
+		want          []Difference
+		wantIdentical int
+		wantDifferent int
+	}{
+		{"identical objects",
+			map[string]any{"total": 3, "perDay": map[string]int{"2024-03-01": 3}},
+			map[string]any{"total": 3, "perDay": map[string]int{"2024-03-01": 3}},
+			0, []Difference{}, 2, 0},
+		{"floats within tolerance",
+			map[string]any{"average": 12.35},
+			map[string]any{"average": 12.4},
+			0.05, []Difference{}, 1, 0},
+		{"floats beyond tolerance",
+			map[string]any{"average": 12.3},
+			map[string]any{"average": 12.4},
+			0.05, []Difference{{Path: "$.average", Kind: KindChanged, Left: 12.3, Right: 12.4}}, 0, 1},
+		{"missing and unexpected fields",
+			map[string]any{"a": 1, "b": 2},
+			map[string]any{"b": 2, "c": 3},
+			0, []Difference{
+				{Path: "$.a", Kind: KindMissing, Left: 1.0},
+				{Path: "$.c", Kind: KindUnexpected, Right: 3.0},
+			}, 1, 2},
+		{"nested paths",
+			map[string]any{"perTag": map[string]any{"graph": map[string]int{"Easy": 1, "Hard": 2}}},
+			map[string]any{"perTag": map[string]any{"graph": map[string]int{"Easy": 1, "Hard": 3}}},
+			0, []Difference{{Path: "$.perTag.graph.Hard", Kind: KindChanged, Left: 2.0, Right: 3.0}}, 0, 1},
+		{"arrays of different lengths",
+			map[string]any{"days": []int{1, 2, 3}},
+			map[string]any{"days": []int{1, 5}},
+			0, []Difference{
+				{Path: "$.days[1]", Kind: KindChanged, Left: 2.0, Right: 5.0},
+				{Path: "$.days[2]", Kind: KindMissing, Left: 3.0},
+			}, 0, 1},
+		{"different types",
+			map[string]any{"tags": []string{"graph"}, "name": "a"},
+			map[string]any{"tags": map[string]int{"graph": 1}, "name": nil},
+			0, []Difference{
+				{Path: "$.name", Kind: KindChanged, Left: "a"},
+				{Path: "$.tags", Kind: KindChanged, Left: []any{"graph"}, Right: map[string]any{"graph": 1.0}},
+			}, 0, 2},
+		{"non-object roots",
+			[]int{1, 2},
+			[]int{1, 3},
+			0, []Difference{{Path: "$[1]", Kind: KindChanged, Left: 2.0, Right: 3.0}}, 0, 0},
+		{"structs by their JSON names",
+			struct {
+				Total int `json:"total"`
+			}{3},
+			map[string]any{"total": 3},
+			0, []Difference{}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Compare(tt.left, tt.right, tt.tolerance)
+			if err != nil {
+				t.Fatalf("Compare: %v", err)
+			}
+			if !reflect.DeepEqual(result.Differences, tt.want) {
+				t.Errorf("differences = %#v, want %#v", result.Differences, tt.want)
+			}
+			if result.Pass != (len(tt.want) == 0) {
+				t.Errorf("pass = %v with %d differences", result.Pass, len(tt.want))
+			}
+			if result.IdenticalSections != tt.wantIdentical || result.DifferentSections != tt.wantDifferent {
+				t.Errorf("sections identical %d, different %d, want %d and %d", result.IdenticalSections, result.DifferentSections, tt.wantIdentical, tt.wantDifferent)
+			}
+		})
+	}
+}
+
+func TestCompareUnmarshalable(t *testing.T) {
+	if _, err := Compare(map[string]any{"f": func() {}}, map[string]any{}, 0); err == nil {
+		t.Error("Compare succeeded on a value JSON can't encode")
+	}
+}
@@ -0,0 +1,111 @@
+package leetcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultEndpoint is LeetCode's public GraphQL API
+const DefaultEndpoint = "https://leetcode.com/graphql"
+
+// DefaultTimeout keeps a slow LeetCode from holding up a write for long
+const DefaultTimeout = 3 * time.Second
+
+const questionQuery = `query questionData($titleSlug: String!) {
+  question(titleSlug: $titleSlug) {
+    questionFrontendId
+    difficulty
+    topicTags { name }
+  }
+}`
+
+// Doer sends HTTP requests; *http.Client satisfies it, tests can stub it
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client looks questions up on LeetCode
+type Client struct {
+	HTTP     Doer
+	Endpoint string
+}
+
+// NewClient builds a client for DefaultEndpoint with DefaultTimeout
+func NewClient() *Client {
+	return &Client{HTTP: &http.Client{Timeout: DefaultTimeout}, Endpoint: DefaultEndpoint}
+}
+
+// Problem is what LeetCode knows about a question
+type Problem struct {
+	// ID is the number LeetCode shows in front of the title
+	ID         int
+	Difficulty string
+	Tags       []string
+}
+
+// ErrNotFound is returned for slugs LeetCode has no question for
+var ErrNotFound = errors.New("no LeetCode question with this slug")
+
+// Problem fetches the question with titleSlug, like "two-sum"
+func (c *Client) Problem(ctx context.Context, titleSlug string) (Problem, error) {
+	payload, err := json.Marshal(map[string]any{
+		"query":     questionQuery,
+		"variables": map[string]string{"titleSlug": titleSlug},
+	})
+	if err != nil {
+		return Problem{}, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return Problem{}, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	// LeetCode rejects GraphQL requests without a referer of its own
+	request.Header.Set("Referer", "https://leetcode.com/problems/"+titleSlug+"/")
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return Problem{}, fmt.Errorf("failed to call LeetCode: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Problem{}, fmt.Errorf("LeetCode answered with status %d", response.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Question *struct {
+				QuestionFrontendID string `json:"questionFrontendId"`
+				Difficulty         string `json:"difficulty"`
+				TopicTags          []struct {
+					Name string `json:"name"`
+				} `json:"topicTags"`
+			} `json:"question"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&body); err != nil {
+		return Problem{}, fmt.Errorf("failed to decode LeetCode response: %w", err)
+	}
+	question := body.Data.Question
+	if question == nil {
+		return Problem{}, ErrNotFound
+	}
+
+	problem := Problem{Difficulty: question.Difficulty}
+	// Frontend IDs are numbers for every regular question
+	if id, err := strconv.Atoi(question.QuestionFrontendID); err == nil {
+		problem.ID = id
+	}
+	for _, tag := range question.TopicTags {
+		problem.Tags = append(problem.Tags, tag.Name)
+	}
+	return problem, nil
+}
@@ -0,0 +1,112 @@
+package leetcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// doerFunc stubs the HTTP client with a function
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// respond answers every request with status and body
+func respond(status int, body string) doerFunc {
+	return func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+}
+
+const twoSum = `{"data":{"question":{"questionFrontendId":"1","difficulty":"Easy","topicTags":[{"name":"Array"},{"name":"Hash Table"}]}}}`
+
+func TestProblemRequest(t *testing.T) {
+	var got *http.Request
+	var variables map[string]string
+	client := &Client{Endpoint: "https://leetcode.test/graphql", HTTP: doerFunc(func(request *http.Request) (*http.Response, error) {
+		got = request
+		var payload struct {
+			Query     string            `json:"query"`
+			Variables map[string]string `json:"variables"`
+		}
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request body: %v", err)
+		}
+		variables = payload.Variables
+		return respond(http.StatusOK, twoSum)(request)
+	})}
+
+	if _, err := client.Problem(context.Background(), "two-sum"); err != nil {
+		t.Fatalf("Problem: %v", err)
+	}
+	if got.Method != http.MethodPost || got.URL.String() != "https://leetcode.test/graphql" {
+		t.Errorf("request = %s %s, want a POST to the endpoint", got.Method, got.URL)
+	}
+	if got.Header.Get("Content-Type") != "application/json" || got.Header.Get("Referer") != "https://leetcode.com/problems/two-sum/" {
+		t.Errorf("headers = %v, want JSON with the problem's referer", got.Header)
+	}
+	if want := map[string]string{"titleSlug": "two-sum"}; !reflect.DeepEqual(variables, want) {
+		t.Errorf("variables = %v, want %v", variables, want)
+	}
+}
+
+func TestProblem(t *testing.T) {
+	transportErr := errors.New("connection reset")
+	tests := []struct {
+		name    string
+		http    doerFunc
+		want    Problem
+		wantErr error
+	}{
+		{"found", respond(http.StatusOK, twoSum), Problem{ID: 1, Difficulty: "Easy", Tags: []string{"Array", "Hash Table"}}, nil},
+		{"without tags", respond(http.StatusOK, `{"data":{"question":{"questionFrontendId":"70","difficulty":"Easy","topicTags":[]}}}`),
+			Problem{ID: 70, Difficulty: "Easy"}, nil},
+		{"non-numeric ID", respond(http.StatusOK, `{"data":{"question":{"questionFrontendId":"LCP 01","difficulty":"Medium","topicTags":[]}}}`),
+			Problem{Difficulty: "Medium"}, nil},
+		{"unknown slug", respond(http.StatusOK, `{"data":{"question":null}}`), Problem{}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Client{HTTP: tt.http, Endpoint: DefaultEndpoint}).Problem(context.Background(), "two-sum")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Problem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	failures := []struct {
+		name string
+		http doerFunc
+	}{
+		{"transport error", func(*http.Request) (*http.Response, error) { return nil, transportErr }},
+		{"server error", respond(http.StatusInternalServerError, "")},
+		{"rate limited", respond(http.StatusTooManyRequests, `{"data":{"question":null}}`)},
+		{"malformed body", respond(http.StatusOK, "<html>")},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Client{HTTP: tt.http, Endpoint: DefaultEndpoint}).Problem(context.Background(), "two-sum")
+			if err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want a lookup failure", err)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	client := NewClient()
+	httpClient, ok := client.HTTP.(*http.Client)
+	if !ok || httpClient.Timeout != DefaultTimeout || client.Endpoint != DefaultEndpoint {
+		t.Errorf("NewClient = %+v, want an http.Client with the default timeout and endpoint", client)
+	}
+}
@@ -0,0 +1,66 @@
+package legacystats
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/model"
+)
+
+// Statistics is what the statistics endpoint computes from a full scan. It
+// is kept apart from the lambda so the dashboard reports the same numbers,
+// and so the diff endpoint can compare it with the aggregate package until
+// the two have agreed on production data for a while.
+type Statistics struct {
+	model.QuestionTotals
+	QuestionsCrackedPerDay map[string]int `json:"questionsCrackedPerDay"`
+	// GoalProgress is omitted when no questions goal is set. It always looks
+	// at the whole history, whatever the date range.
+	GoalProgress *goal.Progress `json:"goalProgress,omitempty"`
+	// MergedKeys lists the raw tag spellings folded into each canonical tag
+	MergedKeys map[string][]string `json:"mergedKeys"`
+}
+
+// Generate counts every question, folding its tags with tagAliases first
+func Generate(questions []model.Question, tagAliases map[string]string) Statistics {
+	stats := Statistics{
+		QuestionTotals:         model.NewQuestionTotals(),
+		QuestionsCrackedPerDay: make(map[string]int),
+	}
+
+	tags := alias.NewCanonicalizer(tagAliases)
+	for _, q := range questions {
+		q.Tags = tags.CanonicalAll(q.Tags)
+		stats.QuestionsCrackedPerDay[q.Date]++
+		stats.Add(q)
+	}
+	stats.MergedKeys = tags.MergedKeys()
+	return stats
+}
+
+// TrackGoal measures the questions solved per day against the daily goal in
+// goalsTable. A goal that can't be read is logged and reported as nil, like a
+// goal that isn't set.
+func TrackGoal(ctx context.Context, client awsutil.DynamoAPI, goalsTable string, perDay map[string]int) *goal.Progress {
+	g, ok, err := goal.Load(ctx, client, goalsTable)
+	if err != nil {
+		slog.Warn("Failed to load goal, omitting progress", "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	days := make(map[time.Time]int, len(perDay))
+	for date, count := range perDay {
+		if day, err := calendar.ParseDate(date); err == nil {
+			days[day] += count
+		}
+	}
+	return g.Track(g.QuestionsPerDay, days, calendar.Today(time.Now()))
+}
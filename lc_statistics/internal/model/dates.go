@@ -0,0 +1,23 @@
+package model
+
+import "veet-code-go/internal/calendar"
+
+// DateLayout is the layout question_solved_date is stored with. Older items
+// used calendar.DayFirstLayout and are normalized when read.
+const DateLayout = calendar.ISOLayout
+
+// FilterQuestions keeps the questions solved within the range. Questions
+// with an unparseable date are dropped once a range is given.
+func FilterQuestions(questions []Question, dateRange calendar.DateRange) []Question {
+	if dateRange.IsZero() {
+		return questions
+	}
+
+	filtered := []Question{}
+	for _, q := range questions {
+		if dateRange.ContainsDate(q.Date) {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,56 @@
+package model
+
+import "strings"
+
+// UnknownDifficulty is stored when a question's difficulty could not be inferred
+const UnknownDifficulty = "unknown"
+
+// DifficultyLookup resolves a question name to a difficulty, reporting false
+// when it has no answer
+type DifficultyLookup func(name string) (string, bool)
+
+// InferDifficulty returns explicit when it is set, otherwise the answer of the
+// first lookup that resolves name, otherwise UnknownDifficulty. Lookups are
+// tried in the order given, so callers list the most trusted source first.
+func InferDifficulty(name, explicit string, lookups ...DifficultyLookup) string {
+	if !IsUnknownDifficulty(explicit) {
+		return explicit
+	}
+	for _, lookup := range lookups {
+		if difficulty, ok := lookup(name); ok && !IsUnknownDifficulty(difficulty) {
+			return difficulty
+		}
+	}
+	return UnknownDifficulty
+}
+
+// MappingLookup resolves names from a name -> difficulty map supplied with a request
+func MappingLookup(mapping map[string]string) DifficultyLookup {
+	return func(name string) (string, bool) {
+		difficulty, ok := mapping[name]
+		return difficulty, ok
+	}
+}
+
+// IsUnknownDifficulty treats missing values like the explicit unknown marker
+func IsUnknownDifficulty(difficulty string) bool {
+	return strings.TrimSpace(difficulty) == "" || strings.EqualFold(difficulty, UnknownDifficulty)
+}
+
+// Difficulties are the difficulties LeetCode assigns
+var Difficulties = []string{"Easy", "Medium", "Hard"}
+
+// CanonicalDifficulty matches value against Difficulties ignoring case,
+// returning it in their spelling. Unknown values report false; blank ones and
+// the unknown marker come back as UnknownDifficulty.
+func CanonicalDifficulty(value string) (string, bool) {
+	if IsUnknownDifficulty(value) {
+		return UnknownDifficulty, true
+	}
+	for _, difficulty := range Difficulties {
+		if strings.EqualFold(strings.TrimSpace(value), difficulty) {
+			return difficulty, true
+		}
+	}
+	return "", false
+}
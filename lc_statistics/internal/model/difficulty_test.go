@@ -0,0 +1,89 @@
+package model
+
+import "testing"
+
+func TestIsUnknownDifficulty(t *testing.T) {
+	tests := []struct {
+		difficulty string
+		want       bool
+	}{
+		{"", true},
+		{"  ", true},
+		{"unknown", true},
+		{"Unknown", true},
+		{"Easy", false},
+		{"medium", false},
+	}
+	for _, tt := range tests {
+		if got := IsUnknownDifficulty(tt.difficulty); got != tt.want {
+			t.Errorf("IsUnknownDifficulty(%q) = %v, want %v", tt.difficulty, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalDifficulty(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"Easy", "Easy", true},
+		{"medium", "Medium", true},
+		{" HARD ", "Hard", true},
+		{"", UnknownDifficulty, true},
+		{"UNKNOWN", UnknownDifficulty, true},
+		{"Expert", "", false},
+		{"Med", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CanonicalDifficulty(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("CanonicalDifficulty(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestInferDifficulty(t *testing.T) {
+	enrichment := func(name string) (string, bool) {
+		switch name {
+		case "two-sum":
+			return "Easy", true
+		case "lru-cache":
+			// LeetCode answered, but without a difficulty
+			return "", true
+		}
+		return "", false
+	}
+	mapping := MappingLookup(map[string]string{
+		"two-sum":     "Medium",
+		"lru-cache":   "Medium",
+		"word-ladder": "Hard",
+		"jump-game":   "unknown",
+	})
+
+	tests := []struct {
+		name     string
+		question string
+		explicit string
+		want     string
+	}{
+		{"explicit beats every lookup", "two-sum", "Hard", "Hard"},
+		{"enrichment beats the mapping", "two-sum", "", "Easy"},
+		{"explicit unknown falls through", "two-sum", "Unknown", "Easy"},
+		{"blank enrichment falls through to the mapping", "lru-cache", " ", "Medium"},
+		{"mapping when enrichment has no answer", "word-ladder", "", "Hard"},
+		{"unknown in the mapping isn't an answer", "jump-game", "", UnknownDifficulty},
+		{"unknown when nothing resolves", "clone-graph", "", UnknownDifficulty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InferDifficulty(tt.question, tt.explicit, enrichment, mapping); got != tt.want {
+				t.Errorf("InferDifficulty(%q, %q) = %q, want %q", tt.question, tt.explicit, got, tt.want)
+			}
+		})
+	}
+
+	if got := InferDifficulty("two-sum", ""); got != UnknownDifficulty {
+		t.Errorf("InferDifficulty without lookups = %q, want %q", got, UnknownDifficulty)
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+// BooleanFlags maps the flag names clients use to the question attributes
+// they are stored in. Only flags listed here can be toggled.
+var BooleanFlags = map[string]string{
+	"needsReview": "needs_review",
+	"archived":    "archived",
+	"favorite":    "favorite",
+}
+
+// VersionAttribute is incremented on every flag change
+const VersionAttribute = "version"
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/user"
+)
+
+// NameAttribute is the sort key of the questions table, under the owner's
+// user.Attribute
+const NameAttribute = "question_name"
+
+// QuestionKey is the key of the question called name of the user in ctx
+func QuestionKey(ctx context.Context, name string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		user.Attribute: &types.AttributeValueMemberS{Value: user.Owner(ctx)},
+		NameAttribute:  &types.AttributeValueMemberS{Value: name},
+	}
+}
+
+// ItemKey is the key of a stored question item, whoever owns it
+func ItemKey(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		user.Attribute: item[user.Attribute],
+		NameAttribute:  item[NameAttribute],
+	}
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/calendar"
+)
+
+type Question struct {
+	Name       string   `dynamodbav:"question_name"`
+	Date       string   `dynamodbav:"question_solved_date"`
+	Difficulty string   `dynamodbav:"difficulty"`
+	Tags       []string `json:"tags" dynamodbav:"-"`
+	// Favorite is set through the flags endpoint; absent means false
+	Favorite bool `json:"favorite" dynamodbav:"favorite"`
+	// CreatedAt is when the item was written, in RFC3339 UTC. Items written
+	// before it was recorded have none.
+	CreatedAt string `json:"createdAt,omitempty" dynamodbav:"created_at,omitempty"`
+	// URL and Notes are optional and empty on items written without them
+	URL   string `json:"url,omitempty" dynamodbav:"url,omitempty"`
+	Notes string `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
+	// MinutesToSolve is nil when the time spent was not recorded
+	MinutesToSolve *int `json:"minutesToSolve,omitempty" dynamodbav:"minutes_to_solve,omitempty"`
+	// Attempts counts every solve, the first included. Items from before
+	// attempts were recorded have been solved once.
+	Attempts int `json:"attempts" dynamodbav:"attempts"`
+	// ReviewDates are the dates of the solves after the first, in the order
+	// they were recorded
+	ReviewDates []string `json:"reviewDates,omitempty" dynamodbav:"solve_dates,omitempty"`
+	// QuestionID is LeetCode's number for the question, when it was looked up
+	QuestionID int `json:"questionId,omitempty" dynamodbav:"question_id,omitempty"`
+}
+
+// SetOptionalAttributes adds the url and notes attributes to item when they
+// are not blank, and minutes_to_solve when it was given
+func SetOptionalAttributes(item map[string]types.AttributeValue, link, notes string, minutesToSolve *int) {
+	if link = strings.TrimSpace(link); link != "" {
+		item["url"] = &types.AttributeValueMemberS{Value: link}
+	}
+	if notes = strings.TrimSpace(notes); notes != "" {
+		item["notes"] = &types.AttributeValueMemberS{Value: notes}
+	}
+	if minutesToSolve != nil {
+		item["minutes_to_solve"] = &types.AttributeValueMemberN{Value: strconv.Itoa(*minutesToSolve)}
+	}
+}
+
+// IsWebURL reports whether value is an absolute http or https URL
+func IsWebURL(value string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// CreatedAtAttributeValue is the created_at attribute for an item written at now
+func CreatedAtAttributeValue(now time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)}
+}
+
+// QuestionsFromItems decodes scanned items, parsing tags in either storage
+// format and normalizing dates. Items with unreadable tags keep an empty list.
+func QuestionsFromItems(items []map[string]types.AttributeValue) ([]Question, error) {
+	var questions []Question
+	err := attributevalue.UnmarshalListOfMaps(items, &questions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
+	}
+
+	for i := range questions {
+		tags, err := ParseTags(items[i]["tags"])
+		if err != nil {
+			slog.Warn("Failed to parse tags", "question", questions[i].Name, "error", err)
+			tags = []string{}
+		}
+
+		questions[i].Tags = tags
+		questions[i].Date = calendar.NormalizeDate(questions[i].Date)
+		for j, date := range questions[i].ReviewDates {
+			questions[i].ReviewDates[j] = calendar.NormalizeDate(date)
+		}
+		if questions[i].Attempts == 0 {
+			questions[i].Attempts = 1 + len(questions[i].ReviewDates)
+		}
+	}
+
+	return questions, nil
+}
+
+// QuestionTotals holds the aggregates every questions statistics response shares
+type QuestionTotals struct {
+	QuestionsCrackedPerDifficulty map[string]int `json:"questionsCrackedPerDifficulty"`
+	QuestionsCrackedPerTag        map[string]int `json:"questionsCrackedPerTag"`
+	TotalQuestionsCracked         int            `json:"totalQuestionsCracked"`
+	UnknownDifficultyCount        int            `json:"unknownDifficultyCount"`
+	FavoritesCount                int            `json:"favoritesCount"`
+	// QuestionsPerTagPerDifficulty breaks each tag down by the difficulty
+	// strings found in the data
+	QuestionsPerTagPerDifficulty map[string]map[string]int `json:"questionsPerTagPerDifficulty"`
+	// The minute totals and averages only cover questions with a recorded
+	// minutesToSolve; averages leave out unknown difficulties
+	TotalMinutesSolving         int                `json:"totalMinutesSolving"`
+	AverageMinutesPerDifficulty map[string]float64 `json:"averageMinutesPerDifficulty"`
+	MinutesSolvingPerDay        map[string]int     `json:"minutesSolvingPerDay"`
+	// Re-solves are counted apart; TotalQuestionsCracked only counts first solves
+	QuestionsSolvedMoreThanOnce int            `json:"questionsSolvedMoreThanOnce"`
+	ReviewsPerDay               map[string]int `json:"reviewsPerDay"`
+
+	minutesPerDifficulty map[string]int
+	timedPerDifficulty   map[string]int
+}
+
+func NewQuestionTotals() QuestionTotals {
+	return QuestionTotals{
+		QuestionsCrackedPerDifficulty: make(map[string]int),
+		QuestionsCrackedPerTag:        make(map[string]int),
+		QuestionsPerTagPerDifficulty:  make(map[string]map[string]int),
+		AverageMinutesPerDifficulty:   make(map[string]float64),
+		MinutesSolvingPerDay:          make(map[string]int),
+		ReviewsPerDay:                 make(map[string]int),
+		minutesPerDifficulty:          make(map[string]int),
+		timedPerDifficulty:            make(map[string]int),
+	}
+}
+
+// AddMinutes adds minutes, spent on the given number of questions, to the
+// average of difficulty
+func (t *QuestionTotals) AddMinutes(difficulty string, minutes, questions int) {
+	t.minutesPerDifficulty[difficulty] += minutes
+	t.timedPerDifficulty[difficulty] += questions
+	if timed := t.timedPerDifficulty[difficulty]; timed > 0 {
+		t.AverageMinutesPerDifficulty[difficulty] = math.Round(float64(t.minutesPerDifficulty[difficulty])/float64(timed)*10) / 10
+	}
+}
+
+// FoldTags re-keys the per-tag counts by canonical name, for totals that were
+// counted from raw tags. Counts of folded tags are summed, so a question
+// carrying two spellings of one tag counts twice there.
+func (t *QuestionTotals) FoldTags(c *alias.Canonicalizer) {
+	perTag := make(map[string]int, len(t.QuestionsCrackedPerTag))
+	for tag, count := range t.QuestionsCrackedPerTag {
+		if canonical := c.Canonical(tag); canonical != "" {
+			perTag[canonical] += count
+		}
+	}
+	t.QuestionsCrackedPerTag = perTag
+
+	perTagPerDifficulty := make(map[string]map[string]int, len(t.QuestionsPerTagPerDifficulty))
+	for tag, perDifficulty := range t.QuestionsPerTagPerDifficulty {
+		canonical := c.Canonical(tag)
+		if canonical == "" {
+			continue
+		}
+		if _, ok := perTagPerDifficulty[canonical]; !ok {
+			perTagPerDifficulty[canonical] = make(map[string]int)
+		}
+		for difficulty, count := range perDifficulty {
+			perTagPerDifficulty[canonical][difficulty] += count
+		}
+	}
+	t.QuestionsPerTagPerDifficulty = perTagPerDifficulty
+}
+
+// Add counts a question towards the totals. Questions without a known
+// difficulty are counted apart rather than under an empty-string bucket.
+func (t *QuestionTotals) Add(q Question) {
+	if IsUnknownDifficulty(q.Difficulty) {
+		t.UnknownDifficultyCount++
+	} else {
+		t.QuestionsCrackedPerDifficulty[q.Difficulty]++
+	}
+	difficulty := q.Difficulty
+	if IsUnknownDifficulty(difficulty) {
+		difficulty = UnknownDifficulty
+	}
+	for _, tag := range q.Tags {
+		t.QuestionsCrackedPerTag[tag]++
+		if _, ok := t.QuestionsPerTagPerDifficulty[tag]; !ok {
+			t.QuestionsPerTagPerDifficulty[tag] = make(map[string]int)
+		}
+		t.QuestionsPerTagPerDifficulty[tag][difficulty]++
+	}
+	if q.Favorite {
+		t.FavoritesCount++
+	}
+	if len(q.ReviewDates) > 0 {
+		t.QuestionsSolvedMoreThanOnce++
+	}
+	for _, date := range q.ReviewDates {
+		t.ReviewsPerDay[date]++
+	}
+	if q.MinutesToSolve != nil {
+		t.TotalMinutesSolving += *q.MinutesToSolve
+		t.MinutesSolvingPerDay[q.Date] += *q.MinutesToSolve
+		if !IsUnknownDifficulty(q.Difficulty) {
+			t.AddMinutes(q.Difficulty, *q.MinutesToSolve, 1)
+		}
+	}
+	t.TotalQuestionsCracked++
+}
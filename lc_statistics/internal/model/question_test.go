@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestQuestionsFromItemsFavorite(t *testing.T) {
+	item := func(name string, favorite types.AttributeValue) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"question_name":        &types.AttributeValueMemberS{Value: name},
+			"question_solved_date": &types.AttributeValueMemberS{Value: "2024-03-01"},
+		}
+		if favorite != nil {
+			item["favorite"] = favorite
+		}
+		return item
+	}
+
+	questions, err := QuestionsFromItems([]map[string]types.AttributeValue{
+		item("two-sum", &types.AttributeValueMemberBOOL{Value: true}),
+		item("clone-graph", &types.AttributeValueMemberBOOL{Value: false}),
+		// Written before the flag existed
+		item("word-ladder", nil),
+	})
+	if err != nil {
+		t.Fatalf("QuestionsFromItems: %v", err)
+	}
+
+	totals := NewQuestionTotals()
+	for i, want := range []bool{true, false, false} {
+		if questions[i].Favorite != want {
+			t.Errorf("%s favorite = %v, want %v", questions[i].Name, questions[i].Favorite, want)
+		}
+		totals.Add(questions[i])
+	}
+	if totals.FavoritesCount != 1 {
+		t.Errorf("favorites count = %d, want 1", totals.FavoritesCount)
+	}
+}
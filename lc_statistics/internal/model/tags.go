@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ParseTags reads the tags attribute, which is a string set (or an empty list)
+// on current items and a JSON-encoded string on legacy ones
+func ParseTags(av types.AttributeValue) ([]string, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberSS:
+		return v.Value, nil
+	case *types.AttributeValueMemberL:
+		tags := make([]string, 0, len(v.Value))
+		for _, element := range v.Value {
+			if s, ok := element.(*types.AttributeValueMemberS); ok {
+				tags = append(tags, s.Value)
+			}
+		}
+		return tags, nil
+	case *types.AttributeValueMemberS:
+		var tags []string
+		if err := json.Unmarshal([]byte(v.Value), &tags); err != nil {
+			return nil, err
+		}
+		return tags, nil
+	case nil:
+		return []string{}, nil
+	default:
+		return nil, fmt.Errorf("unexpected tags attribute type %T", av)
+	}
+}
+
+// IsLegacyTags reports whether the attribute still holds JSON-encoded tags
+func IsLegacyTags(av types.AttributeValue) bool {
+	_, ok := av.(*types.AttributeValueMemberS)
+	return ok
+}
+
+// TagsAttributeValue stores tags as a string set; DynamoDB rejects empty or
+// duplicated sets, so empty lists become an empty L and duplicates are dropped
+func TagsAttributeValue(tags []string) types.AttributeValue {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+
+	if len(unique) == 0 {
+		return &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	}
+	return &types.AttributeValueMemberSS{Value: unique}
+}
+
+// NormalizeTags lowercases and trims tags, dropping blank and repeated ones so
+// "Array" and " array" count as the same tag
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		av      types.AttributeValue
+		want    []string
+		wantErr bool
+	}{
+		{"string set", &types.AttributeValueMemberSS{Value: []string{"Array", "Graph"}}, []string{"Array", "Graph"}, false},
+		{"empty list", &types.AttributeValueMemberL{Value: []types.AttributeValue{}}, []string{}, false},
+		{"list skips non-strings", &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "Array"},
+			&types.AttributeValueMemberN{Value: "1"},
+		}}, []string{"Array"}, false},
+		{"legacy JSON string", &types.AttributeValueMemberS{Value: `["Array","Graph"]`}, []string{"Array", "Graph"}, false},
+		{"legacy string that isn't JSON", &types.AttributeValueMemberS{Value: "Array"}, nil, true},
+		{"missing", nil, []string{}, false},
+		{"wrong type", &types.AttributeValueMemberN{Value: "1"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.av)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTags error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTags = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsAttributeValue(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want types.AttributeValue
+	}{
+		{"nil", nil, &types.AttributeValueMemberL{Value: []types.AttributeValue{}}},
+		{"empty", []string{}, &types.AttributeValueMemberL{Value: []types.AttributeValue{}}},
+		{"unique", []string{"Array", "Graph"}, &types.AttributeValueMemberSS{Value: []string{"Array", "Graph"}}},
+		{"duplicates dropped", []string{"Array", "Graph", "Array"}, &types.AttributeValueMemberSS{Value: []string{"Array", "Graph"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TagsAttributeValue(tt.tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TagsAttributeValue = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{"Array", " array", "ARRAY "}, []string{"array"}},
+		{[]string{"Graph", "", "  ", "Two Pointers"}, []string{"graph", "two pointers"}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+// Every stored encoding reads back the same tags once rewritten the way the
+// add handlers and the legacy migration write them
+func TestTagsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   types.AttributeValue
+		want     []string
+		wantType types.AttributeValue
+	}{
+		{"string set", &types.AttributeValueMemberSS{Value: []string{"Array", "Graph"}},
+			[]string{"Array", "Graph"}, &types.AttributeValueMemberSS{}},
+		{"list", &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "Array"},
+			&types.AttributeValueMemberS{Value: "Graph"},
+		}}, []string{"Array", "Graph"}, &types.AttributeValueMemberSS{}},
+		{"empty list", &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			[]string{}, &types.AttributeValueMemberL{}},
+		{"legacy JSON string", &types.AttributeValueMemberS{Value: `["Array","Graph"]`},
+			[]string{"Array", "Graph"}, &types.AttributeValueMemberSS{}},
+		{"legacy JSON string with duplicates", &types.AttributeValueMemberS{Value: `["Array","Graph","Array"]`},
+			[]string{"Array", "Graph"}, &types.AttributeValueMemberSS{}},
+		{"legacy empty JSON array", &types.AttributeValueMemberS{Value: `[]`},
+			[]string{}, &types.AttributeValueMemberL{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := ParseTags(tt.stored)
+			if err != nil {
+				t.Fatalf("ParseTags stored: %v", err)
+			}
+
+			rewritten := TagsAttributeValue(tags)
+			if reflect.TypeOf(rewritten) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("rewritten as %T, want %T", rewritten, tt.wantType)
+			}
+			if IsLegacyTags(rewritten) {
+				t.Error("rewritten tags are still legacy")
+			}
+
+			got, err := ParseTags(rewritten)
+			if err != nil {
+				t.Fatalf("ParseTags rewritten: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round trip = %#v, want %#v", got, tt.want)
+			}
+
+			// Reading the item back does the same
+			questions, err := QuestionsFromItems([]map[string]types.AttributeValue{{
+				"question_name":        &types.AttributeValueMemberS{Value: "two-sum"},
+				"question_solved_date": &types.AttributeValueMemberS{Value: "2024-03-01"},
+				"tags":                 rewritten,
+			}})
+			if err != nil {
+				t.Fatalf("QuestionsFromItems: %v", err)
+			}
+			if !reflect.DeepEqual(questions[0].Tags, tt.want) {
+				t.Errorf("question tags = %#v, want %#v", questions[0].Tags, tt.want)
+			}
+		})
+	}
+}
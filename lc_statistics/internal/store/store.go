@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
+)
+
+// QuestionStore is what read-only handlers depend on, so they can be driven
+// by a fake store instead of DynamoDB
+type QuestionStore interface {
+	FetchAll(ctx context.Context) ([]model.Question, error)
+	// FetchSince returns the questions solved or reviewed on from or later
+	FetchSince(ctx context.Context, from string) ([]model.Question, error)
+	// Get returns the question stored under name, and false when there is none
+	Get(ctx context.Context, name string) (model.Question, bool, error)
+}
+
+// DynamoQuestionStore reads questions from Table, scoped to the tenant in ctx
+type DynamoQuestionStore struct {
+	Client awsutil.DynamoAPI
+	Table  string
+}
+
+var _ QuestionStore = (*DynamoQuestionStore)(nil)
+
+// NewDynamoQuestionStore builds a store backed by the default DynamoDB client
+func NewDynamoQuestionStore(ctx context.Context, table string) (*DynamoQuestionStore, error) {
+	client, err := awsutil.NewDynamoClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoQuestionStore{Client: client, Table: table}, nil
+}
+
+// FetchAll scans the whole table, keeping the items of the user in ctx
+func (s *DynamoQuestionStore) FetchAll(ctx context.Context) (questions []model.Question, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllQuestions")
+	defer func() { segment.End(err) }()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, s.Table)),
+	}
+	user.ScopeScan(ctx, input)
+	return s.scan(ctx, input)
+}
+
+// FetchSince scans for the questions solved or reviewed on from (YYYY-MM-DD)
+// or later. Reviews and day-first dates cannot be compared by the filter, so
+// those items are read and checked here
+func (s *DynamoQuestionStore) FetchSince(ctx context.Context, from string) (recent []model.Question, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchQuestionsSince")
+	defer func() { segment.End(err) }()
+
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(tenant.Table(ctx, s.Table)),
+		FilterExpression: aws.String("#date >= :from OR contains(#date, :dayFirst) OR attribute_exists(#reviews)"),
+		ExpressionAttributeNames: map[string]string{
+			"#date":    "question_solved_date",
+			"#reviews": "solve_dates",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":from":     &types.AttributeValueMemberS{Value: from},
+			":dayFirst": &types.AttributeValueMemberS{Value: "/"},
+		},
+	}
+	user.ScopeScan(ctx, input)
+
+	questions, err := s.scan(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if solvedSince(q, from) {
+			recent = append(recent, q)
+		}
+	}
+	return recent, nil
+}
+
+func solvedSince(q model.Question, from string) bool {
+	if q.Date >= from {
+		return true
+	}
+	for _, date := range q.ReviewDates {
+		if date >= from {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DynamoQuestionStore) scan(ctx context.Context, input *dynamodb.ScanInput) (questions []model.Question, err error) {
+	start := time.Now()
+	paginator := dynamodb.NewScanPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		pageQuestions, err := model.QuestionsFromItems(page.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		questions = append(questions, pageQuestions...)
+	}
+
+	metrics.Scan(ctx, start, len(questions))
+	return questions, nil
+}
+
+// Get reads a single question of the user in ctx by its name
+func (s *DynamoQuestionStore) Get(ctx context.Context, name string) (model.Question, bool, error) {
+	output, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tenant.Table(ctx, s.Table)),
+		Key:       model.QuestionKey(ctx, name),
+	})
+	if err != nil {
+		return model.Question{}, false, fmt.Errorf("failed to get item from DynamoDB: %w", err)
+	}
+	if output.Item == nil {
+		return model.Question{}, false, nil
+	}
+
+	questions, err := model.QuestionsFromItems([]map[string]types.AttributeValue{output.Item})
+	if err != nil {
+		return model.Question{}, false, err
+	}
+	return questions[0], true, nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+const table = "veet_code_questions_table"
+
+func item(name, date string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id":              &types.AttributeValueMemberS{Value: user.DefaultOwner},
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: "Medium"},
+		"tags":                 &types.AttributeValueMemberSS{Value: []string{"Graph"}},
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	items := []map[string]types.AttributeValue{
+		item("course-schedule", "2024-01-01"),
+		item("clone-graph", "05/01/2024"),
+		item("number-of-islands", "2024-01-09"),
+	}
+	for _, pageSize := range []int{0, 1, 2, 3} {
+		fake := dynamotest.New(table, items...)
+		fake.PageSize = pageSize
+		s := &DynamoQuestionStore{Client: fake, Table: table}
+
+		questions, err := s.FetchAll(context.Background())
+		if err != nil {
+			t.Fatalf("page size %d: FetchAll: %v", pageSize, err)
+		}
+		if len(questions) != 3 {
+			t.Fatalf("page size %d: got %d questions, want 3", pageSize, len(questions))
+		}
+		if got := questions[1].Date; got != "2024-01-05" {
+			t.Errorf("page size %d: legacy date = %s, want it normalized to 2024-01-05", pageSize, got)
+		}
+	}
+}
+
+func TestFetchAllMalformed(t *testing.T) {
+	malformed := item("broken", "2024-01-02")
+	malformed["minutes_to_solve"] = &types.AttributeValueMemberS{Value: "ten"}
+	fake := dynamotest.New(table, item("course-schedule", "2024-01-01"), malformed)
+	fake.PageSize = 1
+	s := &DynamoQuestionStore{Client: fake, Table: table}
+
+	if _, err := s.FetchAll(context.Background()); err == nil {
+		t.Fatal("FetchAll succeeded, want an unmarshal error")
+	}
+}
+
+func TestFetchAllTenantTable(t *testing.T) {
+	fake := dynamotest.New("alice_"+table, item("course-schedule", "2024-01-01"))
+	fake.Tables["bob_"+table] = []map[string]types.AttributeValue{item("clone-graph", "2024-01-02")}
+	s := &DynamoQuestionStore{Client: fake, Table: table}
+
+	questions, err := s.FetchAll(tenant.WithTenant(context.Background(), "bob"))
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	if len(questions) != 1 || questions[0].Name != "clone-graph" {
+		t.Errorf("questions = %+v, want only bob's", questions)
+	}
+}
+
+func TestFetchSince(t *testing.T) {
+	reviewed := item("word-ladder", "2024-01-01")
+	reviewed["solve_dates"] = &types.AttributeValueMemberL{Value: []types.AttributeValue{
+		&types.AttributeValueMemberS{Value: "2024-01-08"},
+	}}
+	fake := dynamotest.New(table,
+		item("course-schedule", "2024-01-01"),
+		item("clone-graph", "05/01/2024"),
+		item("number-of-islands", "09/01/2024"),
+		item("rotting-oranges", "2024-01-10"),
+		reviewed,
+	)
+	s := &DynamoQuestionStore{Client: fake, Table: table}
+
+	questions, err := s.FetchSince(context.Background(), "2024-01-06")
+	if err != nil {
+		t.Fatalf("FetchSince: %v", err)
+	}
+	var names []string
+	for _, q := range questions {
+		names = append(names, q.Name)
+	}
+	want := []string{"number-of-islands", "rotting-oranges", "word-ladder"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("FetchSince = %v, want %v", names, want)
+	}
+}
+
+func TestGet(t *testing.T) {
+	bobs := item("clone-graph", "2024-01-02")
+	bobs["user_id"] = &types.AttributeValueMemberS{Value: "bob"}
+	fake := dynamotest.New(table, item("course-schedule", "2024-01-01"), bobs)
+	s := &DynamoQuestionStore{Client: fake, Table: table}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		question string
+		wantOK   bool
+	}{
+		{"single user", context.Background(), "course-schedule", true},
+		{"missing", context.Background(), "number-of-islands", false},
+		{"another user's", context.Background(), "clone-graph", false},
+		{"own", user.WithUser(context.Background(), "bob"), "clone-graph", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, ok, err := s.Get(tt.ctx, tt.question)
+			if err != nil {
+				t.Fatalf("Get(%q): %v", tt.question, err)
+			}
+			if ok != tt.wantOK || (ok && question.Name != tt.question) {
+				t.Errorf("Get(%q) = %+v, %v, want found %v", tt.question, question, ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -2,15 +2,34 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/quota"
+	"veet-code-go/internal/ratelimit"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
 )
 
 type Request struct {
@@ -18,91 +37,506 @@ type Request struct {
 	QuestionDate       string   `json:"date"`
 	QuestionDifficulty string   `json:"difficulty"`
 	QuestionTags       []string `json:"tags"`
+	QuestionURL        string   `json:"url"`
+	QuestionNotes      string   `json:"notes"`
+	MinutesToSolve     *int     `json:"minutesToSolve"`
+}
+
+// ImportRequest is the object form of the body. A bare JSON array of
+// questions is still accepted.
+type ImportRequest struct {
+	Questions []Request `json:"questions"`
+	// DifficultyMapping fills in difficulties for questions sent without one
+	DifficultyMapping map[string]string `json:"difficultyMapping"`
+	// WritesPerSecond overrides the WRITES_PER_SECOND budget for this import
+	WritesPerSecond *float64 `json:"writesPerSecond"`
 }
 
-var dynamoClient  *dynamodb.Client
-const tableName = "veet_code_questions_table"
+var dynamoClient awsutil.DynamoAPI
+var writeLimiter *ratelimit.Limiter
+
+// maxWritesPerSecond caps the budget a request can ask for
+var maxWritesPerSecond float64 = ratelimit.DefaultMaxWritesPerSecond
+
+// writeClock paces the limiter and the backoff between retries
+var writeClock = ratelimit.SystemClock
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to load AWS SDK config: %v", err))
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
 
-	dynamoClient = dynamodb.NewFromConfig(cfg)
+	writeLimiter, err = ratelimit.FromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load write rate limit: %v", err)
+	}
+	maxWritesPerSecond, err = ratelimit.MaxFromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load write rate limit: %v", err)
+	}
 }
 
-func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (map[string]interface{}, error) {
+// Handler imports a batch of questions. With ?dryRun=true it validates and
+// normalizes them and looks up existing names exactly as an import would, but
+// writes nothing and answers with what would have been written.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
 
-	fmt.Println("Raw Event:", event)
+	slog.Debug("Raw event", "event", event)
 
-	var requests []Request
-	err := json.Unmarshal([]byte(event.Body), &requests)
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	dryRun := event.QueryStringParameters["dryRun"] == "true"
+
+	if isCSV(event) {
+		return importCSV(ctx, event, dryRun)
+	}
+
+	importRequest, err := parseImportRequest(event.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal request body: %v", err)
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
 	}
+	requests := importRequest.Questions
 
-	successCount := 0
+	if verr := validation.CheckBatch(len(requests)); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
 
-	for _, request := range requests {
-		fmt.Println("Question Name: ", request.QuestionName)
-		fmt.Println("Question Date: ", request.QuestionDate)
-		fmt.Println("Question Difficulty: ", request.QuestionDifficulty)
-		fmt.Println("Question Tags: ", request.QuestionTags)
+	now := time.Now()
+	for i := range requests {
+		requests[i].QuestionDate = calendar.DateOrToday(requests[i].QuestionDate, model.DateLayout, now)
+	}
 
-		tagsJSON, err := json.Marshal(request.QuestionTags)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal tags: %v", err)
+	// Validate every question before writing any of them
+	var fields validation.Fields
+	for i, request := range requests {
+		request.validate(&fields, fmt.Sprintf("[%d].", i))
+	}
+	validateUnique(&fields, requests)
+	if importRequest.WritesPerSecond != nil && *importRequest.WritesPerSecond <= 0 {
+		fields.Add("writesPerSecond", "must be greater than 0")
+	}
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	mapping := model.MappingLookup(importRequest.DifficultyMapping)
+	for i := range requests {
+		requests[i].QuestionDifficulty = model.InferDifficulty(requests[i].QuestionName, requests[i].QuestionDifficulty, mapping)
+		requests[i].QuestionTags = model.NormalizeTags(requests[i].QuestionTags)
+	}
+
+	names := make([]string, len(requests))
+	for i, request := range requests {
+		names[i] = request.QuestionName
+	}
+	existing, err := existingQuestions(ctx, names)
+	if err != nil {
+		slog.Error("Failed to look up existing questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to look up existing questions"), nil
+	}
+	if len(existing) > 0 {
+		return awsutil.APIError{
+			Code:    awsutil.CodeConflict,
+			Message: "question already exists",
+			Details: map[string][]string{"names": existing},
+		}.Response(ctx), nil
+	}
+
+	slog.Debug("Received questions", "questions", requests)
+
+	var warnings quota.Warnings
+	warnings.Check(quota.BatchSize, len(requests))
+
+	if dryRun {
+		slog.Info("Dry run of question import", "questions", len(requests))
+		return awsutil.WarnedResponse(200, map[string]any{
+			"message":    fmt.Sprintf("%d question(s) would be added to DynamoDB.", len(requests)),
+			"dryRun":     true,
+			"wouldWrite": len(requests),
+			"questions":  requests,
+		}, warnings), nil
+	}
+
+	limiter := ratelimit.ForRequest(importRequest.WritesPerSecond, maxWritesPerSecond, writeLimiter, writeClock)
+	unsaved, err := putMultipleItemsToDynamoDB(ctx, requests, limiter)
+	succeeded := len(requests) - len(unsaved)
+	if err != nil {
+		slog.Error("Failed to add items to DynamoDB", "error", err, "written", succeeded)
+		if succeeded == 0 {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to add the questions"), nil
+		}
+	}
+	metrics.Emit(ctx, metrics.Count(metrics.QuestionsWritten, succeeded))
+
+	body := map[string]any{
+		"message":   fmt.Sprintf("%d question(s) successfully added to DynamoDB.", succeeded),
+		"succeeded": succeeded,
+		"failed":    len(unsaved),
+	}
+	status := reportUnsaved(body, unsaved, err)
+	if status != 200 {
+		body["message"] = fmt.Sprintf("%d of %d question(s) added to DynamoDB.", succeeded, len(requests))
+	}
+	return awsutil.WarnedResponse(status, body, warnings), nil
+}
+
+// validate records missing required fields, prefixing field names with prefix
+func (r Request) validate(fields *validation.Fields, prefix string) {
+	fields.Require(prefix+"name", r.QuestionName)
+	fields.Require(prefix+"date", r.QuestionDate)
+	if r.QuestionDate != "" {
+		if err := calendar.CheckNewDate(r.QuestionDate, time.Now(), calendar.Layouts...); err != nil {
+			fields.Add(prefix+"date", err.Error())
 		}
+	}
+	if r.QuestionURL != "" && !model.IsWebURL(r.QuestionURL) {
+		fields.Add(prefix+"url", "must be an http or https URL")
+	}
+	if r.MinutesToSolve != nil && *r.MinutesToSolve <= 0 {
+		fields.Add(prefix+"minutesToSolve", "must be a positive whole number")
+	}
+}
+
+// isCSV tells CSV uploads, sent as text/csv or with ?format=csv, from JSON ones
+func isCSV(event events.APIGatewayProxyRequest) bool {
+	contentType := strings.ToLower(awsutil.HeaderValue(event.Headers, "Content-Type"))
+	return strings.HasPrefix(contentType, "text/csv") || event.QueryStringParameters["format"] == "csv"
+}
+
+// RejectedRow is a CSV line that was not imported
+type RejectedRow struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
 
-		err = putItemToDynamoDB(request, string(tagsJSON))
+// csvRow is a parsed data line of an import
+type csvRow struct {
+	line    int
+	request Request
+}
+
+// importCSV imports a CSV with the columns of the CSV export. Unlike JSON
+// bodies, rows are imported independently: invalid rows and names that
+// already exist are reported back by line number, the others are written.
+// A dry run reports the same but stops before writing.
+func importCSV(ctx context.Context, event events.APIGatewayProxyRequest, dryRun bool) (events.APIGatewayProxyResponse, error) {
+	body := event.Body
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to add item to DynamoDB: %v", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid base64 body"), nil
+		}
+		body = string(decoded)
+	}
+
+	rows, err := parseCSV(body)
+	if err != nil {
+		slog.Warn("Failed to parse CSV body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, err.Error()), nil
+	}
+	if verr := validation.CheckBatch(len(rows)); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+	var warnings quota.Warnings
+	warnings.Check(quota.BatchSize, len(rows))
+
+	rejected := []RejectedRow{}
+	var valid []csvRow
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if reason := row.validate(); reason != "" {
+			rejected = append(rejected, RejectedRow{Line: row.line, Name: row.request.QuestionName, Reason: reason})
+			continue
+		}
+		if seen[row.request.QuestionName] {
+			rejected = append(rejected, RejectedRow{Line: row.line, Name: row.request.QuestionName, Reason: "name is repeated in the file"})
+			continue
 		}
+		seen[row.request.QuestionName] = true
+		valid = append(valid, row)
+	}
 
-		successCount++
+	names := make([]string, len(valid))
+	for i, row := range valid {
+		names[i] = row.request.QuestionName
+	}
+	existing, err := existingQuestions(ctx, names)
+	if err != nil {
+		slog.Error("Failed to look up existing questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to look up existing questions"), nil
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
 	}
 
-	successMessage := fmt.Sprintf("%d question(s) successfully added to DynamoDB.", successCount)
+	var requests []Request
+	for _, row := range valid {
+		if exists[row.request.QuestionName] {
+			rejected = append(rejected, RejectedRow{Line: row.line, Name: row.request.QuestionName, Reason: "question already exists"})
+			continue
+		}
+		requests = append(requests, row.request)
+	}
+	sort.SliceStable(rejected, func(i, j int) bool { return rejected[i].Line < rejected[j].Line })
 
-	headers := map[string]string{
-		"Access-Control-Allow-Origin":      "*",           
-		"Access-Control-Allow-Methods":     "POST, OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+	if dryRun {
+		slog.Info("Dry run of CSV import", "questions", len(requests), "rejected", len(rejected))
+		return awsutil.WarnedResponse(200, map[string]any{
+			"message":    fmt.Sprintf("%d question(s) would be imported, %d line(s) rejected.", len(requests), len(rejected)),
+			"dryRun":     true,
+			"wouldWrite": len(requests),
+			"rejected":   rejected,
+		}, warnings), nil
 	}
 
-	body, err := json.Marshal(map[string]string{
-		"message": successMessage,
-	})
+	unsaved, err := putMultipleItemsToDynamoDB(ctx, requests, writeLimiter)
+	succeeded := len(requests) - len(unsaved)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response body: %v", err)
+		slog.Error("Failed to add items to DynamoDB", "error", err, "written", succeeded)
+		if succeeded == 0 {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to add the questions"), nil
+		}
 	}
+	metrics.Emit(ctx, metrics.Count(metrics.QuestionsWritten, succeeded))
 
-	return map[string]interface{}{
-		"statusCode": 200,
-		"headers":    headers,
-		"body":       string(body),
-	}, nil
+	response := map[string]any{
+		"message":  fmt.Sprintf("%d question(s) imported, %d line(s) rejected.", succeeded, len(rejected)),
+		"imported": succeeded,
+		"failed":   len(unsaved),
+		"rejected": rejected,
+	}
+	status := reportUnsaved(response, unsaved, err)
+	if status != 200 {
+		response["message"] = fmt.Sprintf("%d of %d question(s) imported, %d line(s) rejected.", succeeded, len(requests), len(rejected))
+	}
+	return awsutil.WarnedResponse(status, response, warnings), nil
 }
 
-func putItemToDynamoDB(request Request, tagsJSON string) error {
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item: map[string]types.AttributeValue{
-			"question_name":       &types.AttributeValueMemberS{Value: request.QuestionName},
-			"question_solved_date": &types.AttributeValueMemberS{Value: request.QuestionDate},
-			"difficulty":          &types.AttributeValueMemberS{Value: request.QuestionDifficulty},
-			"tags":                &types.AttributeValueMemberS{Value: tagsJSON},
-		},
+// reportUnsaved adds the questions that were not written to an import
+// response, along with the error that stopped the import, if any. Since the
+// others were written, that is a partial success: 207 rather than 200.
+func reportUnsaved(body map[string]any, unsaved []Request, err error) int {
+	if len(unsaved) == 0 {
+		return 200
+	}
+	body["unsaved"] = unsaved
+	if err != nil {
+		body["error"] = "stopped by a database error; the unsaved questions can be sent again"
 	}
+	return 207
+}
 
-	_, err := dynamoClient.PutItem(context.TODO(), input)
+// parseCSV reads the header row to find the columns, so their order does not
+// matter and unknown ones are ignored. Besides the name, date, difficulty
+// and tags of the export, url and notes columns are read. The name column
+// may also be called question_name, as in exports made before.
+func parseCSV(body string) ([]csvRow, error) {
+	reader := csv.NewReader(strings.NewReader(body))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
 	if err != nil {
-		return fmt.Errorf("failed to put item in DynamoDB: %v", err)
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "question_name" {
+			name = "name"
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV header has no name column")
+	}
+
+	var rows []csvRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		var tags []string
+		if value := field("tags"); value != "" {
+			tags = strings.Split(value, ";")
+		}
+		rows = append(rows, csvRow{line: line, request: Request{
+			QuestionName:       field("name"),
+			QuestionDate:       field("date"),
+			QuestionDifficulty: field("difficulty"),
+			QuestionTags:       tags,
+			QuestionURL:        field("url"),
+			QuestionNotes:      field("notes"),
+		}})
+	}
+	return rows, nil
+}
+
+// validate checks the row like a JSON question, and additionally requires a
+// known difficulty. It returns the reason the row is rejected, or "" and
+// normalizes the row's difficulty and tags. Rows without a date are dated
+// today, like JSON questions.
+func (r *csvRow) validate() string {
+	r.request.QuestionDate = calendar.DateOrToday(r.request.QuestionDate, model.DateLayout, time.Now())
+
+	var fields validation.Fields
+	r.request.validate(&fields, "")
+	if len(fields) > 0 {
+		return fields[0].Field + " " + fields[0].Message
+	}
+	difficulty, ok := model.CanonicalDifficulty(r.request.QuestionDifficulty)
+	if !ok {
+		return fmt.Sprintf("difficulty %q is not one of %s", r.request.QuestionDifficulty, strings.Join(model.Difficulties, ", "))
+	}
+
+	r.request.QuestionDifficulty = difficulty
+	r.request.QuestionTags = model.NormalizeTags(r.request.QuestionTags)
+	return ""
+}
+
+// validateUnique rejects names repeated within the batch, which
+// BatchWriteItem refuses outright
+func validateUnique(fields *validation.Fields, requests []Request) {
+	seen := make(map[string]bool)
+	for i, request := range requests {
+		if request.QuestionName != "" && seen[request.QuestionName] {
+			fields.Add(fmt.Sprintf("[%d].name", i), "is repeated in the request")
+		}
+		seen[request.QuestionName] = true
+	}
+}
+
+func parseImportRequest(body string) (ImportRequest, error) {
+	var importRequest ImportRequest
+	if strings.HasPrefix(strings.TrimSpace(body), "[") {
+		err := json.Unmarshal([]byte(body), &importRequest.Questions)
+		return importRequest, err
+	}
+
+	err := json.Unmarshal([]byte(body), &importRequest)
+	return importRequest, err
+}
+
+// existingQuestions returns the names the user in ctx already stored. Batch
+// writes cannot carry a condition, so duplicates are checked up front
+// instead. The table is keyed by user and name, so other users' questions
+// neither show up here nor can be overwritten by the batch.
+func existingQuestions(ctx context.Context, names []string) ([]string, error) {
+	table := tenant.Table(ctx, tableName)
+	existing := []string{}
+
+	// BatchGetItem accepts at most 100 keys per request
+	const maxGetSize = 100
+	for i := 0; i < len(names); i += maxGetSize {
+		end := min(i+maxGetSize, len(names))
+
+		var keys []map[string]types.AttributeValue
+		for _, name := range names[i:end] {
+			keys = append(keys, model.QuestionKey(ctx, name))
+		}
+
+		pending := map[string]types.KeysAndAttributes{
+			table: {Keys: keys, ProjectionExpression: aws.String("question_name")},
+		}
+		for attempt := 1; len(pending) > 0; attempt++ {
+			if attempt > awsutil.MaxBatchAttempts {
+				return nil, fmt.Errorf("gave up after %d attempts with unprocessed keys", awsutil.MaxBatchAttempts)
+			}
+			if attempt > 1 {
+				if err := writeClock.Sleep(ctx, ratelimit.Backoff(attempt-1)); err != nil {
+					return nil, fmt.Errorf("interrupted backing off: %w", err)
+				}
+			}
+
+			output, err := dynamoClient.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, fmt.Errorf("failed to batch get items from DynamoDB: %w", err)
+			}
+
+			for _, item := range output.Responses[table] {
+				if v, ok := item["question_name"].(*types.AttributeValueMemberS); ok {
+					existing = append(existing, v.Value)
+				}
+			}
+			pending = output.UnprocessedKeys
+		}
 	}
-	return nil
+
+	return existing, nil
+}
+
+// putMultipleItemsToDynamoDB writes in chunks of 25 paced through limiter and
+// returns the questions DynamoDB still left unprocessed after every retry. An
+// error stops the import; the questions of that chunk and every later one
+// are then returned as unsaved along with it.
+func putMultipleItemsToDynamoDB(ctx context.Context, requests []Request, limiter *ratelimit.Limiter) ([]Request, error) {
+	var writeRequests []types.WriteRequest
+	byName := make(map[string]Request, len(requests))
+	createdAt := model.CreatedAtAttributeValue(time.Now())
+	for _, request := range requests {
+		byName[request.QuestionName] = request
+
+		item := model.QuestionKey(ctx, request.QuestionName)
+		item["question_solved_date"] = &types.AttributeValueMemberS{Value: calendar.NormalizeDate(request.QuestionDate)}
+		item["difficulty"] = &types.AttributeValueMemberS{Value: request.QuestionDifficulty}
+		item["tags"] = model.TagsAttributeValue(request.QuestionTags)
+		item["created_at"] = createdAt
+		model.SetOptionalAttributes(item, request.QuestionURL, request.QuestionNotes, request.MinutesToSolve)
+
+		writeRequests = append(writeRequests, types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: item},
+		})
+	}
+
+	table := tenant.Table(ctx, tableName)
+	unsaved := []Request{}
+	for i := 0; i < len(writeRequests); i += awsutil.MaxBatchWriteSize {
+		end := min(i+awsutil.MaxBatchWriteSize, len(writeRequests))
+
+		unprocessed, err := awsutil.WriteBatch(ctx, dynamoClient, table, writeRequests[i:end], limiter, writeClock)
+		if err != nil {
+			unprocessed = append(unprocessed, writeRequests[end:]...)
+		}
+		for _, writeRequest := range unprocessed {
+			name := writeRequest.PutRequest.Item["question_name"].(*types.AttributeValueMemberS).Value
+			unsaved = append(unsaved, byName[name])
+		}
+		if err != nil {
+			return unsaved, err
+		}
+		slog.Info("Wrote questions", "written", end-len(unsaved), "total", len(writeRequests), "writesPerSecond", limiter.Rate())
+	}
+
+	return unsaved, nil
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
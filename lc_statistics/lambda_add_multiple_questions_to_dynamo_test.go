@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/quota"
+	"veet-code-go/internal/ratelimit"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+func newQuestionsFake(items ...map[string]types.AttributeValue) *dynamotest.Fake {
+	fake := dynamotest.New(tableName, items...)
+	fake.Keys = map[string][]string{tableName: {"user_id", "question_name"}}
+	return fake
+}
+
+func questionRequests(n int) []Request {
+	requests := make([]Request, n)
+	for i := range requests {
+		requests[i] = Request{QuestionName: fmt.Sprintf("question-%03d", i), QuestionDate: "2024-03-01", QuestionDifficulty: "Easy"}
+	}
+	return requests
+}
+
+// leaveUnprocessed hands back the first count requests of the calls listed
+func leaveUnprocessed(count int, calls ...int) func(int, map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+	return func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		for _, call := range calls {
+			if call == n {
+				return map[string][]types.WriteRequest{tableName: requests[tableName][:count]}
+			}
+		}
+		return nil
+	}
+}
+
+// fakeClock records sleeps instead of taking them
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	previous := writeClock
+	writeClock = clock
+	t.Cleanup(func() { writeClock = previous })
+	return clock
+}
+
+func TestPutMultipleItemsToDynamoDB(t *testing.T) {
+	tests := []struct {
+		name          string
+		questions     int
+		unprocessed   func(int, map[string][]types.WriteRequest) map[string][]types.WriteRequest
+		wantSucceeded int
+		wantFailed    int
+		wantWrites    int
+	}{
+		{"single chunk", 3, nil, 3, 0, 1},
+		{"chunks of 25", 60, nil, 60, 0, 3},
+		{"unprocessed items are retried", 30, leaveUnprocessed(5, 1), 30, 0, 3},
+		{"unprocessed after every retry", 10, leaveUnprocessed(4, 1, 2, 3, 4, 5, 6, 7, 8), 6, 4, awsutil.MaxBatchAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeClock(t)
+			fake := newQuestionsFake()
+			fake.Unprocessed = tt.unprocessed
+			dynamoClient = fake
+
+			unsaved, err := putMultipleItemsToDynamoDB(context.Background(), questionRequests(tt.questions), nil)
+			if err != nil {
+				t.Fatalf("putMultipleItemsToDynamoDB: %v", err)
+			}
+			if succeeded, failed := tt.questions-len(unsaved), len(unsaved); succeeded != tt.wantSucceeded || failed != tt.wantFailed {
+				t.Errorf("succeeded, failed = %d, %d, want %d, %d", succeeded, failed, tt.wantSucceeded, tt.wantFailed)
+			}
+			if got := fake.Count("BatchWriteItem"); got != tt.wantWrites {
+				t.Errorf("BatchWriteItem called %d times, want %d", got, tt.wantWrites)
+			}
+			if got := len(fake.Items(tableName)); got != tt.wantSucceeded {
+				t.Errorf("table holds %d items, want %d", got, tt.wantSucceeded)
+			}
+		})
+	}
+}
+
+func TestPutMultipleItemsToDynamoDBError(t *testing.T) {
+	writeErr := errors.New("boom")
+	fake := newQuestionsFake()
+	fake.Err = func(operation string, n int) error {
+		if operation == "BatchWriteItem" && n == 2 {
+			return writeErr
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	unsaved, err := putMultipleItemsToDynamoDB(context.Background(), questionRequests(80), nil)
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("err = %v, want %v", err, writeErr)
+	}
+	// The failing chunk and the two after it were never written
+	if len(unsaved) != 55 || unsaved[0].QuestionName != "question-025" || unsaved[54].QuestionName != "question-079" {
+		t.Errorf("unsaved = %d questions, want question-025 to question-079", len(unsaved))
+	}
+	if got := fake.Count("BatchWriteItem"); got != 2 {
+		t.Errorf("BatchWriteItem called %d times, want no writes after the error", got)
+	}
+}
+
+func TestPutMultipleItemsToDynamoDBPacing(t *testing.T) {
+	clock := useFakeClock(t)
+	fake := newQuestionsFake()
+	fake.Err = func(operation string, n int) error {
+		if operation == "BatchWriteItem" && n == 1 {
+			return &types.ProvisionedThroughputExceededException{}
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	// The first chunk fits the initial burst; after the throttle its retry and
+	// the second chunk wait for capacity at the halved rate
+	limiter := ratelimit.NewWithClock(40, clock)
+	unsaved, err := putMultipleItemsToDynamoDB(context.Background(), questionRequests(50), limiter)
+	if err != nil || len(unsaved) != 0 {
+		t.Fatalf("putMultipleItemsToDynamoDB = %d unsaved, %v, want all 50 written", len(unsaved), err)
+	}
+	if len(clock.slept) != 3 || clock.slept[0] > ratelimit.BaseBackoff {
+		t.Errorf("slept %v, want a first backoff and two waits for capacity", clock.slept)
+	}
+	// Halved once, then a tenth of the budget recovered per written chunk
+	if got := limiter.Rate(); got != 28 {
+		t.Errorf("rate = %v, want 28", got)
+	}
+}
+
+func TestHandlerWritesPerSecond(t *testing.T) {
+	useFakeClock(t)
+	t.Cleanup(func() { maxWritesPerSecond = ratelimit.DefaultMaxWritesPerSecond })
+	maxWritesPerSecond = 50
+
+	for _, value := range []string{"0", "-1"} {
+		dynamoClient = newQuestionsFake()
+		response, _ := Handler(context.Background(), events.APIGatewayProxyRequest{
+			Body: `{"questions":[{"name":"two-sum","date":"2024-03-01"}],"writesPerSecond":` + value + `}`,
+		})
+		if code, fields := errorFields(t, response); code != validation.CodeValidationFailed || !reflect.DeepEqual(fields, []string{"writesPerSecond"}) {
+			t.Errorf("writesPerSecond %s = %s %v, want a validation error on writesPerSecond", value, code, fields)
+		}
+	}
+
+	dynamoClient = newQuestionsFake()
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"questions":[{"name":"two-sum","date":"2024-03-01"}],"writesPerSecond":5000}`,
+	})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+}
+
+func TestHandlerPartialSuccess(t *testing.T) {
+	writeErr := errors.New("boom")
+	failSecondWrite := func(operation string, n int) error {
+		if operation == "BatchWriteItem" && n == 2 {
+			return writeErr
+		}
+		return nil
+	}
+	failEveryWrite := func(operation string, n int) error {
+		if operation == "BatchWriteItem" {
+			return writeErr
+		}
+		return nil
+	}
+	tests := []struct {
+		name        string
+		questions   int
+		err         func(string, int) error
+		unprocessed func(int, map[string][]types.WriteRequest) map[string][]types.WriteRequest
+		wantStatus  int
+		wantWritten int
+		wantUnsaved int
+		wantError   bool
+	}{
+		{"everything written", 30, nil, nil, 200, 30, 0, false},
+		{"unprocessed after every retry", 10, nil, leaveUnprocessed(4, 1, 2, 3, 4, 5, 6, 7, 8), 207, 6, 4, false},
+		{"error after the first chunk", 30, failSecondWrite, nil, 207, 25, 5, true},
+		{"error before anything was written", 30, failEveryWrite, nil, 500, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeClock(t)
+			fake := newQuestionsFake()
+			fake.Err = tt.err
+			fake.Unprocessed = tt.unprocessed
+			dynamoClient = fake
+
+			questions, _ := json.Marshal(questionRequests(tt.questions))
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(questions)})
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Fatalf("Handler = %d %s, %v, want %d", response.StatusCode, response.Body, err, tt.wantStatus)
+			}
+			if tt.wantStatus == 500 {
+				return
+			}
+
+			var body struct {
+				Succeeded int       `json:"succeeded"`
+				Failed    int       `json:"failed"`
+				Unsaved   []Request `json:"unsaved"`
+				Error     string    `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if body.Succeeded != tt.wantWritten || body.Failed != tt.wantUnsaved || len(body.Unsaved) != tt.wantUnsaved {
+				t.Errorf("succeeded, failed, unsaved = %d, %d, %d, want %d, %d, %d", body.Succeeded, body.Failed, len(body.Unsaved), tt.wantWritten, tt.wantUnsaved, tt.wantUnsaved)
+			}
+			if (body.Error != "") != tt.wantError {
+				t.Errorf("error = %q, want one %v", body.Error, tt.wantError)
+			}
+			if got := len(fake.Items(tableName)); got != tt.wantWritten {
+				t.Errorf("table holds %d items, want %d", got, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestImportCSVPartialSuccess(t *testing.T) {
+	useFakeClock(t)
+	fake := newQuestionsFake()
+	fake.Unprocessed = leaveUnprocessed(1, 1, 2, 3, 4, 5, 6, 7, 8)
+	dynamoClient = fake
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Content-Type": "text/csv"},
+		Body:    "name,date,difficulty\ntwo-sum,2024-03-01,Easy\nthree-sum,2024-03-01,Medium\n",
+	})
+	if err != nil || response.StatusCode != 207 {
+		t.Fatalf("Handler = %d %s, %v, want 207", response.StatusCode, response.Body, err)
+	}
+	var body struct {
+		Imported int       `json:"imported"`
+		Failed   int       `json:"failed"`
+		Unsaved  []Request `json:"unsaved"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if body.Imported != 1 || body.Failed != 1 || len(body.Unsaved) != 1 || body.Unsaved[0].QuestionName != "two-sum" {
+		t.Errorf("body = %+v, want two-sum unsaved and the other question imported", body)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	want := []Request{
+		{QuestionName: "two-sum, again", QuestionDate: "2024-01-01", QuestionDifficulty: "Easy", QuestionTags: []string{"Array", "Hash Table"}},
+		{QuestionName: "climbing-stairs", QuestionDate: "2024-01-02", QuestionDifficulty: "Easy", QuestionTags: []string{"Dynamic Programming"}},
+	}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"export", "name,date,difficulty,tags\r\n" +
+			"\"two-sum, again\",2024-01-01,Easy,Array;Hash Table\r\n" +
+			"climbing-stairs,2024-01-02,Easy,Dynamic Programming\r\n"},
+		{"earlier export", "question_name,date,difficulty,tags,created_at,url,notes\n" +
+			"\"two-sum, again\",2024-01-01,Easy,Array;Hash Table,2024-01-01T12:30:00Z,,\n" +
+			"climbing-stairs,2024-01-02,Easy,Dynamic Programming,,,\n"},
+		{"reordered columns", "tags,name,difficulty,date\n" +
+			"Array;Hash Table,\"two-sum, again\",Easy,2024-01-01\n" +
+			"Dynamic Programming,climbing-stairs,Easy,2024-01-02\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseCSV(tt.body)
+			if err != nil {
+				t.Fatalf("parseCSV: %v", err)
+			}
+			var got []Request
+			for _, row := range rows {
+				got = append(got, row.request)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("parseCSV = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestExistingQuestions(t *testing.T) {
+	stored := []map[string]types.AttributeValue{
+		{"user_id": &types.AttributeValueMemberS{Value: user.DefaultOwner}, "question_name": &types.AttributeValueMemberS{Value: "question-000"}},
+		{"user_id": &types.AttributeValueMemberS{Value: user.DefaultOwner}, "question_name": &types.AttributeValueMemberS{Value: "question-149"}},
+		{"user_id": &types.AttributeValueMemberS{Value: "bob"}, "question_name": &types.AttributeValueMemberS{Value: "question-001"}},
+	}
+	fake := newQuestionsFake(stored...)
+	dynamoClient = fake
+
+	var names []string
+	for _, request := range questionRequests(150) {
+		names = append(names, request.QuestionName)
+	}
+	existing, err := existingQuestions(context.Background(), names)
+	if err != nil {
+		t.Fatalf("existingQuestions: %v", err)
+	}
+	sort.Strings(existing)
+	if want := []string{"question-000", "question-149"}; !reflect.DeepEqual(existing, want) {
+		t.Errorf("existing = %v, want %v", existing, want)
+	}
+	if got := fake.Count("BatchGetItem"); got != 2 {
+		t.Errorf("BatchGetItem called %d times, want 2 for 150 keys", got)
+	}
+}
+
+func TestHandlerRejectsExistingQuestions(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       string
+		body       string
+		wantStatus int
+		wantItems  int
+	}{
+		// JSON imports are refused as a whole, CSV ones reject the line
+		{"own question", "", `[{"name":"two-sum","date":"2024-03-01"},{"name":"three-sum","date":"2024-03-01"}]`, 409, 1},
+		{"own question in a CSV", "", "name,date\ntwo-sum,2024-03-01\nthree-sum,2024-03-01\n", 200, 2},
+		{"another user's question", "bob", `[{"name":"two-sum","date":"2024-03-01"},{"name":"three-sum","date":"2024-03-01"}]`, 200, 3},
+		{"another user's question in a CSV", "bob", "name,date\ntwo-sum,2024-03-01\nthree-sum,2024-03-01\n", 200, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newQuestionsFake(map[string]types.AttributeValue{
+				"user_id":       &types.AttributeValueMemberS{Value: user.DefaultOwner},
+				"question_name": &types.AttributeValueMemberS{Value: "two-sum"},
+			})
+			dynamoClient = fake
+
+			ctx := context.Background()
+			if tt.user != "" {
+				ctx = user.WithUser(ctx, tt.user)
+			}
+			headers := map[string]string{}
+			if strings.HasPrefix(tt.body, "name,") {
+				headers["Content-Type"] = "text/csv"
+			}
+			response, err := Handler(ctx, events.APIGatewayProxyRequest{Headers: headers, Body: tt.body})
+			if err != nil {
+				t.Fatalf("Handler: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if items := fake.Items(tableName); len(items) != tt.wantItems {
+				t.Errorf("table holds %d items, want %d", len(items), tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestHandlerInfersDifficulty(t *testing.T) {
+	fake := newQuestionsFake()
+	dynamoClient = fake
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{
+		"questions": [
+			{"name":"two-sum","date":"2024-03-01","difficulty":"Easy"},
+			{"name":"clone-graph","date":"2024-03-01"},
+			{"name":"word-ladder","date":"2024-03-01","difficulty":"unknown"},
+			{"name":"jump-game","date":"2024-03-01"}
+		],
+		"difficultyMapping": {"two-sum":"Hard","clone-graph":"Medium","word-ladder":"Hard"}
+	}`})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+
+	got := map[string]string{}
+	for _, item := range fake.Items(tableName) {
+		name := item["question_name"].(*types.AttributeValueMemberS).Value
+		got[name] = item["difficulty"].(*types.AttributeValueMemberS).Value
+	}
+	want := map[string]string{"two-sum": "Easy", "clone-graph": "Medium", "word-ladder": "Hard", "jump-game": "unknown"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stored difficulties = %v, want %v", got, want)
+	}
+}
+
+// errorFields decodes the error envelope of a response into its code and the
+// names of its offending fields
+func errorFields(t *testing.T, response events.APIGatewayProxyResponse) (string, []string) {
+	t.Helper()
+	var body struct {
+		Error validation.Error `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("invalid error body %s: %v", response.Body, err)
+	}
+	var fields []string
+	for _, field := range body.Error.Fields {
+		fields = append(fields, field.Field)
+	}
+	return body.Error.Code, fields
+}
+
+// TestHandlerEmptyPayloads covers the payloads every write endpoint handles the
+// same way through the validation package
+func TestHandlerEmptyPayloads(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []string
+	}{
+		{"empty", "", validation.CodeEmptyBody, nil},
+		{"blank", " \n\t", validation.CodeEmptyBody, nil},
+		{"null", "null", validation.CodeEmptyBody, nil},
+		{"null with whitespace", " null ", validation.CodeEmptyBody, nil},
+		{"empty object", "{}", validation.CodeEmptyBatch, nil},
+		{"empty array", "[]", validation.CodeEmptyBatch, nil},
+		{"empty questions", `{"questions":[]}`, validation.CodeEmptyBatch, nil},
+		{"array of empty objects", "[{},{}]", validation.CodeValidationFailed, []string{"[0].name", "[1].name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newQuestionsFake()
+			dynamoClient = fake
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 400 {
+				t.Fatalf("Handler = %d %s, %v, want 400", response.StatusCode, response.Body, err)
+			}
+			code, fields := errorFields(t, response)
+			if code != tt.wantCode || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("error %s %v, want %s %v", code, fields, tt.wantCode, tt.wantFields)
+			}
+			if n := fake.Count("PutItem") + fake.Count("BatchWriteItem"); n != 0 {
+				t.Errorf("%d writes, want none", n)
+			}
+		})
+	}
+}
+
+func TestHandlerBatchSizeWarning(t *testing.T) {
+	// Both import formats warn alike, dry runs included
+	formats := map[string]func(n int) events.APIGatewayProxyRequest{
+		"JSON": func(n int) events.APIGatewayProxyRequest {
+			body, _ := json.Marshal(questionRequests(n))
+			return events.APIGatewayProxyRequest{Body: string(body)}
+		},
+		"CSV": func(n int) events.APIGatewayProxyRequest {
+			var body strings.Builder
+			body.WriteString("name,date,difficulty\n")
+			for _, request := range questionRequests(n) {
+				fmt.Fprintf(&body, "%s,%s,%s\n", request.QuestionName, request.QuestionDate, request.QuestionDifficulty)
+			}
+			return events.APIGatewayProxyRequest{Headers: map[string]string{"Content-Type": "text/csv"}, Body: body.String()}
+		},
+	}
+	tests := []struct {
+		name string
+		size int
+		want string
+	}{
+		{"just below the threshold", 399, ""},
+		{"at the threshold", 400, "batch-size 400/500"},
+		{"at the limit", 500, "batch-size 500/500"},
+	}
+	for format, event := range formats {
+		for _, dryRun := range []bool{false, true} {
+			for _, tt := range tests {
+				t.Run(fmt.Sprintf("%s dry run %v %s", format, dryRun, tt.name), func(t *testing.T) {
+					dynamoClient = newQuestionsFake()
+					request := event(tt.size)
+					if dryRun {
+						request.QueryStringParameters = map[string]string{"dryRun": "true"}
+					}
+					response, err := Handler(context.Background(), request)
+					if err != nil || response.StatusCode != 200 {
+						t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+					}
+
+					if got := response.Headers[quota.WarningHeader]; got != tt.want {
+						t.Errorf("%s = %q, want %q", quota.WarningHeader, got, tt.want)
+					}
+					var payload struct {
+						Warnings []string `json:"warnings"`
+					}
+					if err := json.Unmarshal([]byte(response.Body), &payload); err != nil {
+						t.Fatalf("unmarshal body: %v", err)
+					}
+					var want []string
+					if tt.want != "" {
+						want = []string{tt.want}
+					}
+					if !reflect.DeepEqual(payload.Warnings, want) {
+						t.Errorf("warnings = %v, want %v", payload.Warnings, want)
+					}
+				})
+			}
+		}
+
+		dynamoClient = newQuestionsFake()
+		response, _ := Handler(context.Background(), event(501))
+		if response.StatusCode != 400 || response.Headers[quota.WarningHeader] != "" {
+			t.Errorf("%s with 501 questions = %d with warning %q, want 400 without one", format, response.StatusCode, response.Headers[quota.WarningHeader])
+		}
+	}
+}
@@ -2,15 +2,29 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/leetcode"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
 )
 
 type Request struct {
@@ -18,88 +32,236 @@ type Request struct {
 	QuestionDate       string   `json:"date"`
 	QuestionDifficulty string   `json:"difficulty"`
 	QuestionTags       []string `json:"tags"`
+	QuestionURL        string   `json:"url"`
+	QuestionNotes      string   `json:"notes"`
+	MinutesToSolve     *int     `json:"minutesToSolve"`
+	// TitleSlug, like "two-sum", fills in a missing difficulty and tags from
+	// LeetCode
+	TitleSlug string `json:"titleSlug"`
+	// Overwrite replaces an existing question of the same name instead of
+	// answering with a conflict
+	Overwrite bool `json:"overwrite"`
+
+	questionID int
+	// idempotencyKey comes from the IdempotencyKeyHeader, empty when absent
+	idempotencyKey string
 }
 
-var dynamoClient  *dynamodb.Client
-const tableName = "veet_code_questions_table"
+// IdempotencyKeyHeader lets clients retry an add safely. The key is stored on
+// the question item itself, next to an expiry, and the write is conditional
+// on it: a repeat of the key within IdempotencyWindow answers with the
+// original 200 without writing again. A new key, or one past its window,
+// goes through as a normal add. Keys are per question, not a global dedupe
+// table, so reusing one for a different question is not detected.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const IdempotencyWindow = 24 * time.Hour
+
+const maxIdempotencyKeyLength = 255
+
+// idempotency_expires_at is epoch seconds. It must not be made the table's
+// TTL attribute, or questions would be deleted along with their keys.
+const (
+	idempotencyKeyAttribute     = "idempotency_key"
+	idempotencyExpiresAttribute = "idempotency_expires_at"
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+// leetcodeClient is swapped for one with a stubbed HTTP client in tests
+var leetcodeClient = leetcode.NewClient()
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to load AWS SDK config: %v", err))
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-
-	dynamoClient = dynamodb.NewFromConfig(cfg)
 }
 
-func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (map[string]interface{}, error) {
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
 
-	fmt.Println("Raw Event:", event)
+	slog.Debug("Raw event", "event", event)
+
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
 
 	var request Request
-	err := json.Unmarshal([]byte(event.Body), &request)
+	err = json.Unmarshal([]byte(event.Body), &request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal request body: %v", err)
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+	request.idempotencyKey = awsutil.HeaderValue(event.Headers, IdempotencyKeyHeader)
+	request.QuestionDate = calendar.DateOrToday(request.QuestionDate, model.DateLayout, time.Now())
+
+	var fields validation.Fields
+	request.validate(&fields, "")
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
 	}
 
-	fmt.Println("Question Name: ", request.QuestionName)
-	fmt.Println("Question Date: ", request.QuestionDate)
-	fmt.Println("Question Difficulty: ", request.QuestionDifficulty)
-	fmt.Println("Question Tags: ", request.QuestionTags)
+	lookups, enriched := request.enrich(ctx)
+	request.QuestionDifficulty = model.InferDifficulty(request.QuestionName, request.QuestionDifficulty, lookups...)
+	request.QuestionTags = model.NormalizeTags(request.QuestionTags)
+
+	slog.Debug("Received question", "name", request.QuestionName, "date", request.QuestionDate, "difficulty", request.QuestionDifficulty, "tags", request.QuestionTags)
 
 	message := fmt.Sprintf("Question Name: %s, Question Date: %s, Question Difficulty: %s, Question Tags: %s", request.QuestionName, request.QuestionDate, request.QuestionDifficulty, request.QuestionTags)
-	
-	tagsJSON, err := json.Marshal(request.QuestionTags)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal tags: %v", err)
+
+	successMessage := "Question successfully added to DynamoDB."
+	fullMessage := fmt.Sprintf("%s %s", successMessage, message)
+	if !enriched {
+		fullMessage += " LeetCode lookup failed, so the question was saved as given."
 	}
 
-	err = putItemToDynamoDB(request, string(tagsJSON))
+	now := time.Now()
+	err = putItemToDynamoDB(ctx, request, now)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) && isRetry(conditionFailed.Item, request.idempotencyKey, now) {
+		slog.Info("Replaying idempotent add", "name", request.QuestionName, "idempotencyKey", request.idempotencyKey)
+		return awsutil.JSONResponse(200, map[string]string{
+			"message": fullMessage,
+		}), nil
+	}
+	if conditionFailed != nil {
+		return awsutil.APIError{
+			Code:    awsutil.CodeConflict,
+			Message: fmt.Sprintf("you already added question %q, set overwrite to replace it", request.QuestionName),
+			Details: map[string][]string{"names": {request.QuestionName}},
+		}.Response(ctx), nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to add item to DynamoDB: %v", err)
+		slog.Error("Failed to add item to DynamoDB", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to add the question"), nil
 	}
+	metrics.Emit(ctx, metrics.Count(metrics.QuestionsWritten, 1))
 
-	successMessage := "Question successfully added to DynamoDB."
-	fullMessage := fmt.Sprintf("%s %s", successMessage, message)
+	return awsutil.JSONResponse(200, map[string]string{
+		"message": fullMessage,
+	}), nil
+}
 
-	headers := map[string]string{
-		"Access-Control-Allow-Origin":      "*",           
-		"Access-Control-Allow-Methods":     "POST, OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+// enrich looks the question up on LeetCode when it has a titleSlug and lacks
+// a difficulty or tags, taking over the tags and question ID and returning the
+// difficulty as a lookup. It reports false only when the lookup failed;
+// failures never block the write.
+func (r *Request) enrich(ctx context.Context) ([]model.DifficultyLookup, bool) {
+	if r.TitleSlug == "" || (!model.IsUnknownDifficulty(r.QuestionDifficulty) && len(r.QuestionTags) > 0) {
+		return nil, true
 	}
 
-	body, err := json.Marshal(map[string]string{
-		"message": fullMessage,
-	})
+	problem, err := leetcodeClient.Problem(ctx, r.TitleSlug)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response body: %v", err)
+		slog.Warn("Failed to look the question up on LeetCode", "titleSlug", r.TitleSlug, "error", err)
+		return nil, false
 	}
 
-	return map[string]interface{}{
-		"statusCode": 200,
-		"headers":    headers,
-		"body": string(body),
-	}, nil
+	if len(r.QuestionTags) == 0 {
+		r.QuestionTags = problem.Tags
+	}
+	r.questionID = problem.ID
+	return []model.DifficultyLookup{func(string) (string, bool) {
+		return problem.Difficulty, true
+	}}, true
 }
 
-func putItemToDynamoDB(request Request, tagsJSON string) error {
+// validate records missing required fields, prefixing field names with prefix
+func (r Request) validate(fields *validation.Fields, prefix string) {
+	fields.Require(prefix+"name", r.QuestionName)
+	fields.Require(prefix+"date", r.QuestionDate)
+	if r.QuestionDate != "" {
+		if err := calendar.CheckNewDate(r.QuestionDate, time.Now(), calendar.Layouts...); err != nil {
+			fields.Add(prefix+"date", err.Error())
+		}
+	}
+	if r.QuestionURL != "" && !model.IsWebURL(r.QuestionURL) {
+		fields.Add(prefix+"url", "must be an http or https URL")
+	}
+	if r.MinutesToSolve != nil && *r.MinutesToSolve <= 0 {
+		fields.Add(prefix+"minutesToSolve", "must be a positive whole number")
+	}
+	if len(r.idempotencyKey) > maxIdempotencyKeyLength {
+		fields.Add(IdempotencyKeyHeader, fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength))
+	}
+}
+
+// putItemToDynamoDB fails with ConditionalCheckFailedException when the user
+// already stored the question, unless the request asks to overwrite it. The
+// table is keyed by user and name, so each user stores a name once. With an idempotency key, an overwrite also fails
+// when the stored item carries the same unexpired key; the exception holds
+// the stored item either way so isRetry can tell the cases apart.
+func putItemToDynamoDB(ctx context.Context, request Request, now time.Time) error {
+	item := model.QuestionKey(ctx, request.QuestionName)
+	item["question_solved_date"] = &types.AttributeValueMemberS{Value: calendar.NormalizeDate(request.QuestionDate)}
+	item["difficulty"] = &types.AttributeValueMemberS{Value: request.QuestionDifficulty}
+	item["tags"] = model.TagsAttributeValue(request.QuestionTags)
+	item["created_at"] = model.CreatedAtAttributeValue(now)
+	model.SetOptionalAttributes(item, request.QuestionURL, request.QuestionNotes, request.MinutesToSolve)
+	if request.questionID > 0 {
+		item["question_id"] = &types.AttributeValueMemberN{Value: strconv.Itoa(request.questionID)}
+	}
+
 	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item: map[string]types.AttributeValue{
-			"question_name":       &types.AttributeValueMemberS{Value: request.QuestionName},
-			"question_solved_date": &types.AttributeValueMemberS{Value: request.QuestionDate},
-			"difficulty":          &types.AttributeValueMemberS{Value: request.QuestionDifficulty},
-			"tags":                &types.AttributeValueMemberS{Value: tagsJSON},
-		},
+		TableName:                           aws.String(tenant.Table(ctx, tableName)),
+		Item:                                item,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if !request.Overwrite {
+		input.ConditionExpression = aws.String("attribute_not_exists(question_name)")
+	}
+	if request.idempotencyKey != "" {
+		item[idempotencyKeyAttribute] = &types.AttributeValueMemberS{Value: request.idempotencyKey}
+		item[idempotencyExpiresAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(IdempotencyWindow).Unix(), 10)}
+		if request.Overwrite {
+			input.ConditionExpression = aws.String("NOT (#key = :key AND #expires > :now)")
+			input.ExpressionAttributeNames = map[string]string{
+				"#key":     idempotencyKeyAttribute,
+				"#expires": idempotencyExpiresAttribute,
+			}
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":key": &types.AttributeValueMemberS{Value: request.idempotencyKey},
+				":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			}
+		}
 	}
 
-	_, err := dynamoClient.PutItem(context.TODO(), input)
+	_, err := dynamoClient.PutItem(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to put item in DynamoDB: %v", err)
+		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
 	}
 	return nil
 }
 
+// isRetry reports whether existing, the item that failed the write condition,
+// was written by an earlier request with the same key that is still within
+// its window
+func isRetry(existing map[string]types.AttributeValue, key string, now time.Time) bool {
+	if key == "" {
+		return false
+	}
+	stored, ok := existing[idempotencyKeyAttribute].(*types.AttributeValueMemberS)
+	if !ok || stored.Value != key {
+		return false
+	}
+	expires, ok := existing[idempotencyExpiresAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	seconds, err := strconv.ParseInt(expires.Value, 10, 64)
+	return err == nil && now.Unix() < seconds
+}
+
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
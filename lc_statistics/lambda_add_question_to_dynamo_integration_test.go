@@ -0,0 +1,74 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/legacystats"
+	"veet-code-go/internal/store"
+)
+
+// Run against DynamoDB Local with
+//
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test -tags integration \
+//		lambda_add_question_to_dynamo.go lambda_add_question_to_dynamo_integration_test.go
+func TestIntegrationAddRetrieveStatistics(t *testing.T) {
+	client := dynamotest.Local(t, dynamotest.QuestionsSchema)
+	dynamoClient = client
+	tableName = dynamotest.QuestionsSchema.Table
+	ctx := context.Background()
+
+	add := func(body string) int {
+		t.Helper()
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{Body: body})
+		if err != nil {
+			t.Fatalf("Handler(%s): %v", body, err)
+		}
+		return response.StatusCode
+	}
+
+	for _, body := range []string{
+		`{"name":"two-sum","date":"2024-03-01","difficulty":"Easy","tags":["Array","Hash Table"],"minutesToSolve":12}`,
+		`{"name":"clone-graph","date":"03/03/2024","difficulty":"Medium","tags":["Graph"]}`,
+		`{"name":"word-ladder","date":"2024-03-03","difficulty":"Hard","tags":["Graph","BFS"],"minutesToSolve":40}`,
+	} {
+		if status := add(body); status != 200 {
+			t.Fatalf("add %s = %d, want 200", body, status)
+		}
+	}
+	if status := add(`{"name":"two-sum","date":"2024-03-02","difficulty":"Easy"}`); status != 409 {
+		t.Errorf("adding an existing question = %d, want 409", status)
+	}
+	if status := add(`{"name":"two-sum","date":"2024-03-02","difficulty":"Easy","tags":["Array"],"overwrite":true}`); status != 200 {
+		t.Errorf("overwriting a question = %d, want 200", status)
+	}
+
+	questions, err := (&store.DynamoQuestionStore{Client: client, Table: tableName}).FetchAll(ctx)
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	if len(questions) != 3 {
+		t.Fatalf("retrieved %d questions, want 3", len(questions))
+	}
+
+	stats := legacystats.Generate(questions, nil)
+	if stats.TotalQuestionsCracked != 3 {
+		t.Errorf("total = %d, want 3", stats.TotalQuestionsCracked)
+	}
+	if want := map[string]int{"Easy": 1, "Medium": 1, "Hard": 1}; !reflect.DeepEqual(stats.QuestionsCrackedPerDifficulty, want) {
+		t.Errorf("per difficulty = %v, want %v", stats.QuestionsCrackedPerDifficulty, want)
+	}
+	// The legacy date is stored normalized, so both land on the same day
+	if want := map[string]int{"2024-03-02": 1, "2024-03-03": 2}; !reflect.DeepEqual(stats.QuestionsCrackedPerDay, want) {
+		t.Errorf("per day = %v, want %v", stats.QuestionsCrackedPerDay, want)
+	}
+	if got := stats.QuestionsCrackedPerTag["graph"]; got != 2 {
+		t.Errorf("graph questions = %d, want 2", got)
+	}
+}
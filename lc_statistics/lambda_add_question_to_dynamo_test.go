@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/leetcode"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+func newQuestionsFake(items ...map[string]types.AttributeValue) *dynamotest.Fake {
+	fake := dynamotest.New(tableName, items...)
+	fake.Keys = map[string][]string{tableName: {"user_id", "question_name"}}
+	return fake
+}
+
+func TestPutItemToDynamoDB(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	minutes := 25
+	request := Request{
+		QuestionName:       "two-sum",
+		QuestionDate:       "01/03/2024",
+		QuestionDifficulty: "Easy",
+		QuestionTags:       []string{"Array", "Hash Table", "Array"},
+		QuestionURL:        " https://leetcode.com/problems/two-sum/ ",
+		MinutesToSolve:     &minutes,
+	}
+
+	fake := newQuestionsFake()
+	dynamoClient = fake
+	if err := putItemToDynamoDB(context.Background(), request, now); err != nil {
+		t.Fatalf("putItemToDynamoDB: %v", err)
+	}
+
+	items := fake.Items(tableName)
+	if len(items) != 1 {
+		t.Fatalf("table holds %d items, want 1", len(items))
+	}
+	want := map[string]types.AttributeValue{
+		"user_id":              &types.AttributeValueMemberS{Value: user.DefaultOwner},
+		"question_name":        &types.AttributeValueMemberS{Value: "two-sum"},
+		"question_solved_date": &types.AttributeValueMemberS{Value: "2024-03-01"},
+		"difficulty":           &types.AttributeValueMemberS{Value: "Easy"},
+		"tags":                 &types.AttributeValueMemberSS{Value: []string{"Array", "Hash Table"}},
+		"created_at":           &types.AttributeValueMemberS{Value: "2024-03-01T12:30:00Z"},
+		"url":                  &types.AttributeValueMemberS{Value: "https://leetcode.com/problems/two-sum/"},
+		"minutes_to_solve":     &types.AttributeValueMemberN{Value: "25"},
+	}
+	if !reflect.DeepEqual(items[0], want) {
+		t.Errorf("item = %#v, want %#v", items[0], want)
+	}
+}
+
+func TestPutItemToDynamoDBExisting(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	existing := func() map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"user_id":              &types.AttributeValueMemberS{Value: user.DefaultOwner},
+			"question_name":        &types.AttributeValueMemberS{Value: "two-sum"},
+			"question_solved_date": &types.AttributeValueMemberS{Value: "2024-01-01"},
+		}
+	}
+
+	t.Run("conflict without overwrite", func(t *testing.T) {
+		fake := newQuestionsFake(existing())
+		dynamoClient = fake
+
+		err := putItemToDynamoDB(context.Background(), Request{QuestionName: "two-sum", QuestionDate: "2024-03-01"}, now)
+		var conditionFailed *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionFailed) {
+			t.Fatalf("err = %v, want ConditionalCheckFailedException", err)
+		}
+		if date := fake.Items(tableName)[0]["question_solved_date"]; !reflect.DeepEqual(date, existing()["question_solved_date"]) {
+			t.Errorf("stored date = %#v, want the original", date)
+		}
+	})
+
+	t.Run("another user's question is added alongside", func(t *testing.T) {
+		fake := newQuestionsFake(existing())
+		dynamoClient = fake
+
+		ctx := user.WithUser(context.Background(), "bob")
+		if err := putItemToDynamoDB(ctx, Request{QuestionName: "two-sum", QuestionDate: "2024-03-01"}, now); err != nil {
+			t.Fatalf("putItemToDynamoDB: %v", err)
+		}
+		if items := fake.Items(tableName); len(items) != 2 {
+			t.Errorf("table holds %d items, want 2", len(items))
+		}
+	})
+
+	t.Run("overwrite replaces", func(t *testing.T) {
+		fake := newQuestionsFake(existing())
+		dynamoClient = fake
+
+		err := putItemToDynamoDB(context.Background(), Request{QuestionName: "two-sum", QuestionDate: "2024-03-01", Overwrite: true}, now)
+		if err != nil {
+			t.Fatalf("putItemToDynamoDB: %v", err)
+		}
+		items := fake.Items(tableName)
+		if len(items) != 1 {
+			t.Fatalf("table holds %d items, want 1", len(items))
+		}
+		if date := items[0]["question_solved_date"].(*types.AttributeValueMemberS).Value; date != "2024-03-01" {
+			t.Errorf("stored date = %s, want 2024-03-01", date)
+		}
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		fake := newQuestionsFake()
+		dynamoClient = fake
+
+		request := Request{QuestionName: "two-sum", QuestionDate: "2024-03-01", Overwrite: true, idempotencyKey: "key-1"}
+		if err := putItemToDynamoDB(context.Background(), request, now); err != nil {
+			t.Fatalf("putItemToDynamoDB: %v", err)
+		}
+
+		input := fake.Calls[0].Input.(*dynamodb.PutItemInput)
+		wantExpiry := strconv.FormatInt(now.Add(IdempotencyWindow).Unix(), 10)
+		if got := input.Item[idempotencyExpiresAttribute].(*types.AttributeValueMemberN).Value; got != wantExpiry {
+			t.Errorf("expiry = %s, want %s", got, wantExpiry)
+		}
+		if got := aws.ToString(input.ConditionExpression); got != "NOT (#key = :key AND #expires > :now)" {
+			t.Errorf("condition = %q", got)
+		}
+	})
+}
+
+func TestHandlerIdempotentReplay(t *testing.T) {
+	now := time.Now()
+	stored := map[string]types.AttributeValue{
+		"question_name":             &types.AttributeValueMemberS{Value: "two-sum"},
+		idempotencyKeyAttribute:     &types.AttributeValueMemberS{Value: "key-1"},
+		idempotencyExpiresAttribute: &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(time.Hour).Unix(), 10)},
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"same key replays", "key-1", 200},
+		{"other key conflicts", "key-2", 409},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newQuestionsFake()
+			fake.Err = func(operation string, _ int) error {
+				if operation == "PutItem" {
+					return &types.ConditionalCheckFailedException{Item: stored}
+				}
+				return nil
+			}
+			dynamoClient = fake
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+				Headers: map[string]string{IdempotencyKeyHeader: tt.key},
+				Body:    `{"name":"two-sum","date":"2024-03-01","difficulty":"Easy"}`,
+			})
+			if err != nil {
+				t.Fatalf("Handler: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+// errorFields decodes the error envelope of a response into its code and the
+// names of its offending fields
+func errorFields(t *testing.T, response events.APIGatewayProxyResponse) (string, []string) {
+	t.Helper()
+	var body struct {
+		Error validation.Error `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("invalid error body %s: %v", response.Body, err)
+	}
+	var fields []string
+	for _, field := range body.Error.Fields {
+		fields = append(fields, field.Field)
+	}
+	return body.Error.Code, fields
+}
+
+// TestHandlerEmptyPayloads covers the payloads every write endpoint handles the
+// same way through the validation package
+func TestHandlerEmptyPayloads(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []string
+	}{
+		{"empty", "", validation.CodeEmptyBody, nil},
+		{"blank", " \n\t", validation.CodeEmptyBody, nil},
+		{"null", "null", validation.CodeEmptyBody, nil},
+		{"null with whitespace", " null ", validation.CodeEmptyBody, nil},
+		{"empty object", "{}", validation.CodeValidationFailed, []string{"name"}},
+		{"array", "[]", awsutil.CodeInvalidBody, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newQuestionsFake()
+			dynamoClient = fake
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 400 {
+				t.Fatalf("Handler = %d %s, %v, want 400", response.StatusCode, response.Body, err)
+			}
+			code, fields := errorFields(t, response)
+			if code != tt.wantCode || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("error %s %v, want %s %v", code, fields, tt.wantCode, tt.wantFields)
+			}
+			if n := fake.Count("PutItem") + fake.Count("BatchWriteItem"); n != 0 {
+				t.Errorf("%d writes, want none", n)
+			}
+		})
+	}
+}
+
+// stubLeetCode answers every LeetCode lookup with status and body, counting
+// the calls
+func stubLeetCode(t *testing.T, status int, body string) *int {
+	t.Helper()
+	calls := 0
+	leetcodeClient = &leetcode.Client{Endpoint: leetcode.DefaultEndpoint, HTTP: doerFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	t.Cleanup(func() { leetcodeClient = leetcode.NewClient() })
+	return &calls
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestHandlerEnrichesFromLeetCode(t *testing.T) {
+	const found = `{"data":{"question":{"questionFrontendId":"1","difficulty":"Easy","topicTags":[{"name":"Array"},{"name":"Hash Table"}]}}}`
+	tests := []struct {
+		name           string
+		body           string
+		status         int
+		response       string
+		wantCalls      int
+		wantDifficulty string
+		wantTags       []string
+		wantID         string
+		wantFailed     bool
+	}{
+		{"fills difficulty, tags and ID", `{"name":"my-two-sum","date":"2024-03-01","titleSlug":"two-sum"}`,
+			200, found, 1, "Easy", []string{"array", "hash table"}, "1", false},
+		{"keeps the given difficulty", `{"name":"my-two-sum","date":"2024-03-01","difficulty":"Medium","titleSlug":"two-sum"}`,
+			200, found, 1, "Medium", []string{"array", "hash table"}, "1", false},
+		{"keeps the given tags", `{"name":"my-two-sum","date":"2024-03-01","tags":["Hashing"],"titleSlug":"two-sum"}`,
+			200, found, 1, "Easy", []string{"hashing"}, "1", false},
+		{"no lookup with everything given", `{"name":"my-two-sum","date":"2024-03-01","difficulty":"Hard","tags":["Hashing"],"titleSlug":"two-sum"}`,
+			200, found, 0, "Hard", []string{"hashing"}, "", false},
+		{"no lookup without a slug", `{"name":"my-two-sum","date":"2024-03-01"}`,
+			200, found, 0, model.UnknownDifficulty, []string{}, "", false},
+		{"LeetCode down", `{"name":"my-two-sum","date":"2024-03-01","tags":["Hashing"],"titleSlug":"two-sum"}`,
+			503, "", 1, model.UnknownDifficulty, []string{"hashing"}, "", true},
+		{"unknown slug", `{"name":"my-two-sum","date":"2024-03-01","titleSlug":"no-such-question"}`,
+			200, `{"data":{"question":null}}`, 1, model.UnknownDifficulty, []string{}, "", true},
+		{"malformed answer", `{"name":"my-two-sum","date":"2024-03-01","difficulty":"Medium","titleSlug":"two-sum"}`,
+			200, "<html>", 1, "Medium", []string{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := stubLeetCode(t, tt.status, tt.response)
+			fake := newQuestionsFake()
+			dynamoClient = fake
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("LeetCode called %d times, want %d", *calls, tt.wantCalls)
+			}
+			if failed := strings.Contains(response.Body, "LeetCode lookup failed"); failed != tt.wantFailed {
+				t.Errorf("message %s, want lookup failure noted %v", response.Body, tt.wantFailed)
+			}
+
+			items := fake.Items(tableName)
+			if len(items) != 1 {
+				t.Fatalf("table holds %d items, want the question written anyway", len(items))
+			}
+			questions, err := model.QuestionsFromItems(items)
+			if err != nil {
+				t.Fatalf("QuestionsFromItems: %v", err)
+			}
+			sort.Strings(questions[0].Tags)
+			if questions[0].Difficulty != tt.wantDifficulty || !reflect.DeepEqual(questions[0].Tags, tt.wantTags) {
+				t.Errorf("stored %q with tags %v, want %q with %v", questions[0].Difficulty, questions[0].Tags, tt.wantDifficulty, tt.wantTags)
+			}
+			id := ""
+			if value, ok := items[0]["question_id"].(*types.AttributeValueMemberN); ok {
+				id = value.Value
+			}
+			if id != tt.wantID {
+				t.Errorf("question_id = %q, want %q", id, tt.wantID)
+			}
+		})
+	}
+}
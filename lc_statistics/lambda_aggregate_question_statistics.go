@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+var statsTableName = awsutil.TableName(aggregate.StatsTableEnv, aggregate.DefaultStatsTable)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler consumes the stream of a questions table, configured with
+// NEW_AND_OLD_IMAGES and ReportBatchItemFailures, and keeps that table's
+// statistics item up to date. Counter updates are not idempotent, so it stops
+// at the first failed record and has Lambda retry from there.
+func Handler(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	for _, record := range event.Records {
+		if err := applyRecord(ctx, record); err != nil {
+			slog.Error("Failed to apply stream record", "eventId", record.EventID, "error", err)
+			return events.DynamoDBEventResponse{
+				BatchItemFailures: []events.DynamoDBBatchItemFailure{{ItemIdentifier: record.Change.SequenceNumber}},
+			}, nil
+		}
+	}
+	return events.DynamoDBEventResponse{}, nil
+}
+
+// applyRecord takes the old image away from the counters and adds the new one,
+// so a MODIFY that moves a question to another day or difficulty moves its counts.
+// A record whose images can't be read would fail on every retry, so instead
+// the counters are marked stale from its date and the next statistics request
+// recalculates them.
+func applyRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
+	sourceTable, err := aggregate.TableFromARN(record.EventSourceArn)
+	if err != nil {
+		return err
+	}
+
+	deltas := aggregate.Deltas{}
+	if record.EventName == "MODIFY" || record.EventName == "REMOVE" {
+		q, err := aggregate.QuestionFromImage(record.Change.OldImage)
+		if err != nil {
+			return markStale(ctx, record, sourceTable, err)
+		}
+		deltas.Add(q, -1)
+	}
+	if record.EventName == "INSERT" || record.EventName == "MODIFY" {
+		q, err := aggregate.QuestionFromImage(record.Change.NewImage)
+		if err != nil {
+			return markStale(ctx, record, sourceTable, err)
+		}
+		deltas.Add(q, 1)
+	}
+
+	input := deltas.Update(statsTableName, sourceTable)
+	if input == nil {
+		return nil
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, input)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		slog.Info("Statistics are not seeded yet, skipping record", "sourceTable", sourceTable, "eventId", record.EventID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update statistics item: %w", err)
+	}
+	return nil
+}
+
+// markStale flags the whole history: an unreadable record may have moved
+// whole-history counters too, which a date range cannot rebuild
+func markStale(ctx context.Context, record events.DynamoDBEventRecord, sourceTable string, cause error) error {
+	slog.Warn("Skipping unreadable stream record, statistics will be recalculated",
+		"sourceTable", sourceTable, "eventId", record.EventID, "error", cause)
+	return aggregate.MarkStale(ctx, dynamoClient, statsTableName, sourceTable, "")
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(Handler))
+}
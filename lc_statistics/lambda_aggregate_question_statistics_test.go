@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+)
+
+const streamARN = "arn:aws:dynamodb:us-east-1:123456789012:table/veet_code_questions_table/stream/2024-03-01T00:00:00.000"
+
+func image(name, date, minutes string) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"question_name":        events.NewStringAttribute(name),
+		"question_solved_date": events.NewStringAttribute(date),
+		"difficulty":           events.NewStringAttribute("Easy"),
+		"minutes_to_solve":     events.NewNumberAttribute(minutes),
+	}
+}
+
+// unreadable is an image QuestionFromImage rejects, minutes being a String
+func unreadable(name, date string) map[string]events.DynamoDBAttributeValue {
+	item := image(name, date, "1")
+	item["minutes_to_solve"] = events.NewStringAttribute("a while")
+	return item
+}
+
+func record(id, eventName string, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:        id,
+		EventName:      eventName,
+		EventSourceArn: streamARN,
+		Change:         events.DynamoDBStreamRecord{OldImage: oldImage, NewImage: newImage, SequenceNumber: id},
+	}
+}
+
+func seededStats(t *testing.T, questions ...model.Question) *dynamotest.Fake {
+	t.Helper()
+	fake := dynamotest.New(statsTableName)
+	fake.Keys = map[string][]string{statsTableName: {aggregate.KeyAttribute}}
+	dynamoClient = fake
+	if err := aggregate.Seed(context.Background(), fake, statsTableName, "veet_code_questions_table", 0, questions); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	return fake
+}
+
+func TestHandlerSkipsUnreadableRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		record events.DynamoDBEventRecord
+	}{
+		{"insert", record("2", "INSERT", nil, unreadable("lru-cache", "2024-03-05"))},
+		{"remove", record("2", "REMOVE", unreadable("lru-cache", "05/03/2024"), nil)},
+		{"modify to an earlier date", record("2", "MODIFY", unreadable("lru-cache", "2024-03-05"), image("lru-cache", "2024-03-02", "9"))},
+		{"image without a date", record("2", "INSERT", nil, map[string]events.DynamoDBAttributeValue{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := seededStats(t)
+
+			response, err := Handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+				record("1", "INSERT", nil, image("two-sum", "2024-03-01", "10")),
+				tt.record,
+				record("3", "INSERT", nil, image("jump-game", "2024-03-06", "20")),
+			}})
+			if err != nil || len(response.BatchItemFailures) != 0 {
+				t.Fatalf("Handler = %+v, %v, want no failures", response, err)
+			}
+
+			snapshot, _, err := aggregate.Load(context.Background(), fake, statsTableName, "veet_code_questions_table")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !snapshot.Stale || snapshot.StaleFrom != "" {
+				t.Errorf("stale %v from %q, want the whole history", snapshot.Stale, snapshot.StaleFrom)
+			}
+			if snapshot.Totals.TotalQuestionsCracked != 2 {
+				t.Errorf("total = %d, want the readable records applied", snapshot.Totals.TotalQuestionsCracked)
+			}
+		})
+	}
+}
+
+func TestHandlerStopsAtFailedUpdate(t *testing.T) {
+	fake := seededStats(t)
+	fake.Err = func(op string, n int) error {
+		if op == "UpdateItem" && n == 2 {
+			return errors.New("throttled")
+		}
+		return nil
+	}
+
+	response, err := Handler(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("1", "INSERT", nil, image("two-sum", "2024-03-01", "10")),
+		record("2", "INSERT", nil, image("jump-game", "2024-03-06", "20")),
+		record("3", "INSERT", nil, image("lru-cache", "2024-03-07", "5")),
+	}})
+	if err != nil || len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Fatalf("Handler = %+v, %v, want a failure at record 2", response, err)
+	}
+}
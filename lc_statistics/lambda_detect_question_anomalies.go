@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/anomaly"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+type Anomaly struct {
+	Date      string           `json:"date"`
+	Count     int              `json:"count"`
+	Threshold float64          `json:"threshold"`
+	Severity  string           `json:"severity"`
+	Questions []model.Question `json:"questions"`
+}
+
+type Report struct {
+	K         float64   `json:"k"`
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+var defaultK float64
+
+func init() {
+	var err error
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+
+	defaultK, err = anomaly.KFromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load anomaly threshold: %v", err)
+	}
+}
+
+// Handler lists days with suspiciously many solved questions, along with the
+// questions recorded on them. ?k= overrides the threshold multiplier.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	k := defaultK
+	if value := event.QueryStringParameters["k"]; value != "" {
+		k, err = anomaly.ParseK(value)
+		if err != nil {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+		}
+	}
+
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	return awsutil.JSONResponse(200, detectAnomalies(questions, k)), nil
+}
+
+// detectAnomalies has no hard limit, since any count of questions is possible
+func detectAnomalies(questions []model.Question, k float64) Report {
+	questionsPerDay := make(map[time.Time][]model.Question)
+	for _, q := range questions {
+		date, err := calendar.ParseDate(q.Date)
+		if err != nil {
+			slog.Warn("Skipping question with invalid date", "question", q.Name, "error", err)
+			continue
+		}
+		questionsPerDay[date] = append(questionsPerDay[date], q)
+	}
+
+	var days []anomaly.Day
+	for date, dayQuestions := range questionsPerDay {
+		days = append(days, anomaly.Day{Date: date, Value: len(dayQuestions)})
+	}
+
+	report := Report{K: k, Anomalies: []Anomaly{}}
+	for _, finding := range anomaly.Detect(days, k, 0) {
+		report.Anomalies = append(report.Anomalies, Anomaly{
+			Date:      finding.Date.Format(model.DateLayout),
+			Count:     finding.Value,
+			Threshold: finding.Threshold,
+			Severity:  finding.Severity,
+			Questions: questionsPerDay[finding.Date],
+		})
+	}
+	return report
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/jsondiff"
+	"veet-code-go/internal/legacystats"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// defaultTolerance absorbs the rounding of averages to one decimal
+const defaultTolerance = 0.05
+
+// Report is the body of the diff endpoint. Left is the legacy scan-based
+// statistics, right the aggregate ones.
+type Report struct {
+	Questions int `json:"questions"`
+	jsondiff.Result
+}
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+// tagAliases come from TAG_ALIASES
+var tagAliases map[string]string
+
+func init() {
+	var err error
+	tagAliases, err = alias.Parse(os.Getenv(alias.EnvTagAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvTagAliases, err)
+	}
+
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler runs the legacy and the aggregate statistics over one scan of the
+// questions table and reports where they disagree. ?tolerance= overrides how
+// far apart two numbers may be and still count as equal.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	tolerance := defaultTolerance
+	if value := event.QueryStringParameters["tolerance"]; value != "" {
+		tolerance, err = strconv.ParseFloat(value, 64)
+		if err != nil || tolerance < 0 {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("invalid tolerance %q: use a number of at least 0", value)), nil
+		}
+	}
+
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	result, err := jsondiff.Compare(legacystats.Generate(questions, tagAliases), aggregateStatistics(questions), tolerance)
+	if err != nil {
+		slog.Error("Failed to compare statistics", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInternal, "failed to compare the statistics"), nil
+	}
+
+	slog.Info("Compared statistics implementations", "questions", len(questions), "pass", result.Pass,
+		"identicalSections", result.IdenticalSections, "differentSections", result.DifferentSections, "differences", len(result.Differences))
+	return awsutil.JSONResponse(200, Report{Questions: len(questions), Result: result}), nil
+}
+
+// aggregateStatistics shapes the aggregate snapshot of questions the way the
+// statistics endpoint serves it, tags folded after counting
+func aggregateStatistics(questions []model.Question) legacystats.Statistics {
+	snapshot := aggregate.FromQuestions(questions)
+	tags := alias.NewCanonicalizer(tagAliases)
+	snapshot.Totals.FoldTags(tags)
+	return legacystats.Statistics{QuestionTotals: snapshot.Totals, QuestionsCrackedPerDay: snapshot.PerDay, MergedKeys: tags.MergedKeys()}
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(user.RequireAdmin(Handler))))))
+}
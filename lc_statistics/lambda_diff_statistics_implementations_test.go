@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/jsondiff"
+	"veet-code-go/internal/store"
+)
+
+func questionItem(name, date, difficulty string, minutes string, tags ...string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: difficulty},
+		"tags":                 &types.AttributeValueMemberSS{Value: tags},
+	}
+	if minutes != "" {
+		item["minutes_to_solve"] = &types.AttributeValueMemberN{Value: minutes}
+	}
+	return item
+}
+
+func useQuestions(items ...map[string]types.AttributeValue) *dynamotest.Fake {
+	fake := dynamotest.New(tableName, items...)
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+	return fake
+}
+
+func TestHandlerComparesImplementations(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []map[string]types.AttributeValue
+		aliases   map[string]string
+		wantPass  bool
+		wantPaths []string
+	}{
+		{"empty table", nil, nil, true, nil},
+		{"implementations agree", []map[string]types.AttributeValue{
+			questionItem("two-sum", "2024-03-01", "Easy", "12", "Array", "Hash Table"),
+			questionItem("clone-graph", "05/03/2024", "Medium", "", "Graph"),
+			questionItem("word-ladder", "2024-03-05", "unknown", "40", "graph"),
+		}, nil, true, nil},
+		{"one question tagged with two spellings of an alias", []map[string]types.AttributeValue{
+			questionItem("climbing-stairs", "2024-03-01", "Easy", "", "dp", "Dynamic Programming"),
+		}, map[string]string{"dp": "dynamic programming"}, false, []string{
+			"$.questionsCrackedPerTag.dynamic programming",
+			"$.questionsPerTagPerDifficulty.dynamic programming.Easy",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useQuestions(tt.items...)
+			tagAliases = tt.aliases
+			t.Cleanup(func() { tagAliases = nil })
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			var report Report
+			if err := json.Unmarshal([]byte(response.Body), &report); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if report.Questions != len(tt.items) || report.Pass != tt.wantPass {
+				t.Fatalf("report %+v, want %d questions and pass %v", report, len(tt.items), tt.wantPass)
+			}
+			if report.IdenticalSections+report.DifferentSections == 0 {
+				t.Error("no sections compared")
+			}
+			var paths []string
+			for _, difference := range report.Differences {
+				if difference.Kind != jsondiff.KindChanged {
+					t.Errorf("difference %+v, want only changed values", difference)
+				}
+				paths = append(paths, difference.Path)
+			}
+			if len(paths) != len(tt.wantPaths) {
+				t.Fatalf("differences at %v, want %v", paths, tt.wantPaths)
+			}
+			for i := range paths {
+				if paths[i] != tt.wantPaths[i] {
+					t.Errorf("differences at %v, want %v", paths, tt.wantPaths)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     map[string]string
+		scanErr    error
+		wantStatus int
+	}{
+		{"custom tolerance", map[string]string{"tolerance": "0.5"}, nil, 200},
+		{"tolerance that isn't a number", map[string]string{"tolerance": "loose"}, nil, 400},
+		{"negative tolerance", map[string]string{"tolerance": "-1"}, nil, 400},
+		{"scan fails", nil, errors.New("boom"), 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useQuestions(questionItem("two-sum", "2024-03-01", "Easy", ""))
+			fake.Err = func(string, int) error { return tt.scanErr }
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: tt.params})
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Errorf("Handler = %d %s, %v, want %d", response.StatusCode, response.Body, err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+type Request struct {
+	QuestionsPerDay int `json:"questionsPerDay"`
+	MinutesPerDay   int `json:"minutesPerDay"`
+	// StartDate defaults to today
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+var goalsTableName = awsutil.TableName(goal.TableEnv, goal.DefaultTable)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler serves /goal: GET returns the daily goal, POST replaces it. Both
+// statistics lambdas report progress against it.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	if event.HTTPMethod == "GET" {
+		g, found, err := goal.Load(ctx, dynamoClient, goalsTableName)
+		if err != nil {
+			slog.Error("Failed to load goal", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+		}
+		if !found {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "no goal is set"), nil
+		}
+		return awsutil.JSONResponse(200, g), nil
+	}
+
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	var request Request
+	err = json.Unmarshal([]byte(event.Body), &request)
+	if err != nil {
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+
+	if request.StartDate == "" {
+		request.StartDate = calendar.Today(time.Now()).Format(goal.DateLayout)
+	}
+	var fields validation.Fields
+	request.validate(&fields)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	g := goal.Goal{
+		QuestionsPerDay: request.QuestionsPerDay,
+		MinutesPerDay:   request.MinutesPerDay,
+		StartDate:       request.StartDate,
+		EndDate:         request.EndDate,
+	}
+	if err := goal.Save(ctx, dynamoClient, goalsTableName, g); err != nil {
+		slog.Error("Failed to save goal", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to save the goal"), nil
+	}
+
+	slog.Info("Goal set", "questionsPerDay", g.QuestionsPerDay, "minutesPerDay", g.MinutesPerDay, "startDate", g.StartDate, "endDate", g.EndDate)
+	return awsutil.JSONResponse(200, g), nil
+}
+
+func (r Request) validate(fields *validation.Fields) {
+	if r.QuestionsPerDay < 0 {
+		fields.Add("questionsPerDay", "must not be negative")
+	}
+	if r.MinutesPerDay < 0 {
+		fields.Add("minutesPerDay", "must not be negative")
+	}
+	if r.QuestionsPerDay == 0 && r.MinutesPerDay == 0 {
+		fields.Add("questionsPerDay", "or minutesPerDay must be set")
+	}
+
+	start, err := time.Parse(goal.DateLayout, r.StartDate)
+	if err != nil {
+		fields.Add("startDate", "must be YYYY-MM-DD")
+	}
+	if r.EndDate != "" {
+		end, err := time.Parse(goal.DateLayout, r.EndDate)
+		if err != nil {
+			fields.Add("endDate", "must be YYYY-MM-DD")
+		} else if end.Before(start) {
+			fields.Add("endDate", "must not be before startDate")
+		}
+	}
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+)
+
+type Request struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type Failure struct {
+	QuestionName string `json:"name"`
+	Error        string `json:"error"`
+}
+
+type Report struct {
+	DryRun   bool      `json:"dryRun"`
+	Migrated int       `json:"migrated"`
+	Skipped  int       `json:"skipped"`
+	Failed   int       `json:"failed"`
+	Failures []Failure `json:"failures"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler rewrites legacy items (JSON-string tags, dd/MM/yyyy dates) in the
+// canonical format. Canonical items are skipped, so running it twice is safe.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	var request Request
+	if event.Body != "" {
+		if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
+			slog.Warn("Failed to unmarshal request body", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+		}
+	}
+
+	report, err := migrateQuestions(ctx, request.DryRun)
+	if err != nil {
+		slog.Error("Failed to migrate questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to migrate questions"), nil
+	}
+
+	return awsutil.JSONResponse(200, report), nil
+}
+
+func migrateQuestions(ctx context.Context, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Failures: []Failure{}}
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+	}
+
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return report, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		for _, item := range page.Items {
+			name := ""
+			if v, ok := item["question_name"].(*types.AttributeValueMemberS); ok {
+				name = v.Value
+			}
+
+			updates, err := legacyUpdates(item)
+			if err == nil && len(updates) > 0 && !dryRun {
+				err = updateQuestion(ctx, model.ItemKey(item), updates)
+			}
+
+			switch {
+			case err != nil:
+				slog.Error("Failed to migrate question", "question", name, "error", err)
+				report.Failed++
+				report.Failures = append(report.Failures, Failure{QuestionName: name, Error: err.Error()})
+			case len(updates) == 0:
+				report.Skipped++
+			default:
+				report.Migrated++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// legacyUpdates returns the canonical value of every attribute that is still
+// stored in a legacy representation
+func legacyUpdates(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	updates := make(map[string]types.AttributeValue)
+
+	if model.IsLegacyTags(item["tags"]) {
+		tags, err := model.ParseTags(item["tags"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse legacy tags: %w", err)
+		}
+		updates["tags"] = model.TagsAttributeValue(tags)
+	}
+
+	if v, ok := item["question_solved_date"].(*types.AttributeValueMemberS); ok {
+		if _, err := calendar.ParseDate(v.Value); err != nil {
+			return nil, err
+		}
+		if normalized := calendar.NormalizeDate(v.Value); normalized != v.Value {
+			updates["question_solved_date"] = &types.AttributeValueMemberS{Value: normalized}
+		}
+	}
+
+	return updates, nil
+}
+
+// updateQuestion rewrites attributes of the item under key, whoever owns it
+func updateQuestion(ctx context.Context, key map[string]types.AttributeValue, updates map[string]types.AttributeValue) error {
+	expression := ""
+	names := make(map[string]string)
+	values := make(map[string]types.AttributeValue)
+	for attribute, value := range updates {
+		if expression != "" {
+			expression += ", "
+		}
+		expression += fmt.Sprintf("#%s = :%s", attribute, attribute)
+		names["#"+attribute] = attribute
+		values[":"+attribute] = value
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tenant.Table(ctx, tableName)),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + expression),
+		ConditionExpression:       aws.String("attribute_exists(question_name)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(Handler))))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// SourceTableEnv names the questions table keyed by question_name alone,
+// which the questions are copied from
+const SourceTableEnv = "SOURCE_QUESTIONS_TABLE_NAME"
+
+type Request struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type Failure struct {
+	QuestionName string `json:"name"`
+	Error        string `json:"error"`
+}
+
+type Report struct {
+	DryRun   bool      `json:"dryRun"`
+	Copied   int       `json:"copied"`
+	Skipped  int       `json:"skipped"`
+	Failed   int       `json:"failed"`
+	Failures []Failure `json:"failures"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+var sourceTableName = os.Getenv(SourceTableEnv)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler copies every question of the source table into the questions
+// table, which is keyed by user_id and question_name. Items without an owner
+// go to user.LegacyOwner. Questions already copied are skipped, so running it
+// again after a failure is safe; a dry run counts every question as copied.
+// The source table is left as it is.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+	if sourceTableName == "" {
+		slog.Error("No source table configured", "env", SourceTableEnv)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInternal, "no source table configured"), nil
+	}
+
+	var request Request
+	if event.Body != "" {
+		if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
+			slog.Warn("Failed to unmarshal request body", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+		}
+	}
+
+	report, err := copyQuestions(ctx, request.DryRun)
+	if err != nil {
+		slog.Error("Failed to copy questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to copy questions"), nil
+	}
+
+	return awsutil.JSONResponse(200, report), nil
+}
+
+func copyQuestions(ctx context.Context, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Failures: []Failure{}}
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, sourceTableName)),
+	}
+
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return report, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		for _, item := range page.Items {
+			name := ""
+			if v, ok := item[model.NameAttribute].(*types.AttributeValueMemberS); ok {
+				name = v.Value
+			}
+			if _, ok := item[user.Attribute].(*types.AttributeValueMemberS); !ok {
+				item[user.Attribute] = &types.AttributeValueMemberS{Value: user.LegacyOwner()}
+			}
+
+			var err error
+			if !dryRun {
+				err = copyQuestion(ctx, item)
+			}
+
+			var conditionFailed *types.ConditionalCheckFailedException
+			switch {
+			case errors.As(err, &conditionFailed):
+				report.Skipped++
+			case err != nil:
+				slog.Error("Failed to copy question", "question", name, "error", err)
+				report.Failed++
+				report.Failures = append(report.Failures, Failure{QuestionName: name, Error: err.Error()})
+			default:
+				report.Copied++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// copyQuestion writes item unless its owner already has the question, which
+// fails with ConditionalCheckFailedException
+func copyQuestion(ctx context.Context, item map[string]types.AttributeValue) error {
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(tenant.Table(ctx, tableName)),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(question_name)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(Handler))))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/user"
+)
+
+func TestHandlerCopiesQuestionsUnderTheirOwner(t *testing.T) {
+	sourceTableName = "veet_code_questions_source"
+	t.Cleanup(func() { sourceTableName = "" })
+
+	question := func(owner, name string) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{"question_name": &types.AttributeValueMemberS{Value: name}}
+		if owner != "" {
+			item[user.Attribute] = &types.AttributeValueMemberS{Value: owner}
+		}
+		return item
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantReport Report
+		wantKeys   []string
+	}{
+		{"copy", `{}`, Report{Copied: 2, Skipped: 1, Failures: []Failure{}},
+			[]string{"bob/word-ladder", user.DefaultOwner + "/jump-game", user.DefaultOwner + "/two-sum"}},
+		{"dry run", `{"dryRun":true}`, Report{DryRun: true, Copied: 3, Failures: []Failure{}},
+			[]string{user.DefaultOwner + "/two-sum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName, question(user.DefaultOwner, "two-sum"))
+			fake.Tables[sourceTableName] = []map[string]types.AttributeValue{
+				question("", "two-sum"),
+				question("", "jump-game"),
+				question("bob", "word-ladder"),
+			}
+			fake.Keys = map[string][]string{tableName: {user.Attribute, "question_name"}}
+			dynamoClient = fake
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			var report Report
+			if err := json.Unmarshal([]byte(response.Body), &report); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if !reflect.DeepEqual(report, tt.wantReport) {
+				t.Errorf("report = %+v, want %+v", report, tt.wantReport)
+			}
+
+			var keys []string
+			for _, item := range fake.Items(tableName) {
+				keys = append(keys, item[user.Attribute].(*types.AttributeValueMemberS).Value+"/"+item["question_name"].(*types.AttributeValueMemberS).Value)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("questions = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutSourceTable(t *testing.T) {
+	dynamoClient = dynamotest.New(tableName)
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 500 {
+		t.Errorf("Handler = %d, %v, want 500", response.StatusCode, err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+type Request struct {
+	Date string `json:"date"`
+}
+
+type Response struct {
+	Name     string `json:"name"`
+	Date     string `json:"date"`
+	Attempts int    `json:"attempts"`
+	// FirstSolve is true when the question did not exist and was created
+	FirstSolve bool `json:"firstSolve"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+var statsTableName = awsutil.TableName(aggregate.StatsTableEnv, aggregate.DefaultStatsTable)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler serves POST /questions/{name}/attempts. Solving a stored question
+// again appends the date to its review dates and bumps its attempts, leaving
+// question_solved_date as the first solve; an unknown question is created
+// with this as its first solve.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	var request Request
+	err = json.Unmarshal([]byte(event.Body), &request)
+	if err != nil {
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+
+	name := event.PathParameters["name"]
+	request.Date = calendar.DateOrToday(request.Date, model.DateLayout, time.Now())
+	var fields validation.Fields
+	fields.Require("name", name)
+	if err := calendar.CheckNewDate(request.Date, time.Now(), calendar.Layouts...); err != nil {
+		fields.Add("date", err.Error())
+	}
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+	date := calendar.NormalizeDate(request.Date)
+
+	response := Response{Name: name, Date: date}
+	response.Attempts, err = recordReview(ctx, name, date)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		response.Attempts, response.FirstSolve = 1, true
+		err = recordFirstSolve(ctx, name, date)
+	}
+	if errors.As(err, &conditionFailed) {
+		// Created by a concurrent request in between, so this is a review after all
+		response.FirstSolve = false
+		response.Attempts, err = recordReview(ctx, name, date)
+	}
+	if errors.As(err, &conditionFailed) {
+		// Only when the question was deleted in between as well
+		return awsutil.ErrorResponse(ctx, awsutil.CodeConflict, fmt.Sprintf("question %q changed while recording the attempt, try again", name)), nil
+	}
+	if err != nil {
+		slog.Error("Failed to record attempt", "question", name, "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to record the attempt"), nil
+	}
+
+	// A backdated attempt lands among days the precomputed statistics already
+	// counted, so they are recalculated from its date on
+	if err := aggregate.MarkStaleIfBackdated(ctx, dynamoClient, statsTableName, tenant.Table(ctx, tableName), date); err != nil {
+		slog.Warn("Failed to mark statistics stale", "date", date, "error", err)
+	}
+
+	slog.Info("Attempt recorded", "question", name, "date", date, "attempts", response.Attempts)
+	return awsutil.JSONResponse(200, response), nil
+}
+
+// recordReview returns the new number of attempts. It fails with
+// ConditionalCheckFailedException when the user has no such question.
+func recordReview(ctx context.Context, name, date string) (int, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+		Key:       model.QuestionKey(ctx, name),
+		// Items from before attempts were recorded count as solved once
+		UpdateExpression:    aws.String("SET attempts = if_not_exists(attempts, :one) + :one, solve_dates = list_append(if_not_exists(solve_dates, :empty), :dates)"),
+		ConditionExpression: aws.String("attribute_exists(question_name)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+			":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":dates": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberS{Value: date},
+			}},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+
+	output, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	attempts, ok := output.Attributes["attempts"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, errors.New("update returned no attempts attribute")
+	}
+	return strconv.Atoi(attempts.Value)
+}
+
+// recordFirstSolve creates the question with an unknown difficulty. It fails
+// with ConditionalCheckFailedException when the question exists.
+func recordFirstSolve(ctx context.Context, name, date string) error {
+	item := model.QuestionKey(ctx, name)
+	item["question_solved_date"] = &types.AttributeValueMemberS{Value: date}
+	item["difficulty"] = &types.AttributeValueMemberS{Value: model.InferDifficulty(name, "")}
+	item["tags"] = model.TagsAttributeValue(nil)
+	item["created_at"] = model.CreatedAtAttributeValue(time.Now())
+	item["attempts"] = &types.AttributeValueMemberN{Value: "1"}
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(tenant.Table(ctx, tableName)),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(question_name)"),
+	}
+
+	_, err := dynamoClient.PutItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
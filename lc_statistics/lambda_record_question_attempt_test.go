@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/user"
+)
+
+func questionItem(name, date string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"user_id":              &types.AttributeValueMemberS{Value: user.DefaultOwner},
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: "Easy"},
+		"tags":                 &types.AttributeValueMemberSS{Value: []string{"Array"}},
+	}
+}
+
+func TestHandlerMarksBackdatedAttemptsStale(t *testing.T) {
+	tests := []struct {
+		name          string
+		question      string
+		date          string
+		wantStale     bool
+		wantStaleFrom string
+	}{
+		{"review before the newest day", "two-sum", "2024-03-04", true, "2024-03-04"},
+		{"first solve before the newest day", "jump-game", "05/03/2024", true, "2024-03-05"},
+		{"review on a new day", "two-sum", "2024-03-09", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			items := []map[string]types.AttributeValue{
+				questionItem("two-sum", "2024-03-01"),
+				questionItem("word-ladder", "2024-03-08"),
+			}
+			questions, err := model.QuestionsFromItems(items)
+			if err != nil {
+				t.Fatalf("QuestionsFromItems: %v", err)
+			}
+			fake := dynamotest.New(tableName, items...)
+			fake.Keys = map[string][]string{tableName: {"user_id", "question_name"}, statsTableName: {aggregate.KeyAttribute}}
+			dynamoClient = fake
+			if err := aggregate.Seed(ctx, fake, statsTableName, tableName, 0, questions); err != nil {
+				t.Fatalf("Seed: %v", err)
+			}
+
+			response, err := Handler(ctx, events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"name": tt.question},
+				Body:           `{"date":"` + tt.date + `"}`,
+			})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+
+			snapshot, _, err := aggregate.Load(ctx, fake, statsTableName, tableName)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if snapshot.Stale != tt.wantStale || snapshot.StaleFrom != tt.wantStaleFrom {
+				t.Errorf("stale %v from %q, want %v from %q", snapshot.Stale, snapshot.StaleFrom, tt.wantStale, tt.wantStaleFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
+)
+
+// Study mirrors the studies table, which belongs to the study_statistics module
+type Study struct {
+	StudyTheme     string            `dynamodbav:"study_theme"`
+	StudyDate      string            `dynamodbav:"study_date"`
+	MinutesOfStudy studytime.Minutes `dynamodbav:"minutes_of_study"`
+}
+
+// HeatmapDay is one cell of the calendar. Levels are 0 for no activity and
+// otherwise 1 to 4 by the quartile of the range's active days the value
+// falls in; Level is the higher of the two.
+type HeatmapDay struct {
+	Date           string `json:"date"`
+	Questions      int    `json:"questions"`
+	Minutes        int    `json:"minutes"`
+	QuestionsLevel int    `json:"questionsLevel"`
+	MinutesLevel   int    `json:"minutesLevel"`
+	Level          int    `json:"level"`
+}
+
+// HeatmapWeek is one column, Monday to Sunday. The current week stops at
+// today.
+type HeatmapWeek struct {
+	Year int          `json:"year"`
+	Week int          `json:"week"`
+	Days []HeatmapDay `json:"days"`
+}
+
+type Heatmap struct {
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	Weeks []HeatmapWeek `json:"weeks"`
+}
+
+const (
+	defaultWeeks = 52
+	maxWeeks     = 53
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+var questionStore store.QuestionStore
+
+const (
+	defaultQuestionsTableName = "veet_code_questions_table"
+	defaultStudiesTableName   = "studies_table"
+)
+
+var (
+	questionsTableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultQuestionsTableName)
+	studiesTableName   = awsutil.TableName(awsutil.StudiesTableEnv, defaultStudiesTableName)
+)
+
+func init() {
+	client, err := awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+	dynamoClient = client
+	questionStore = &store.DynamoQuestionStore{Client: client, Table: questionsTableName}
+}
+
+// Handler returns the questions solved and minutes studied per day over the
+// trailing 52 ISO weeks, or ?weeks=N, with inactive days zero-filled
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	weeks := defaultWeeks
+	if value := event.QueryStringParameters["weeks"]; value != "" {
+		weeks, err = strconv.Atoi(value)
+		if err != nil || weeks < 1 || weeks > maxWeeks {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("invalid weeks %q: use a whole number from 1 to %d", value, maxWeeks)), nil
+		}
+	}
+
+	var (
+		wg                       sync.WaitGroup
+		questions                []model.Question
+		studies                  []Study
+		questionsErr, studiesErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		questions, questionsErr = questionStore.FetchAll(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		studies, studiesErr = fetchAllStudies(ctx)
+	}()
+	wg.Wait()
+
+	if questionsErr != nil || studiesErr != nil {
+		slog.Error("Failed to fetch activity", "questionsError", questionsErr, "studiesError", studiesErr)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	questionsPerDay := make(map[time.Time]int)
+	for _, q := range questions {
+		if day, err := calendar.ParseDate(q.Date); err == nil {
+			questionsPerDay[day]++
+		}
+	}
+	minutesPerDay := make(map[time.Time]int)
+	for _, study := range studies {
+		if day, err := calendar.ParseDate(study.StudyDate); err == nil {
+			minutesPerDay[day] += int(study.MinutesOfStudy)
+		}
+	}
+
+	heatmap := buildHeatmap(questionsPerDay, minutesPerDay, calendar.Today(time.Now()), weeks)
+	return awsutil.Compress(event, awsutil.JSONResponse(200, heatmap)), nil
+}
+
+func fetchAllStudies(ctx context.Context) (studies []Study, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllStudies")
+	defer func() { segment.End(err) }()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, studiesTableName)),
+	}
+	user.ScopeScan(ctx, input)
+
+	start := time.Now()
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		var pageStudies []Study
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageStudies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
+		}
+
+		studies = append(studies, pageStudies...)
+	}
+
+	metrics.Scan(ctx, start, len(studies))
+	return studies, nil
+}
+
+// buildHeatmap lays out the weeks ending with the one holding today. Levels
+// are ranked against the active days inside the range only.
+func buildHeatmap(questionsPerDay, minutesPerDay map[time.Time]int, today time.Time, weeks int) Heatmap {
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	from := monday.AddDate(0, 0, -7*(weeks-1))
+
+	var days []HeatmapDay
+	var activeQuestions, activeMinutes []int
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		cell := HeatmapDay{
+			Date:      day.Format(model.DateLayout),
+			Questions: questionsPerDay[day],
+			Minutes:   minutesPerDay[day],
+		}
+		if cell.Questions > 0 {
+			activeQuestions = append(activeQuestions, cell.Questions)
+		}
+		if cell.Minutes > 0 {
+			activeMinutes = append(activeMinutes, cell.Minutes)
+		}
+		days = append(days, cell)
+	}
+
+	questionsQuartiles := quartiles(activeQuestions)
+	minutesQuartiles := quartiles(activeMinutes)
+	heatmap := Heatmap{From: from.Format(model.DateLayout), To: today.Format(model.DateLayout), Weeks: []HeatmapWeek{}}
+	for i := range days {
+		days[i].QuestionsLevel = level(days[i].Questions, questionsQuartiles)
+		days[i].MinutesLevel = level(days[i].Minutes, minutesQuartiles)
+		days[i].Level = max(days[i].QuestionsLevel, days[i].MinutesLevel)
+
+		if i%7 == 0 {
+			year, week := from.AddDate(0, 0, i).ISOWeek()
+			heatmap.Weeks = append(heatmap.Weeks, HeatmapWeek{Year: year, Week: week})
+		}
+		current := &heatmap.Weeks[len(heatmap.Weeks)-1]
+		current.Days = append(current.Days, days[i])
+	}
+	return heatmap
+}
+
+// quartiles returns the nearest-rank 25th, 50th and 75th percentiles
+func quartiles(values []int) [3]int {
+	var cuts [3]int
+	if len(values) == 0 {
+		return cuts
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	for i := range cuts {
+		rank := (len(sorted)*(i+1) + 3) / 4
+		cuts[i] = sorted[rank-1]
+	}
+	return cuts
+}
+
+// level maps value to 0 when inactive, otherwise 1 to 4 by the quartile it
+// falls in
+func level(value int, cuts [3]int) int {
+	if value <= 0 {
+		return 0
+	}
+	result := 1
+	for _, cut := range cuts {
+		if value > cut {
+			result++
+		}
+	}
+	return result
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/legacystats"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/studystats"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
+)
+
+// CombinedDay pairs the questions solved and minutes studied on a day
+type CombinedDay struct {
+	Date      string `json:"date"`
+	Questions int    `json:"questions"`
+	Minutes   int    `json:"minutes"`
+}
+
+// Combined joins both tables by day. PerDay doubles as the points of a
+// minutes against questions scatter plot.
+type Combined struct {
+	PerDay []CombinedDay `json:"perDay"`
+	// Correlation is the Pearson coefficient between minutes studied and
+	// questions solved over PerDay, rounded to three decimals. It is null
+	// with fewer than two days or when either side never varies.
+	Correlation *float64 `json:"correlation"`
+}
+
+// sectionError replaces a section whose table could not be read
+type sectionError struct {
+	Error awsutil.APIError `json:"error"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+var questionStore store.QuestionStore
+
+const (
+	defaultQuestionsTableName = "veet_code_questions_table"
+	defaultStudiesTableName   = "studies_table"
+)
+
+var (
+	questionsTableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultQuestionsTableName)
+	studiesTableName   = awsutil.TableName(awsutil.StudiesTableEnv, defaultStudiesTableName)
+	goalsTableName     = awsutil.TableName(goal.TableEnv, goal.DefaultTable)
+)
+
+// tagAliases and themeAliases come from TAG_ALIASES and THEME_ALIASES, as in
+// the statistics lambdas
+var tagAliases, themeAliases map[string]string
+
+func init() {
+	var err error
+	tagAliases, err = alias.Parse(os.Getenv(alias.EnvTagAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvTagAliases, err)
+	}
+	themeAliases, err = alias.Parse(os.Getenv(alias.EnvThemeAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvThemeAliases, err)
+	}
+
+	client, err := awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+	dynamoClient = client
+	questionStore = &store.DynamoQuestionStore{Client: client, Table: questionsTableName}
+}
+
+// Handler returns {"questions": {...}, "studies": {...}, "combined": {...}},
+// reading both tables concurrently. A section that fails carries an error
+// instead and is named in "warnings", the combined series is left out, and
+// only when both fail is the whole request a 500.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	var (
+		wg                       sync.WaitGroup
+		questions                []model.Question
+		studies                  []studystats.Study
+		questionsErr, studiesErr error
+	)
+	// Both goroutines share dynamoClient: the SDK's dynamodb.Client is safe
+	// for concurrent use, and each scan keeps its own paginator and results.
+	// Errors are kept per table rather than first-error-wins, so one failed
+	// scan does not discard the other section.
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		questions, questionsErr = questionStore.FetchAll(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		studies, studiesErr = fetchAllStudies(ctx)
+	}()
+	wg.Wait()
+
+	if questionsErr != nil && studiesErr != nil {
+		slog.Error("Failed to fetch questions", "error", questionsErr)
+		slog.Error("Failed to fetch studies", "error", studiesErr)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	dashboard := make(map[string]any)
+	warnings := []string{}
+	if questionsErr != nil {
+		slog.Error("Failed to fetch questions", "error", questionsErr)
+		dashboard["questions"] = sectionError{awsutil.APIError{Code: awsutil.CodeDatabaseError, Message: "failed to read questions"}}
+		warnings = append(warnings, "questions could not be read, so the combined series is missing")
+	} else {
+		stats := legacystats.Generate(questions, tagAliases)
+		stats.GoalProgress = legacystats.TrackGoal(ctx, dynamoClient, goalsTableName, stats.QuestionsCrackedPerDay)
+		dashboard["questions"] = stats
+	}
+	if studiesErr != nil {
+		slog.Error("Failed to fetch studies", "error", studiesErr)
+		dashboard["studies"] = sectionError{awsutil.APIError{Code: awsutil.CodeDatabaseError, Message: "failed to read studies"}}
+		warnings = append(warnings, "studies could not be read, so the combined series is missing")
+	} else {
+		stats := studystats.Generate(studies, themeAliases)
+		stats.GoalProgress = studystats.TrackGoal(ctx, dynamoClient, goalsTableName, studies)
+		dashboard["studies"] = stats
+	}
+	if questionsErr == nil && studiesErr == nil {
+		perDay := combinedPerDay(questions, studies)
+		dashboard["combined"] = Combined{PerDay: perDay, Correlation: correlation(perDay)}
+	}
+	dashboard["warnings"] = warnings
+
+	return awsutil.Compress(event, awsutil.JSONResponse(200, dashboard)), nil
+}
+
+func fetchAllStudies(ctx context.Context) (studies []studystats.Study, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllStudies")
+	defer func() { segment.End(err) }()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, studiesTableName)),
+	}
+	user.ScopeScan(ctx, input)
+
+	start := time.Now()
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		var pageStudies []studystats.Study
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageStudies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
+		}
+
+		studies = append(studies, pageStudies...)
+	}
+
+	metrics.Scan(ctx, start, len(studies))
+	return studies, nil
+}
+
+// combinedPerDay lists every day with a solve or a study in date order, a
+// day missing from one table counting as zero there.
+// Question and study dates use different layouts, so both are parsed and the
+// days are reported in the canonical one; unparseable dates are left out.
+func combinedPerDay(questions []model.Question, studies []studystats.Study) []CombinedDay {
+	perDay := make(map[time.Time]*CombinedDay)
+	day := func(value string) *CombinedDay {
+		date, err := calendar.ParseDate(value)
+		if err != nil {
+			return nil
+		}
+		if _, ok := perDay[date]; !ok {
+			perDay[date] = &CombinedDay{Date: date.Format(model.DateLayout)}
+		}
+		return perDay[date]
+	}
+
+	for _, q := range questions {
+		if d := day(q.Date); d != nil {
+			d.Questions++
+		}
+	}
+	for _, study := range studies {
+		if d := day(study.StudyDate); d != nil {
+			d.Minutes += int(study.MinutesOfStudy)
+		}
+	}
+
+	combined := make([]CombinedDay, 0, len(perDay))
+	for _, d := range perDay {
+		combined = append(combined, *d)
+	}
+	// The canonical layout sorts chronologically as text
+	sort.Slice(combined, func(i, j int) bool { return combined[i].Date < combined[j].Date })
+	return combined
+}
+
+func correlation(days []CombinedDay) *float64 {
+	n := float64(len(days))
+	if n < 2 {
+		return nil
+	}
+
+	var sumMinutes, sumQuestions float64
+	for _, d := range days {
+		sumMinutes += float64(d.Minutes)
+		sumQuestions += float64(d.Questions)
+	}
+	meanMinutes, meanQuestions := sumMinutes/n, sumQuestions/n
+
+	var covariance, varianceMinutes, varianceQuestions float64
+	for _, d := range days {
+		dm, dq := float64(d.Minutes)-meanMinutes, float64(d.Questions)-meanQuestions
+		covariance += dm * dq
+		varianceMinutes += dm * dm
+		varianceQuestions += dq * dq
+	}
+	if varianceMinutes == 0 || varianceQuestions == 0 {
+		return nil
+	}
+
+	r := math.Round(covariance/math.Sqrt(varianceMinutes*varianceQuestions)*1000) / 1000
+	return &r
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
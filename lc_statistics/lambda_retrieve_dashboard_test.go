@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/legacystats"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/studystats"
+)
+
+func TestHandlerMatchesTheStatisticsEndpoints(t *testing.T) {
+	ctx := context.Background()
+	today := time.Now().UTC().Format("2006-01-02")
+	s := func(value string) types.AttributeValue { return &types.AttributeValueMemberS{Value: value} }
+
+	fake := dynamotest.New(questionsTableName,
+		map[string]types.AttributeValue{"question_name": s("two-sum"), "question_solved_date": s(today), "difficulty": s("Easy"), "tags": &types.AttributeValueMemberSS{Value: []string{"DP"}}},
+		map[string]types.AttributeValue{"question_name": s("jump-game"), "question_solved_date": s(today), "difficulty": s("Medium"), "tags": &types.AttributeValueMemberSS{Value: []string{"Dynamic Programming"}}},
+	)
+	fake.Tables[studiesTableName] = []map[string]types.AttributeValue{
+		{"study_theme": s("k8s"), "study_date": s(today), "minutes_of_study": &types.AttributeValueMemberN{Value: "20"}},
+		{"study_theme": s("Go"), "study_date": s(today), "minutes_of_study": &types.AttributeValueMemberN{Value: "25"}},
+		{"study_theme": s("Kubernetes"), "study_date": s(today), "minutes_of_study": s("15")},
+	}
+	fake.Keys = map[string][]string{goalsTableName: {goal.KeyAttribute}}
+	dynamoClient = fake
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: questionsTableName}
+	if err := goal.Save(ctx, fake, goalsTableName, goal.Goal{QuestionsPerDay: 2, MinutesPerDay: 90, StartDate: today}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	tagAliases = map[string]string{"dp": "dynamic programming"}
+	themeAliases = map[string]string{"k8s": "kubernetes"}
+	t.Cleanup(func() { tagAliases, themeAliases = nil, nil })
+
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var dashboard struct {
+		Questions legacystats.Statistics `json:"questions"`
+		Studies   studystats.Statistics  `json:"studies"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &dashboard); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+
+	questions := dashboard.Questions
+	if want := map[string]int{"dynamic programming": 2}; !reflect.DeepEqual(questions.QuestionsCrackedPerTag, want) {
+		t.Errorf("questions per tag = %v, want %v", questions.QuestionsCrackedPerTag, want)
+	}
+	if want := map[string][]string{"dynamic programming": {"DP", "Dynamic Programming"}}; !reflect.DeepEqual(questions.MergedKeys, want) {
+		t.Errorf("question merged keys = %v, want %v", questions.MergedKeys, want)
+	}
+	if questions.GoalProgress == nil || !questions.GoalProgress.TodayMet {
+		t.Errorf("question goal progress = %+v, want today met", questions.GoalProgress)
+	}
+
+	studies := dashboard.Studies
+	if studies.MostStudiedTheme != "kubernetes" || studies.BusiestDay != today {
+		t.Errorf("most studied theme %q on %q, want kubernetes on %q", studies.MostStudiedTheme, studies.BusiestDay, today)
+	}
+	if want := map[string][]string{"kubernetes": {"Kubernetes", "k8s"}, "go": {"Go"}}; !reflect.DeepEqual(studies.MergedKeys, want) {
+		t.Errorf("study merged keys = %v, want %v", studies.MergedKeys, want)
+	}
+	if studies.GoalProgress == nil || studies.GoalProgress.Today != 60 || studies.GoalProgress.TodayMet {
+		t.Errorf("study goal progress = %+v, want 60 of 90 minutes today", studies.GoalProgress)
+	}
+}
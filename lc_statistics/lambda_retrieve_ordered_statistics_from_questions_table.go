@@ -1,185 +1,620 @@
 package main
 
 import (
-    "context"
-    "encoding/json"
-    "fmt"
-    "log"
-    "sort"
-    "time"
-
-    "github.com/aws/aws-lambda-go/events"
-    "github.com/aws/aws-lambda-go/lambda"
-    "github.com/aws/aws-sdk-go-v2/aws"
-    "github.com/aws/aws-sdk-go-v2/config"
-    "github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-    "github.com/aws/aws-sdk-go-v2/service/dynamodb"
-)
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-type Question struct {
-    Name       string   `dynamodbav:"question_name"`
-    Date       string   `dynamodbav:"question_solved_date"`
-    Difficulty string   `dynamodbav:"difficulty"`
-    Tags       []string `json:"tags"`
-}
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
+)
 
 type DayStatistic struct {
-    Date  string `json:"date"`
-    Count int    `json:"count"`
+	Date  string `json:"date"`
+	Count int    `json:"count"`
 }
 
 type Statistics struct {
-    QuestionsCrackedPerDay              []DayStatistic      `json:"questionsCrackedPerDay"`
-    QuestionsCrackedPerDifficulty       map[string]int      `json:"questionsCrackedPerDifficulty"`
-    QuestionsCrackedPerTag              map[string]int      `json:"questionsCrackedPerTag"`
-    TotalQuestionsCracked               int                 `json:"totalQuestionsCracked"`
-    IncrementalQuestionsCrackedPerDay   []DayStatistic      `json:"incrementalQuestionsCrackedPerDay"`
+	model.QuestionTotals
+	QuestionsCrackedPerDay            []DayStatistic `json:"questionsCrackedPerDay"`
+	IncrementalQuestionsCrackedPerDay []DayStatistic `json:"incrementalQuestionsCrackedPerDay"`
+	// IncrementalPerDifficultyPerDay holds one cumulative series per difficulty,
+	// each on the same date axis as IncrementalQuestionsCrackedPerDay
+	IncrementalPerDifficultyPerDay map[string][]DayStatistic `json:"incrementalPerDifficultyPerDay"`
+	// PerDifficultyPerDay holds the daily counts per difficulty on the same
+	// axis, zero on days without a solve of that difficulty
+	PerDifficultyPerDay      map[string][]DayStatistic `json:"perDifficultyPerDay"`
+	QuestionsCrackedPerMonth map[string]int            `json:"questionsCrackedPerMonth"`
+	DaysSinceLastSolvePerTag map[string]*int           `json:"daysSinceLastSolvePerTag"`
+	CurrentStreakDays        int                       `json:"currentStreakDays"`
+	CurrentStreakRange       *DateRange                `json:"currentStreakRange"`
+	LongestStreakDays        int                       `json:"longestStreakDays"`
+	LongestStreakRange       *DateRange                `json:"longestStreakRange"`
+	// TotalActiveDays counts the distinct days with a solve, InactiveDays the
+	// days without one between the first and last of them
+	TotalActiveDays          int              `json:"totalActiveDays"`
+	InactiveDays             int              `json:"inactiveDays"`
+	RollingAverage7d         []RollingAverage `json:"rollingAverage7d"`
+	WeightedScorePerDay      []DayStatistic   `json:"weightedScorePerDay"`
+	IncrementalWeightedScore []DayStatistic   `json:"incrementalWeightedScore"`
+	Weights                  Weights          `json:"weights"`
+	WeeklyGoalProgress       []WeekGoal       `json:"weeklyGoalProgress,omitempty"`
+	// RecentQuestions are the latest solves, newest first, with their URL and notes
+	RecentQuestions []model.Question `json:"recentQuestions"`
+	// MergedKeys lists the raw tag spellings folded into each canonical tag
+	MergedKeys map[string][]string `json:"mergedKeys"`
+}
+
+// recentQuestionsLimit caps RecentQuestions
+const recentQuestionsLimit = 10
+
+// Options are read from the query string
+type Options struct {
+	// Tags restricts the statistics to questions carrying any of them
+	Tags []string
+	// FillGaps adds zero-count days between the first and last solve, set by
+	// ?fillGaps=true or its shorter alias ?fill=true
+	FillGaps bool
+	// Granularity buckets the daily series by day, week or month
+	Granularity string
+	// WeekStart is Monday for ISO weeks, or Sunday
+	WeekStart time.Weekday
+	Weights   Weights
+	// WeeklyGoal is the target number of solves per week; zero disables it
+	WeeklyGoal int
+}
+
+// WeekGoal reports a week's solves against the weekly goal
+type WeekGoal struct {
+	Week  string `json:"week"`
+	Count int    `json:"count"`
+	Goal  int    `json:"goal"`
+	Met   bool   `json:"met"`
+}
+
+// Weights score a solve by difficulty. Unknown difficulties score zero.
+type Weights struct {
+	Easy   int `json:"easy"`
+	Medium int `json:"medium"`
+	Hard   int `json:"hard"`
 }
 
-var dynamoClient *dynamodb.Client
-const tableName = "veet_code_questions_table"
+var defaultWeights = Weights{Easy: 1, Medium: 2, Hard: 4}
+
+// For returns the weight of a difficulty, ignoring case
+func (w Weights) For(difficulty string) int {
+	switch strings.ToLower(difficulty) {
+	case "easy":
+		return w.Easy
+	case "medium":
+		return w.Medium
+	case "hard":
+		return w.Hard
+	}
+	return 0
+}
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// RollingAverage is the mean daily count over the window ending at Date
+type RollingAverage struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// DateRange spans a streak, both ends inclusive
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+// statsCache survives between invocations of a warm container
+var statsCache = cache.FromEnv()
+
+// clock is what the statistics count days up to, swapped in tests
+var clock = time.Now
+
+// tagAliases come from TAG_ALIASES
+var tagAliases map[string]string
 
 func init() {
-    cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
-    if err != nil {
-        log.Fatalf("Unable to load AWS SDK config: %v", err)
-    }
-    dynamoClient = dynamodb.NewFromConfig(cfg)
+	var err error
+	tagAliases, err = alias.Parse(os.Getenv(alias.EnvTagAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvTagAliases, err)
+	}
+
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-    questions, err := fetchAllQuestions(ctx)
-    if err != nil {
-        log.Printf("Failed to fetch questions: %v", err)
-        return events.APIGatewayProxyResponse{
-            StatusCode: 500,
-            Body:       "Internal Server Error",
-        }, nil
-    }
-
-    stats := generateStatistics(questions)
-
-    responseBody, err := json.Marshal(stats)
-    if err != nil {
-        log.Printf("Failed to marshal response: %v", err)
-        return events.APIGatewayProxyResponse{
-            StatusCode: 500,
-            Body:       "Internal Server Error",
-        }, nil
-    }
-
-    return events.APIGatewayProxyResponse{
-        StatusCode: 200,
-        Headers: map[string]string{
-            "Content-Type":                   "application/json",
-            "Access-Control-Allow-Origin":    "*",
-            "Access-Control-Allow-Methods":   "GET, OPTIONS",
-            "Access-Control-Allow-Headers":   "Content-Type, Authorization",
-        },
-        Body: string(responseBody),
-    }, nil
-}
-
-func fetchAllQuestions(ctx context.Context) ([]Question, error) {
-    var questions []Question
-    input := &dynamodb.ScanInput{
-        TableName: aws.String(tableName),
-    }
-
-    paginator := dynamodb.NewScanPaginator(dynamoClient, input)
-    for paginator.HasMorePages() {
-        page, err := paginator.NextPage(ctx)
-        if err != nil {
-            return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
-        }
-
-        var pageQuestions []struct {
-            Name       string `dynamodbav:"question_name"`
-            Date       string `dynamodbav:"question_solved_date"`
-            Difficulty string `dynamodbav:"difficulty"`
-            Tags       string `dynamodbav:"tags"`
-        }
-        err = attributevalue.UnmarshalListOfMaps(page.Items, &pageQuestions)
-        if err != nil {
-            return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
-        }
-
-        for _, q := range pageQuestions {
-            var tags []string
-            if err := json.Unmarshal([]byte(q.Tags), &tags); err != nil {
-                log.Printf("Failed to parse tags for question %s: %v", q.Name, err)
-                tags = []string{}
-            }
-
-            questions = append(questions, Question{
-                Name:       q.Name,
-                Date:       q.Date,
-                Difficulty: q.Difficulty,
-                Tags:       tags,
-            })
-        }
-    }
-
-    return questions, nil
-}
-
-func generateStatistics(questions []Question) Statistics {
-    stats := Statistics{
-        QuestionsCrackedPerDifficulty: make(map[string]int),
-        QuestionsCrackedPerTag:        make(map[string]int),
-        TotalQuestionsCracked:         0,
-    }
-
-    dailyStats := make(map[string]int)
-
-    for _, q := range questions {
-        dailyStats[q.Date]++
-        stats.QuestionsCrackedPerDifficulty[q.Difficulty]++
-        for _, tag := range q.Tags {
-            stats.QuestionsCrackedPerTag[tag]++
-        }
-        stats.TotalQuestionsCracked++
-    }
-
-    sortedDates := getSortedDates(dailyStats)
-
-    // Populate ordered statistics
-    var orderedQuestions []DayStatistic
-    var incrementalQuestions []DayStatistic
-    runningTotal := 0
-    for _, date := range sortedDates {
-        count := dailyStats[date]
-        orderedQuestions = append(orderedQuestions, DayStatistic{Date: date, Count: count})
-        runningTotal += count
-        incrementalQuestions = append(incrementalQuestions, DayStatistic{Date: date, Count: runningTotal})
-    }
-
-    stats.QuestionsCrackedPerDay = orderedQuestions
-    stats.IncrementalQuestionsCrackedPerDay = incrementalQuestions
-
-    return stats
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	cacheKey := cache.Key(ctx, event)
+	if cached, ok := statsCache.Get(cacheKey, event); ok {
+		return awsutil.Compress(event, awsutil.WithETag(event, cached)), nil
+	}
+
+	dateRange, err := calendar.DateRangeFromQuery(event.QueryStringParameters)
+	if err != nil {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+	}
+
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	questions = model.FilterQuestions(questions, dateRange)
+
+	opts, err := optionsFromRequest(event)
+	if err != nil {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+	}
+
+	start := time.Now()
+	_, segment := tracing.Start(ctx, "generateStatistics")
+	stats := generateStatistics(questions, clock(), opts)
+	segment.End(nil)
+	metrics.Emit(ctx, metrics.Duration(metrics.StatsGenerationMs, time.Since(start)))
+
+	response := statsCache.Put(cacheKey, awsutil.JSONResponse(200, stats))
+	return awsutil.Compress(event, awsutil.WithETag(event, response)), nil
+}
+
+func generateStatistics(questions []model.Question, now time.Time, opts Options) Statistics {
+	stats := Statistics{
+		QuestionTotals:           model.NewQuestionTotals(),
+		QuestionsCrackedPerMonth: make(map[string]int),
+		Weights:                  opts.Weights,
+	}
+
+	dailyStats := make(map[string]int)
+	dailyScores := make(map[string]int)
+	dailyStatsPerDifficulty := make(map[string]map[string]int)
+	lastSolvePerTag := make(map[string]time.Time)
+	solvedDays := make(map[time.Time]bool)
+	matched := []model.Question{}
+
+	// Tags are compared and counted by canonical name; the requested ones get
+	// their own canonicalizer so they don't show up in MergedKeys
+	tags := alias.NewCanonicalizer(tagAliases)
+	wanted := alias.NewCanonicalizer(tagAliases).CanonicalAll(opts.Tags)
+	for _, q := range questions {
+		q.Tags = tags.CanonicalAll(q.Tags)
+		if !hasAnyTag(q, wanted) {
+			continue
+		}
+		matched = append(matched, q)
+
+		dailyStats[q.Date]++
+		dailyScores[q.Date] += opts.Weights.For(q.Difficulty)
+		stats.Add(q)
+		if !model.IsUnknownDifficulty(q.Difficulty) {
+			if _, ok := dailyStatsPerDifficulty[q.Difficulty]; !ok {
+				dailyStatsPerDifficulty[q.Difficulty] = make(map[string]int)
+			}
+			dailyStatsPerDifficulty[q.Difficulty][q.Date]++
+		}
+
+		date, err := calendar.ParseDate(q.Date)
+		if err != nil {
+			slog.Warn("Skipping monthly count for question", "question", q.Name, "error", err)
+			continue
+		}
+		stats.QuestionsCrackedPerMonth[date.Format("01/2006")]++
+		solvedDays[date] = true
+
+		for _, tag := range q.Tags {
+			if date.After(lastSolvePerTag[tag]) {
+				lastSolvePerTag[tag] = date
+			}
+		}
+	}
+
+	stats.DaysSinceLastSolvePerTag = daysSinceLastSolvePerTag(stats.QuestionsCrackedPerTag, lastSolvePerTag, now)
+	stats.setStreaks(solvedDays, now)
+	stats.RecentQuestions = recentQuestions(matched, recentQuestionsLimit)
+	stats.MergedKeys = tags.MergedKeys()
+	stats.RollingAverage7d = rollingAverage(dailyStats, 7)
+	if opts.WeeklyGoal > 0 {
+		stats.WeeklyGoalProgress = weeklyGoalProgress(dailyStats, opts.WeeklyGoal, opts.WeekStart)
+	}
+
+	sortedDates := getSortedDates(dailyStats)
+	if opts.FillGaps {
+		sortedDates = fillDateGaps(sortedDates)
+	}
+
+	// Populate ordered statistics
+	orderedQuestions := []DayStatistic{}
+	incrementalQuestions := []DayStatistic{}
+	perDifficulty := make(map[string][]DayStatistic)
+	incrementalPerDifficulty := make(map[string][]DayStatistic)
+	scores := []DayStatistic{}
+	incrementalScores := []DayStatistic{}
+	runningTotal := 0
+	runningScore := 0
+	runningTotalPerDifficulty := make(map[string]int)
+	for _, date := range sortedDates {
+		count := dailyStats[date]
+		orderedQuestions = append(orderedQuestions, DayStatistic{Date: date, Count: count})
+		runningTotal += count
+		incrementalQuestions = append(incrementalQuestions, DayStatistic{Date: date, Count: runningTotal})
+
+		score := dailyScores[date]
+		scores = append(scores, DayStatistic{Date: date, Count: score})
+		runningScore += score
+		incrementalScores = append(incrementalScores, DayStatistic{Date: date, Count: runningScore})
+
+		for difficulty, perDay := range dailyStatsPerDifficulty {
+			perDifficulty[difficulty] = append(perDifficulty[difficulty], DayStatistic{Date: date, Count: perDay[date]})
+			runningTotalPerDifficulty[difficulty] += perDay[date]
+			incrementalPerDifficulty[difficulty] = append(incrementalPerDifficulty[difficulty], DayStatistic{Date: date, Count: runningTotalPerDifficulty[difficulty]})
+		}
+	}
+
+	if opts.Granularity != GranularityDay {
+		label := bucketLabel(opts.Granularity, opts.WeekStart)
+		orderedQuestions = sumBuckets(orderedQuestions, label)
+		incrementalQuestions = lastOfBuckets(incrementalQuestions, label)
+		scores = sumBuckets(scores, label)
+		incrementalScores = lastOfBuckets(incrementalScores, label)
+		for difficulty, series := range perDifficulty {
+			perDifficulty[difficulty] = sumBuckets(series, label)
+		}
+		for difficulty, series := range incrementalPerDifficulty {
+			incrementalPerDifficulty[difficulty] = lastOfBuckets(series, label)
+		}
+	}
+
+	stats.QuestionsCrackedPerDay = orderedQuestions
+	stats.IncrementalQuestionsCrackedPerDay = incrementalQuestions
+	stats.PerDifficultyPerDay = perDifficulty
+	stats.IncrementalPerDifficultyPerDay = incrementalPerDifficulty
+	stats.WeightedScorePerDay = scores
+	stats.IncrementalWeightedScore = incrementalScores
+
+	return stats
+}
+
+func optionsFromRequest(event events.APIGatewayProxyRequest) (Options, error) {
+	opts := Options{
+		Tags:        requestedTags(event),
+		FillGaps:    event.QueryStringParameters["fillGaps"] == "true" || event.QueryStringParameters["fill"] == "true",
+		Granularity: GranularityDay,
+		WeekStart:   time.Monday,
+		Weights:     defaultWeights,
+	}
+
+	if value := event.QueryStringParameters["weeklyGoal"]; value != "" {
+		goal, err := strconv.Atoi(value)
+		if err != nil || goal < 1 {
+			return opts, fmt.Errorf("invalid weeklyGoal %q: use a positive whole number", value)
+		}
+		opts.WeeklyGoal = goal
+	}
+
+	for name, weight := range map[string]*int{
+		"weightEasy":   &opts.Weights.Easy,
+		"weightMedium": &opts.Weights.Medium,
+		"weightHard":   &opts.Weights.Hard,
+	} {
+		value := event.QueryStringParameters[name]
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return opts, fmt.Errorf("invalid %s %q: use a non-negative whole number", name, value)
+		}
+		*weight = parsed
+	}
+
+	switch granularity := event.QueryStringParameters["granularity"]; granularity {
+	case "":
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		opts.Granularity = granularity
+	default:
+		return opts, fmt.Errorf("invalid granularity %q: use day, week or month", granularity)
+	}
+
+	switch weekStart := event.QueryStringParameters["weekStart"]; weekStart {
+	case "", "monday":
+	case "sunday":
+		opts.WeekStart = time.Sunday
+	default:
+		return opts, fmt.Errorf("invalid weekStart %q: use monday or sunday", weekStart)
+	}
+
+	return opts, nil
+}
+
+// requestedTags reads ?tag=a&tag=b, falling back to the single-value map for
+// callers that do not send multi-value parameters
+func requestedTags(event events.APIGatewayProxyRequest) []string {
+	if tags := event.MultiValueQueryStringParameters["tag"]; len(tags) > 0 {
+		return tags
+	}
+	if tag := event.QueryStringParameters["tag"]; tag != "" {
+		return []string{tag}
+	}
+	return nil
+}
+
+func hasAnyTag(q model.Question, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, want := range tags {
+		for _, tag := range q.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// daysSinceLastSolvePerTag reports null for tags without a parseable solve date
+func daysSinceLastSolvePerTag(tags map[string]int, lastSolvePerTag map[string]time.Time, now time.Time) map[string]*int {
+	today := calendar.Today(now)
+	daysSince := make(map[string]*int)
+	for tag := range tags {
+		last, ok := lastSolvePerTag[tag]
+		if !ok {
+			daysSince[tag] = nil
+			continue
+		}
+		days := calendar.DaysBetween(last, today)
+		daysSince[tag] = &days
+	}
+	return daysSince
+}
+
+// setStreaks counts runs of consecutive calendar days with a solve, along
+// with the active and inactive days. A run ending yesterday is still current,
+// since today may not be over yet.
+func (stats *Statistics) setStreaks(solvedDays map[time.Time]bool, now time.Time) {
+	days := make([]time.Time, 0, len(solvedDays))
+	for day := range solvedDays {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var runStart time.Time
+	for i, day := range days {
+		if i == 0 || calendar.DaysBetween(days[i-1], day) != 1 {
+			runStart = day
+		}
+		length := calendar.DaysBetween(runStart, day) + 1
+		// >= so that the most recent of equally long streaks wins
+		if length >= stats.LongestStreakDays {
+			stats.LongestStreakDays = length
+			stats.LongestStreakRange = newDateRange(runStart, day)
+		}
+	}
+
+	if len(days) == 0 {
+		return
+	}
+	last := days[len(days)-1]
+	stats.TotalActiveDays = len(days)
+	stats.InactiveDays = calendar.DaysBetween(days[0], last) + 1 - len(days)
+	if gap := calendar.DaysBetween(last, calendar.Today(now)); gap == 0 || gap == 1 {
+		stats.CurrentStreakDays = calendar.DaysBetween(runStart, last) + 1
+		stats.CurrentStreakRange = newDateRange(runStart, last)
+	}
+}
+
+func newDateRange(start, end time.Time) *DateRange {
+	return &DateRange{Start: start.Format(model.DateLayout), End: end.Format(model.DateLayout)}
 }
 
 func getSortedDates(dateMap map[string]int) []string {
-    var dates []string
-    for date := range dateMap {
-        dates = append(dates, date)
-    }
+	var dates []string
+	for date := range dateMap {
+		dates = append(dates, date)
+	}
+
+	sort.SliceStable(dates, func(i, j int) bool {
+		date1, err1 := calendar.ParseDate(dates[i])
+		date2, err2 := calendar.ParseDate(dates[j])
+		if err1 != nil || err2 != nil {
+			slog.Warn("Error parsing dates", "error", errors.Join(err1, err2))
+			return dates[i] < dates[j]
+		}
+		return date1.Before(date2)
+	})
+
+	return dates
+}
+
+// rollingAverage averages every calendar day from the first to the last solve
+// with the window-1 days before it, counting days without solves as zero.
+// Until a full window of history exists it averages over the days available.
+func rollingAverage(dailyStats map[string]int, window int) []RollingAverage {
+	counts := make(map[time.Time]int)
+	var first, last time.Time
+	for value, count := range dailyStats {
+		date, err := calendar.ParseDate(value)
+		if err != nil {
+			continue
+		}
+		counts[date] += count
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
 
-    sort.SliceStable(dates, func(i, j int) bool {
-        layout := "02/01/2006" // Adjust the date format as per your data
-        date1, err1 := time.Parse(layout, dates[i])
-        date2, err2 := time.Parse(layout, dates[j])
-        if err1 != nil || err2 != nil {
-            log.Printf("Error parsing dates: %v, %v", err1, err2)
-            return dates[i] < dates[j]
-        }
-        return date1.Before(date2)
-    })
+	averages := []RollingAverage{}
+	if first.IsZero() {
+		return averages
+	}
+
+	var daily []int
+	sum := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		daily = append(daily, counts[day])
+		sum += counts[day]
+		if len(daily) > window {
+			sum -= daily[len(daily)-window-1]
+		}
+
+		days := min(len(daily), window)
+		average := math.Round(float64(sum)/float64(days)*100) / 100
+		averages = append(averages, RollingAverage{Date: day.Format(model.DateLayout), Value: average})
+	}
+	return averages
+}
+
+// recentQuestions returns up to limit questions, newest solve date first and,
+// within a day, latest created first. Questions with unparseable dates go last.
+func recentQuestions(questions []model.Question, limit int) []model.Question {
+	recent := slices.Clone(questions)
+	sort.SliceStable(recent, func(i, j int) bool {
+		dateI, errI := calendar.ParseDate(recent[i].Date)
+		dateJ, errJ := calendar.ParseDate(recent[j].Date)
+		if errI != nil || errJ != nil {
+			return errJ != nil && errI == nil
+		}
+		if !dateI.Equal(dateJ) {
+			return dateI.After(dateJ)
+		}
+		return recent[i].CreatedAt > recent[j].CreatedAt
+	})
+	return recent[:min(limit, len(recent))]
+}
+
+// fillDateGaps inserts every missing calendar day between consecutive sorted
+// dates. Dates that do not parse are kept where they are.
+func fillDateGaps(sortedDates []string) []string {
+	var filled []string
+	var previous time.Time
+	for _, value := range sortedDates {
+		date, err := calendar.ParseDate(value)
+		if err != nil {
+			filled = append(filled, value)
+			continue
+		}
+
+		if !previous.IsZero() {
+			for day := previous.AddDate(0, 0, 1); day.Before(date); day = day.AddDate(0, 0, 1) {
+				filled = append(filled, day.Format(model.DateLayout))
+			}
+		}
+		filled = append(filled, value)
+		previous = date
+	}
+	return filled
+}
+
+// bucketLabel names the week (2024-W14) or month (2024-03) of a date. Weeks
+// follow ISO numbering; Sunday-started weeks take the number of the ISO week
+// that begins the next day.
+func bucketLabel(granularity string, weekStart time.Weekday) func(string) string {
+	return func(value string) string {
+		date, err := calendar.ParseDate(value)
+		if err != nil {
+			return value
+		}
+		if granularity == GranularityMonth {
+			return date.Format("2006-01")
+		}
+		if weekStart == time.Sunday {
+			date = date.AddDate(0, 0, 1)
+		}
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+}
+
+// weeklyGoalProgress covers every week from the first solve to the last, so
+// weeks without solves show up as missed
+func weeklyGoalProgress(dailyStats map[string]int, goal int, weekStart time.Weekday) []WeekGoal {
+	var days []DayStatistic
+	for _, date := range fillDateGaps(getSortedDates(dailyStats)) {
+		days = append(days, DayStatistic{Date: date, Count: dailyStats[date]})
+	}
+
+	progress := []WeekGoal{}
+	for _, week := range sumBuckets(days, bucketLabel(GranularityWeek, weekStart)) {
+		progress = append(progress, WeekGoal{Week: week.Date, Count: week.Count, Goal: goal, Met: week.Count >= goal})
+	}
+	return progress
+}
+
+// sumBuckets adds up the counts of consecutive days sharing a label
+func sumBuckets(days []DayStatistic, label func(string) string) []DayStatistic {
+	buckets := []DayStatistic{}
+	for _, day := range days {
+		bucket := label(day.Date)
+		if last := len(buckets) - 1; last >= 0 && buckets[last].Date == bucket {
+			buckets[last].Count += day.Count
+			continue
+		}
+		buckets = append(buckets, DayStatistic{Date: bucket, Count: day.Count})
+	}
+	return buckets
+}
 
-    return dates
+// lastOfBuckets keeps the running total at the end of each bucket, so the
+// series stays monotonic
+func lastOfBuckets(days []DayStatistic, label func(string) string) []DayStatistic {
+	buckets := []DayStatistic{}
+	for _, day := range days {
+		bucket := label(day.Date)
+		if last := len(buckets) - 1; last >= 0 && buckets[last].Date == bucket {
+			buckets[last].Count = day.Count
+			continue
+		}
+		buckets = append(buckets, DayStatistic{Date: bucket, Count: day.Count})
+	}
+	return buckets
 }
 
 func main() {
-    lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+)
+
+// noonInSaoPaulo is 2024-03-10 in the default timezone
+var noonInSaoPaulo = time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+func days(values ...string) map[time.Time]bool {
+	solved := make(map[time.Time]bool)
+	for _, value := range values {
+		date, err := calendar.ParseDate(value)
+		if err != nil {
+			panic(err)
+		}
+		solved[date] = true
+	}
+	return solved
+}
+
+func TestSetStreaks(t *testing.T) {
+	tests := []struct {
+		name          string
+		solved        map[time.Time]bool
+		wantCurrent   int
+		wantCurRange  *DateRange
+		wantLongest   int
+		wantLongRange *DateRange
+		wantActive    int
+		wantInactive  int
+	}{
+		{"no solves", days(), 0, nil, 0, nil, 0, 0},
+		{"solved today only", days("2024-03-10"), 1, &DateRange{"2024-03-10", "2024-03-10"}, 1, &DateRange{"2024-03-10", "2024-03-10"}, 1, 0},
+		{"run ending yesterday is current", days("2024-03-07", "2024-03-08", "2024-03-09"),
+			3, &DateRange{"2024-03-07", "2024-03-09"}, 3, &DateRange{"2024-03-07", "2024-03-09"}, 3, 0},
+		{"run ending two days ago is over", days("2024-03-07", "2024-03-08"),
+			0, nil, 2, &DateRange{"2024-03-07", "2024-03-08"}, 2, 0},
+		{"longest before a gap", days("2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04", "2024-03-09", "2024-03-10"),
+			2, &DateRange{"2024-03-09", "2024-03-10"}, 4, &DateRange{"2024-03-01", "2024-03-04"}, 6, 4},
+		{"most recent of equal streaks wins", days("2024-03-01", "2024-03-02", "2024-03-05", "2024-03-06"),
+			0, nil, 2, &DateRange{"2024-03-05", "2024-03-06"}, 4, 2},
+		{"across a month end", days("2024-02-28", "2024-02-29", "2024-03-01"),
+			0, nil, 3, &DateRange{"2024-02-28", "2024-03-01"}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats Statistics
+			stats.setStreaks(tt.solved, noonInSaoPaulo)
+
+			if stats.CurrentStreakDays != tt.wantCurrent || !reflect.DeepEqual(stats.CurrentStreakRange, tt.wantCurRange) {
+				t.Errorf("current = %d %+v, want %d %+v", stats.CurrentStreakDays, stats.CurrentStreakRange, tt.wantCurrent, tt.wantCurRange)
+			}
+			if stats.LongestStreakDays != tt.wantLongest || !reflect.DeepEqual(stats.LongestStreakRange, tt.wantLongRange) {
+				t.Errorf("longest = %d %+v, want %d %+v", stats.LongestStreakDays, stats.LongestStreakRange, tt.wantLongest, tt.wantLongRange)
+			}
+			if stats.TotalActiveDays != tt.wantActive || stats.InactiveDays != tt.wantInactive {
+				t.Errorf("active %d, inactive %d, want %d and %d", stats.TotalActiveDays, stats.InactiveDays, tt.wantActive, tt.wantInactive)
+			}
+		})
+	}
+}
+
+func TestRollingAverage(t *testing.T) {
+	tests := []struct {
+		name   string
+		daily  map[string]int
+		window int
+		want   []RollingAverage
+	}{
+		{"empty", map[string]int{}, 7, []RollingAverage{}},
+		{"only unparseable dates", map[string]int{"someday": 3}, 7, []RollingAverage{}},
+		{"partial window averages the days available", map[string]int{"2024-03-01": 2, "2024-03-02": 4}, 7,
+			[]RollingAverage{{"2024-03-01", 2}, {"2024-03-02", 3}}},
+		{"days without solves count as zero", map[string]int{"2024-03-01": 3, "2024-03-03": 3}, 2,
+			[]RollingAverage{{"2024-03-01", 3}, {"2024-03-02", 1.5}, {"2024-03-03", 1.5}}},
+		{"window slides", map[string]int{"2024-03-01": 1, "2024-03-02": 2, "2024-03-03": 3, "2024-03-04": 6}, 3,
+			[]RollingAverage{{"2024-03-01", 1}, {"2024-03-02", 1.5}, {"2024-03-03", 2}, {"2024-03-04", 3.67}}},
+		{"legacy and canonical dates of one day add up", map[string]int{"2024-03-01": 1, "01/03/2024": 2}, 7,
+			[]RollingAverage{{"2024-03-01", 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollingAverage(tt.daily, tt.window); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rollingAverage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillDateGaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []string
+		want   []string
+	}{
+		{"empty", nil, nil},
+		{"no gaps", []string{"2024-03-01", "2024-03-02"}, []string{"2024-03-01", "2024-03-02"}},
+		{"fills missing days", []string{"2024-03-01", "2024-03-04"}, []string{"2024-03-01", "2024-03-02", "2024-03-03", "2024-03-04"}},
+		{"across a leap day", []string{"2024-02-28", "2024-03-01"}, []string{"2024-02-28", "2024-02-29", "2024-03-01"}},
+		{"unparseable dates kept in place", []string{"2024-03-01", "someday", "2024-03-03"}, []string{"2024-03-01", "someday", "2024-03-02", "2024-03-03"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fillDateGaps(tt.sorted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillDateGaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSortedDates(t *testing.T) {
+	daily := map[string]int{"2024-03-10": 1, "2024-01-05": 1, "2023-12-31": 1, "2024-02-29": 1}
+	want := []string{"2023-12-31", "2024-01-05", "2024-02-29", "2024-03-10"}
+	if got := getSortedDates(daily); !reflect.DeepEqual(got, want) {
+		t.Errorf("getSortedDates = %v, want %v", got, want)
+	}
+}
+
+func TestBucketLabel(t *testing.T) {
+	tests := []struct {
+		granularity string
+		weekStart   time.Weekday
+		date        string
+		want        string
+	}{
+		{GranularityMonth, time.Monday, "2024-03-31", "2024-03"},
+		{GranularityWeek, time.Monday, "2024-04-01", "2024-W14"},
+		{GranularityWeek, time.Monday, "2024-03-31", "2024-W13"},
+		// Sunday-started weeks take the number of the ISO week starting next day
+		{GranularityWeek, time.Sunday, "2024-03-31", "2024-W14"},
+		{GranularityWeek, time.Sunday, "2024-03-30", "2024-W13"},
+		{GranularityWeek, time.Monday, "2024-12-30", "2025-W01"},
+		{GranularityWeek, time.Monday, "someday", "someday"},
+	}
+	for _, tt := range tests {
+		if got := bucketLabel(tt.granularity, tt.weekStart)(tt.date); got != tt.want {
+			t.Errorf("bucketLabel(%s, %s)(%s) = %s, want %s", tt.granularity, tt.weekStart, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	days := []DayStatistic{{"2024-02-28", 1}, {"2024-02-29", 2}, {"2024-03-01", 3}, {"2024-03-02", 4}}
+	month := bucketLabel(GranularityMonth, time.Monday)
+
+	if got, want := sumBuckets(days, month), []DayStatistic{{"2024-02", 3}, {"2024-03", 7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sumBuckets = %v, want %v", got, want)
+	}
+	if got, want := lastOfBuckets(days, month), []DayStatistic{{"2024-02", 2}, {"2024-03", 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lastOfBuckets = %v, want %v", got, want)
+	}
+}
+
+func TestWeeklyGoalProgress(t *testing.T) {
+	// Mondays 2024-03-04, 2024-03-11 and 2024-03-18; the middle week has no solves
+	daily := map[string]int{"2024-03-04": 2, "2024-03-06": 1, "2024-03-20": 1}
+	want := []WeekGoal{
+		{Week: "2024-W10", Count: 3, Goal: 3, Met: true},
+		{Week: "2024-W11", Count: 0, Goal: 3, Met: false},
+		{Week: "2024-W12", Count: 1, Goal: 3, Met: false},
+	}
+	if got := weeklyGoalProgress(daily, 3, time.Monday); !reflect.DeepEqual(got, want) {
+		t.Errorf("weeklyGoalProgress = %+v, want %+v", got, want)
+	}
+}
+
+func TestWeightsFor(t *testing.T) {
+	tests := []struct {
+		difficulty string
+		want       int
+	}{
+		{"Easy", 1},
+		{"MEDIUM", 2},
+		{"hard", 4},
+		{"unknown", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := defaultWeights.For(tt.difficulty); got != tt.want {
+			t.Errorf("For(%q) = %d, want %d", tt.difficulty, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateStatisticsFillsGaps(t *testing.T) {
+	questions := []model.Question{
+		{Name: "two-sum", Date: "2024-03-08", Difficulty: "Easy"},
+		{Name: "clone-graph", Date: "2024-03-10", Difficulty: "Medium"},
+		{Name: "word-ladder", Date: "2024-03-10", Difficulty: "Hard"},
+	}
+	stats := generateStatistics(questions, noonInSaoPaulo, Options{FillGaps: true, Granularity: GranularityDay, WeekStart: time.Monday, Weights: defaultWeights})
+
+	want := []DayStatistic{{"2024-03-08", 1}, {"2024-03-09", 1}, {"2024-03-10", 3}}
+	if got := stats.IncrementalQuestionsCrackedPerDay; !reflect.DeepEqual(got, want) {
+		t.Errorf("incremental per day = %v, want %v", got, want)
+	}
+	if stats.CurrentStreakDays != 1 || stats.LongestStreakDays != 1 || stats.InactiveDays != 1 {
+		t.Errorf("streaks %d/%d, inactive %d, want 1/1 and 1", stats.CurrentStreakDays, stats.LongestStreakDays, stats.InactiveDays)
+	}
+	if last := stats.IncrementalWeightedScore[len(stats.IncrementalWeightedScore)-1]; last.Count != 7 {
+		t.Errorf("weighted score = %d, want 1+2+4", last.Count)
+	}
+}
+
+func questionItem(name, date string, tags ...string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: "Medium"},
+		"tags":                 &types.AttributeValueMemberSS{Value: tags},
+	}
+}
+
+// The default timezone is UTC-3, so at 02:30 UTC it is still the day before
+func TestHandlerDaysSinceLastSolveAcrossMidnight(t *testing.T) {
+	if name := calendar.Location().String(); name != calendar.DefaultTimezone {
+		t.Skipf("TIMEZONE is %s, want the default %s", name, calendar.DefaultTimezone)
+	}
+	fake := dynamotest.New(tableName,
+		questionItem("clone-graph", "2024-03-09", "Graph"),
+		questionItem("two-sum", "2024-02-08", "Array"),
+		questionItem("word-ladder", "08/02/2024", "Graph", "BFS"),
+		questionItem("jump-game", "someday", "Greedy"),
+	)
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+	t.Cleanup(func() { clock = time.Now })
+
+	intPtr := func(n int) *int { return &n }
+	tests := []struct {
+		name string
+		now  time.Time
+		want map[string]*int
+	}{
+		{"before local midnight", time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC),
+			map[string]*int{"graph": intPtr(0), "array": intPtr(30), "bfs": intPtr(30), "greedy": nil}},
+		{"after local midnight", time.Date(2024, 3, 10, 3, 30, 0, 0, time.UTC),
+			map[string]*int{"graph": intPtr(1), "array": intPtr(31), "bfs": intPtr(31), "greedy": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock = func() time.Time { return tt.now }
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			var stats Statistics
+			if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if !reflect.DeepEqual(stats.DaysSinceLastSolvePerTag, tt.want) {
+				t.Errorf("days since last solve = %v, want %v", format(stats.DaysSinceLastSolvePerTag), format(tt.want))
+			}
+		})
+	}
+}
+
+func format(days map[string]*int) map[string]any {
+	formatted := make(map[string]any)
+	for tag, n := range days {
+		if n == nil {
+			formatted[tag] = nil
+		} else {
+			formatted[tag] = *n
+		}
+	}
+	return formatted
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler serves GET /questions/{name}, also accepting ?name=, with a single
+// GetItem on the exact, case-sensitive name
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	name := event.PathParameters["name"]
+	if name == "" {
+		name = event.QueryStringParameters["name"]
+	}
+	var fields validation.Fields
+	fields.Require("name", name)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	question, found, err := questionStore.Get(ctx, name)
+	if err != nil {
+		slog.Error("Failed to get question", "name", name, "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+	if !found {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "question not found"), nil
+	}
+
+	return awsutil.JSONResponse(200, question), nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// DueQuestion is a question whose next review falls within the window
+type DueQuestion struct {
+	Name       string   `json:"name"`
+	Difficulty string   `json:"difficulty"`
+	Tags       []string `json:"tags"`
+	URL        string   `json:"url,omitempty"`
+	SolvedDate string   `json:"solvedDate"`
+	DueDate    string   `json:"dueDate"`
+	// DaysOverdue is negative for reviews that are still upcoming
+	DaysOverdue int `json:"daysOverdue"`
+	// Review is the number of the review that is due, starting at 1
+	Review int `json:"review"`
+}
+
+// EnvIntervals overrides the review schedule with comma-separated day
+// counts, each measured from the first solve
+const EnvIntervals = "REVIEW_INTERVALS_DAYS"
+
+var defaultIntervals = []int{3, 7, 21, 60}
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+var intervals []int
+
+func init() {
+	var err error
+	intervals, err = parseIntervals(os.Getenv(EnvIntervals))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", EnvIntervals, err)
+	}
+
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler returns the questions with a review due today or overdue, most
+// overdue first. ?days=7 also includes the reviews due within the next week,
+// and ?favoritesOnly=true leaves out the questions not marked favorite.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	days := 0
+	if value := event.QueryStringParameters["days"]; value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("invalid days %q: use a non-negative whole number", value)), nil
+		}
+	}
+
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	if event.QueryStringParameters["favoritesOnly"] == "true" {
+		questions = favorites(questions)
+	}
+
+	return awsutil.JSONResponse(200, dueQuestions(questions, intervals, calendar.Today(time.Now()), days)), nil
+}
+
+func favorites(questions []model.Question) []model.Question {
+	favorites := []model.Question{}
+	for _, q := range questions {
+		if q.Favorite {
+			favorites = append(favorites, q)
+		}
+	}
+	return favorites
+}
+
+// dueQuestions lists the questions whose next review is due by today plus
+// days. Questions with an unparseable solved date are skipped.
+func dueQuestions(questions []model.Question, intervals []int, today time.Time, days int) []DueQuestion {
+	due := []DueQuestion{}
+	for _, q := range questions {
+		solved, err := calendar.ParseDate(q.Date)
+		if err != nil {
+			slog.Warn("Skipping question with invalid date", "question", q.Name, "error", err)
+			continue
+		}
+
+		review, ok := nextReview(solved, q.ReviewDates, intervals)
+		if !ok {
+			continue
+		}
+		dueDate := solved.AddDate(0, 0, intervals[review])
+		overdue := calendar.DaysBetween(dueDate, today)
+		if overdue < -days {
+			continue
+		}
+
+		due = append(due, DueQuestion{
+			Name:        q.Name,
+			Difficulty:  q.Difficulty,
+			Tags:        q.Tags,
+			URL:         q.URL,
+			SolvedDate:  q.Date,
+			DueDate:     dueDate.Format(model.DateLayout),
+			DaysOverdue: overdue,
+			Review:      review + 1,
+		})
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].DaysOverdue != due[j].DaysOverdue {
+			return due[i].DaysOverdue > due[j].DaysOverdue
+		}
+		return due[i].Name < due[j].Name
+	})
+	return due
+}
+
+// nextReview walks the schedule in date order: a review done on or after the
+// due date of the current stage completes it, earlier ones don't count. It
+// returns the index of the first stage left open, or false once all are done.
+func nextReview(solved time.Time, reviewDates []string, intervals []int) (int, bool) {
+	var reviews []time.Time
+	for _, value := range reviewDates {
+		if date, err := calendar.ParseDate(value); err == nil {
+			reviews = append(reviews, date)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].Before(reviews[j]) })
+
+	stage := 0
+	for _, review := range reviews {
+		if stage < len(intervals) && !review.Before(solved.AddDate(0, 0, intervals[stage])) {
+			stage++
+		}
+	}
+	return stage, stage < len(intervals)
+}
+
+// parseIntervals reads a list like "3,7,21,60", which must be increasing
+func parseIntervals(value string) ([]int, error) {
+	if value == "" {
+		return defaultIntervals, nil
+	}
+
+	var parsed []int
+	for _, part := range strings.Split(value, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("%q is not a positive whole number of days", part)
+		}
+		if len(parsed) > 0 && days <= parsed[len(parsed)-1] {
+			return nil, fmt.Errorf("intervals must increase, %d follows %d", days, parsed[len(parsed)-1])
+		}
+		parsed = append(parsed, days)
+	}
+	return parsed, nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/store"
+)
+
+func TestHandlerFavoritesOnly(t *testing.T) {
+	// Solved long ago and never reviewed, so every one of them is due
+	question := func(name string, favorite types.AttributeValue) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"question_name":        &types.AttributeValueMemberS{Value: name},
+			"question_solved_date": &types.AttributeValueMemberS{Value: "2024-01-01"},
+			"difficulty":           &types.AttributeValueMemberS{Value: "Easy"},
+		}
+		if favorite != nil {
+			item["favorite"] = favorite
+		}
+		return item
+	}
+	fake := dynamotest.New(tableName,
+		question("two-sum", &types.AttributeValueMemberBOOL{Value: true}),
+		question("valid-parentheses", &types.AttributeValueMemberBOOL{Value: false}),
+		// Written before the flag existed
+		question("climbing-stairs", nil),
+	)
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   []string
+	}{
+		{"every question", nil, []string{"climbing-stairs", "two-sum", "valid-parentheses"}},
+		{"favorites only", map[string]string{"favoritesOnly": "true"}, []string{"two-sum"}},
+		{"false keeps every question", map[string]string{"favoritesOnly": "false"}, []string{"climbing-stairs", "two-sum", "valid-parentheses"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: tt.params})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			var due []DueQuestion
+			if err := json.Unmarshal([]byte(response.Body), &due); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+
+			names := []string{}
+			for _, q := range due {
+				names = append(names, q.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -1,115 +1,182 @@
 package main
 
 import (
-    "context"
-    "encoding/json"
-    "fmt"
-    "log"
-    "github.com/aws/aws-lambda-go/events"
-    "github.com/aws/aws-lambda-go/lambda"
-    "github.com/aws/aws-sdk-go-v2/aws"
-    "github.com/aws/aws-sdk-go-v2/config"
-    "github.com/aws/aws-sdk-go-v2/service/dynamodb"
-    "github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
 )
 
-type Question struct {
-    Name       string   `dynamodbav:"question_name"`
-    Date       string   `dynamodbav:"question_solved_date"`
-    Difficulty string   `dynamodbav:"difficulty"`
-    Tags       []string   `json:"tags"`
-}
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
 
-var dynamoClient *dynamodb.Client
-const tableName = "veet_code_questions_table"
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-	dynamoClient = dynamodb.NewFromConfig(cfg)
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	favorite := event.QueryStringParameters["favorite"] == "true"
+	csvFormat := event.QueryStringParameters["format"] == "csv"
+
+	limit, err := awsutil.ParseLimit(event.QueryStringParameters["limit"])
+	if err != nil {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+	}
+	if limit > 0 {
+		startKey, err := awsutil.DecodePageToken(event.QueryStringParameters["nextToken"])
+		if err != nil {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+		}
+
+		page, err := fetchQuestionsPage(ctx, limit, startKey)
+		if err != nil {
+			slog.Error("Failed to fetch questions", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+		}
+
+		if favorite {
+			page.Items = favoritesOnly(page.Items)
+		}
+		if csvFormat {
+			response := questionsCSV(page.Items)
+			if page.NextToken != "" {
+				response.Headers[awsutil.NextTokenHeader] = page.NextToken
+			}
+			return response, nil
+		}
+		return awsutil.JSONResponse(200, page), nil
+	}
+
 	questions, err := fetchAllQuestions(ctx)
 	if err != nil {
-		log.Printf("Failed to fetch questions: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode:	500,
-			Body:		"Internal Server Error",	 
-		}, nil
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	if favorite {
+		questions = favoritesOnly(questions)
 	}
+	if csvFormat {
+		return questionsCSV(questions), nil
+	}
+
+	return awsutil.JSONResponse(200, questions), nil
+}
 
-	responseBody, err := json.Marshal(questions)
+// csvHeader is the header row of the export, which the bulk add reads back
+var csvHeader = []string{"name", "date", "difficulty", "tags"}
+
+// questionsCSV renders one row per question with tags joined by ";"
+func questionsCSV(questions []model.Question) events.APIGatewayProxyResponse {
+	rows := make([][]string, 0, len(questions))
+	for _, q := range questions {
+		rows = append(rows, []string{q.Name, q.Date, q.Difficulty, strings.Join(q.Tags, ";")})
+	}
+	return awsutil.CSVResponse(200, "questions.csv", csvHeader, rows)
+}
+
+// fetchQuestionsPage runs a single scan of at most limit items. Filters are
+// applied to the page afterwards, so a filtered page can come back short.
+func fetchQuestionsPage(ctx context.Context, limit int32, startKey map[string]types.AttributeValue) (awsutil.Page[model.Question], error) {
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(tenant.Table(ctx, tableName)),
+		Limit:             aws.Int32(limit),
+		ExclusiveStartKey: startKey,
+	}
+	user.ScopeScan(ctx, input)
+
+	output, err := dynamoClient.Scan(ctx, input)
 	if err != nil {
-		log.Printf("Failed to marshal response: %v", err)
-        	return events.APIGatewayProxyResponse{
-            		StatusCode:	500,
-            		Body:		"Internal Server Error",
-        	}, nil
-	}
-
-	return events.APIGatewayProxyResponse{
-		StatusCode:	200,
-		Headers:	map[string]string{
-			"Content-Type":                   "application/json",
-        		"Access-Control-Allow-Origin":    "*",
-            		"Access-Control-Allow-Methods":   "GET, OPTIONS",
-            		"Access-Control-Allow-Headers":   "Content-Type, Authorization",
-        
-		},
-		Body:	string(responseBody),
-	}, nil
+		return awsutil.Page[model.Question]{}, fmt.Errorf("failed to scan DynamoDB: %w", err)
+	}
+
+	questions, err := model.QuestionsFromItems(output.Items)
+	if err != nil {
+		return awsutil.Page[model.Question]{}, err
+	}
+	if questions == nil {
+		questions = []model.Question{}
+	}
+
+	nextToken, err := awsutil.EncodePageToken(output.LastEvaluatedKey)
+	if err != nil {
+		return awsutil.Page[model.Question]{}, err
+	}
+	return awsutil.Page[model.Question]{Items: questions, NextToken: nextToken}, nil
+}
+
+func favoritesOnly(questions []model.Question) []model.Question {
+	favorites := []model.Question{}
+	for _, q := range questions {
+		if q.Favorite {
+			favorites = append(favorites, q)
+		}
+	}
+	return favorites
 }
 
-func fetchAllQuestions(ctx context.Context) ([]Question, error) {
-	var questions []Question
+func fetchAllQuestions(ctx context.Context) (questions []model.Question, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllQuestions")
+	defer func() { segment.End(err) }()
+
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(tenant.Table(ctx, tableName)),
 	}
+	user.ScopeScan(ctx, input)
 
+	start := time.Now()
 	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		page, err := awsutil.NextPage(ctx, paginator)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
 		}
-		
+
 		for _, item := range page.Items {
-			log.Printf("Raw item: %v", item)
+			slog.Debug("Raw item", "item", item)
 		}
 
-		var pageQuestions []struct {
-			Name       string `dynamodbav:"question_name"`
-			Date       string `dynamodbav:"question_solved_date"`
-			Difficulty string `dynamodbav:"difficulty"`
-			Tags       string `dynamodbav:"tags"` // Tags as a string from DynamoDB
-		}
-		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageQuestions)
+		pageQuestions, err := model.QuestionsFromItems(page.Items)
 		if err != nil {
-         		return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
-        	}
-
-		for _, q := range pageQuestions {
-			var tags []string
-			if err := json.Unmarshal([]byte(q.Tags), &tags); err != nil {
-				log.Printf("Failed to parse tags for question %s: %v", q.Name, err)
-				tags = []string{} // Default to an empty array if parsing fails
-			}
-
-			questions = append(questions, Question{
-				Name:       q.Name,
-				Date:       q.Date,
-				Difficulty: q.Difficulty,
-				Tags:       tags,
-			})
+			return nil, err
 		}
+
+		questions = append(questions, pageQuestions...)
 	}
 
+	metrics.Scan(ctx, start, len(questions))
 	return questions, nil
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
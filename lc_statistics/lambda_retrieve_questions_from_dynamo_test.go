@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/model"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+func questionItem(name, date string, tags types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: "Easy"},
+		"tags":                 tags,
+	}
+}
+
+func TestFetchAllQuestionsPaginates(t *testing.T) {
+	items := []map[string]types.AttributeValue{
+		questionItem("two-sum", "2024-01-01", &types.AttributeValueMemberSS{Value: []string{"Array"}}),
+		questionItem("valid-parentheses", "02/01/2024", &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "Stack"}}}),
+		questionItem("climbing-stairs", "2024-01-03", &types.AttributeValueMemberS{Value: `["Dynamic Programming"]`}),
+	}
+
+	tests := []struct {
+		name      string
+		pageSize  int
+		wantScans int
+	}{
+		{"single page", 0, 1},
+		{"page per item", 1, 3},
+		{"last page partial", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName, items...)
+			fake.PageSize = tt.pageSize
+			dynamoClient = fake
+
+			questions, err := fetchAllQuestions(context.Background())
+			if err != nil {
+				t.Fatalf("fetchAllQuestions: %v", err)
+			}
+			if got := fake.Count("Scan"); got != tt.wantScans {
+				t.Errorf("Scan called %d times, want %d", got, tt.wantScans)
+			}
+
+			var names, dates []string
+			var tags [][]string
+			for _, q := range questions {
+				names = append(names, q.Name)
+				dates = append(dates, q.Date)
+				tags = append(tags, q.Tags)
+			}
+			if want := []string{"two-sum", "valid-parentheses", "climbing-stairs"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("names = %v, want %v", names, want)
+			}
+			if want := []string{"2024-01-01", "2024-01-02", "2024-01-03"}; !reflect.DeepEqual(dates, want) {
+				t.Errorf("dates = %v, want %v", dates, want)
+			}
+			if want := [][]string{{"Array"}, {"Stack"}, {"Dynamic Programming"}}; !reflect.DeepEqual(tags, want) {
+				t.Errorf("tags = %v, want %v", tags, want)
+			}
+		})
+	}
+}
+
+func TestFetchAllQuestionsMalformedItems(t *testing.T) {
+	valid := questionItem("two-sum", "2024-01-01", &types.AttributeValueMemberSS{Value: []string{"Array"}})
+
+	t.Run("wrong attribute type fails the scan", func(t *testing.T) {
+		malformed := questionItem("broken", "2024-01-02", nil)
+		malformed["attempts"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+		delete(malformed, "tags")
+		fake := dynamotest.New(tableName, valid, malformed)
+		fake.PageSize = 1
+		dynamoClient = fake
+
+		if _, err := fetchAllQuestions(context.Background()); err == nil {
+			t.Fatal("fetchAllQuestions succeeded, want an unmarshal error")
+		}
+	})
+
+	t.Run("unreadable tags are dropped", func(t *testing.T) {
+		malformed := questionItem("broken-tags", "2024-01-02", &types.AttributeValueMemberS{Value: "[not json"})
+		dynamoClient = dynamotest.New(tableName, valid, malformed)
+
+		questions, err := fetchAllQuestions(context.Background())
+		if err != nil {
+			t.Fatalf("fetchAllQuestions: %v", err)
+		}
+		if len(questions) != 2 {
+			t.Fatalf("got %d questions, want 2", len(questions))
+		}
+		if got := questions[1].Tags; len(got) != 0 {
+			t.Errorf("tags = %v, want none", got)
+		}
+	})
+
+	t.Run("scan error on a later page", func(t *testing.T) {
+		scanErr := errors.New("boom")
+		fake := dynamotest.New(tableName, valid, valid)
+		fake.PageSize = 1
+		fake.Err = func(operation string, n int) error {
+			if operation == "Scan" && n == 2 {
+				return scanErr
+			}
+			return nil
+		}
+		dynamoClient = fake
+
+		if _, err := fetchAllQuestions(context.Background()); !errors.Is(err, scanErr) {
+			t.Fatalf("err = %v, want %v", err, scanErr)
+		}
+	})
+}
+
+func TestHandlerFavoriteFilter(t *testing.T) {
+	favorite := questionItem("two-sum", "2024-01-01", &types.AttributeValueMemberSS{Value: []string{"Array"}})
+	favorite["favorite"] = &types.AttributeValueMemberBOOL{Value: true}
+	unmarked := questionItem("valid-parentheses", "2024-01-02", &types.AttributeValueMemberSS{Value: []string{"Stack"}})
+	unmarked["favorite"] = &types.AttributeValueMemberBOOL{Value: false}
+	// Written before the flag existed
+	legacy := questionItem("climbing-stairs", "2024-01-03", &types.AttributeValueMemberSS{Value: []string{"Dynamic Programming"}})
+	dynamoClient = dynamotest.New(tableName, favorite, unmarked, legacy)
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   []string
+	}{
+		{"no filter", nil, []string{"two-sum", "valid-parentheses", "climbing-stairs"}},
+		{"favorites", map[string]string{"favorite": "true"}, []string{"two-sum"}},
+		{"false lists everything", map[string]string{"favorite": "false"}, []string{"two-sum", "valid-parentheses", "climbing-stairs"}},
+		{"favorites on a page", map[string]string{"favorite": "true", "limit": "10"}, []string{"two-sum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: tt.params})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+
+			var questions []model.Question
+			if tt.params["limit"] != "" {
+				var page awsutil.Page[model.Question]
+				err = json.Unmarshal([]byte(response.Body), &page)
+				questions = page.Items
+			} else {
+				err = json.Unmarshal([]byte(response.Body), &questions)
+			}
+			if err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+
+			names := []string{}
+			for _, q := range questions {
+				names = append(names, q.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	// Pages are filtered after the read, so they may come back short or empty
+	var sizes []int
+	token := ""
+	for {
+		params := map[string]string{"favorite": "true", "limit": "1", "nextToken": token}
+		response, err := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: params})
+		if err != nil || response.StatusCode != 200 {
+			t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+		}
+		var page awsutil.Page[model.Question]
+		if err := json.Unmarshal([]byte(response.Body), &page); err != nil {
+			t.Fatalf("unmarshal body: %v", err)
+		}
+		sizes = append(sizes, len(page.Items))
+		if token = page.NextToken; token == "" {
+			break
+		}
+	}
+	if want := []int{1, 0, 0}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("page sizes = %v, want %v", sizes, want)
+	}
+}
+
+func TestHandlerCSV(t *testing.T) {
+	withComma := questionItem("two-sum, again", "2024-01-01", &types.AttributeValueMemberSS{Value: []string{"Array", "Hash Table"}})
+	withComma["url"] = &types.AttributeValueMemberS{Value: "https://leetcode.com/problems/two-sum/"}
+	withComma["created_at"] = &types.AttributeValueMemberS{Value: "2024-01-01T12:30:00Z"}
+	dynamoClient = dynamotest.New(tableName, withComma, questionItem("climbing-stairs", "02/01/2024", &types.AttributeValueMemberSS{Value: []string{"Dynamic Programming"}}))
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"format": "csv"},
+	})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if got := response.Headers["Content-Type"]; !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	want := "name,date,difficulty,tags\r\n" +
+		"\"two-sum, again\",2024-01-01,Easy,Array;Hash Table\r\n" +
+		"climbing-stairs,2024-01-02,Easy,Dynamic Programming\r\n"
+	if response.Body != want {
+		t.Errorf("body = %q, want %q", response.Body, want)
+	}
+}
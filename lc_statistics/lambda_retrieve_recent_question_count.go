@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+type Response struct {
+	Days  int `json:"days"`
+	Count int `json:"count"`
+}
+
+const (
+	defaultDays = 30
+	maxDays     = 3650
+)
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler counts the questions first solved in the last 30 days, or ?days=N,
+// today included. Today is the calendar day in the configured timezone.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	days := defaultDays
+	if value := event.QueryStringParameters["days"]; value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > maxDays {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("invalid days %q: use a whole number from 1 to %d", value, maxDays)), nil
+		}
+	}
+
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	return awsutil.JSONResponse(200, Response{
+		Days:  days,
+		Count: countSince(questions, days, time.Now()),
+	}), nil
+}
+
+// countSince counts the questions dated from days-1 days before today up to
+// today. Later dates and dates that don't parse are not counted.
+func countSince(questions []model.Question, days int, now time.Time) int {
+	today := calendar.Today(now)
+	from := today.AddDate(0, 0, -(days - 1))
+
+	count := 0
+	for _, q := range questions {
+		date, err := calendar.ParseDate(q.Date)
+		if err != nil {
+			continue
+		}
+		if !date.Before(from) && !date.After(today) {
+			count++
+		}
+	}
+	return count
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
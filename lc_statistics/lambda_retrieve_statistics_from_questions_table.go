@@ -1,162 +1,188 @@
 package main
 
 import (
-    "context"
-    "encoding/json"
-    "fmt"
-    "log"
-    "github.com/aws/aws-lambda-go/events"
-    "github.com/aws/aws-lambda-go/lambda"
-    "github.com/aws/aws-sdk-go-v2/aws"
-    "github.com/aws/aws-sdk-go-v2/config"
-    "github.com/aws/aws-sdk-go-v2/service/dynamodb"
-    "github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/legacystats"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
 )
 
-type Question struct {
-	Name       string   `dynamodbav:"question_name"`
-	Date       string   `dynamodbav:"question_solved_date"`
-	Difficulty string   `dynamodbav:"difficulty"`
-	Tags       []string `json:"tags"`
-}
+// Statistics is the response, shared with the dashboard
+type Statistics = legacystats.Statistics
 
-type Statistics struct {
-	QuestionsCrackedPerDay		map[string]int	`json:"questionsCrackedPerDay"`
-	QuestionsCrackedPerDifficulty	map[string]int	`json:"questionsCrackedPerDifficulty"`
-	QuestionsCrackedPerTag		map[string]int	`json:"questionsCrackedPerTag"`
-	TotalQuestionsCracked		int		`json:"totalQuestionsCracked"`
-}
+var dynamoClient awsutil.DynamoAPI
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
 
-var dynamoClient *dynamodb.Client
-const tableName = "veet_code_questions_table"
+var statsTableName = awsutil.TableName(aggregate.StatsTableEnv, aggregate.DefaultStatsTable)
+
+var goalsTableName = awsutil.TableName(goal.TableEnv, goal.DefaultTable)
+
+// tagAliases come from TAG_ALIASES
+var tagAliases map[string]string
+
+// statsCache survives between invocations of a warm container
+var statsCache = cache.FromEnv()
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	tagAliases, err = alias.Parse(os.Getenv(alias.EnvTagAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvTagAliases, err)
+	}
+
+	client, err := awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-	dynamoClient = dynamodb.NewFromConfig(cfg)
+	dynamoClient = client
+	questionStore = &store.DynamoQuestionStore{Client: client, Table: tableName}
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	questions, err := fetchAllQuestions(ctx)
+	ctx, err := tenant.FromRequest(ctx, event)
 	if err != nil {
-		log.Printf("Failed to fetch questions: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode:	500,
-			Body:		"Internal Server Error",	 
-		}, nil
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
 	}
 
-	_, err = json.Marshal(questions)
-	if err != nil {
-		log.Printf("Failed to marshal response: %v", err)
-        	return events.APIGatewayProxyResponse{
-            		StatusCode:	500,
-            		Body:		"Internal Server Error",
-        	}, nil
+	cacheKey := cache.Key(ctx, event)
+	if cached, ok := statsCache.Get(cacheKey, event); ok {
+		return awsutil.Compress(event, awsutil.WithETag(event, cached)), nil
 	}
 
-	stats := generateStatistics(questions)
-	statsJSON, err := json.MarshalIndent(stats, "", "  ")
-	if err != nil {
-		fmt.Printf("Failed to marshal stats: %v\n", err)
-	} else {
-		fmt.Printf("Generated stats(JSON): \n%s\n", statsJSON)
-	}
-	
-	responseBody, err := json.Marshal(stats)
+	dateRange, err := calendar.DateRangeFromQuery(event.QueryStringParameters)
 	if err != nil {
-		log.Printf("Failed to marshal response: %v", err)
-        	return events.APIGatewayProxyResponse{
-            		StatusCode:	500,
-            		Body:		"Internal Server Error",
-        	}, nil
-	}
-	
-	return events.APIGatewayProxyResponse{
-		StatusCode:	200,
-		Headers:	map[string]string{
-			"Content-Type":                   "application/json",
-        		"Access-Control-Allow-Origin":    "*",
-            		"Access-Control-Allow-Methods":   "GET, OPTIONS",
-            		"Access-Control-Allow-Headers":   "Content-Type, Authorization",
-        
-		},
-		Body:	string(responseBody),
-	}, nil
-}
-
-func fetchAllQuestions(ctx context.Context) ([]Question, error) {
-	var questions []Question
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
 	}
 
-	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	// The precomputed item only covers the whole history; ?refresh=true
+	// rebuilds it from a scan. Its counters span every user, so per-user
+	// deployments always scan. The item's version is read before the scan, so
+	// Seed can tell whether the stream updated it in the meantime.
+	// A stale item is recalculated: a backdated edit marks it from its date,
+	// and only the questions solved or reviewed since are read to rebuild the
+	// daily counters; a record the stream consumer could not apply marks the
+	// whole history, which needs the full scan.
+	sourceTable := tenant.Table(ctx, tableName)
+	seed, recalculate := false, false
+	var snapshot aggregate.Snapshot
+	if dateRange.IsZero() && !user.Enabled() {
+		var ok bool
+		snapshot, ok, err = aggregate.Load(ctx, dynamoClient, statsTableName, sourceTable)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
-		}
-		
-		for _, item := range page.Items {
-			log.Printf("Raw item: %v", item)
+			slog.Warn("Failed to load precomputed statistics, scanning instead", "error", err)
+		} else if !ok || event.QueryStringParameters[cache.RefreshParam] == "true" {
+			seed = true
+		} else if snapshot.Stale && snapshot.StaleFrom != "" {
+			if fresh, ok := recalculateRange(ctx, sourceTable, snapshot); ok {
+				return snapshotResponse(ctx, event, cacheKey, fresh), nil
+			}
+			recalculate = true
+		} else if snapshot.Stale {
+			recalculate = true
+		} else {
+			return snapshotResponse(ctx, event, cacheKey, snapshot), nil
 		}
+	}
 
-		var pageQuestions []struct {
-			Name       string `dynamodbav:"question_name"`
-			Date       string `dynamodbav:"question_solved_date"`
-			Difficulty string `dynamodbav:"difficulty"`
-			Tags       string `dynamodbav:"tags"`
-		}
-		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageQuestions)
-		if err != nil {
-         		return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
-        	}
-
-		for _, q := range pageQuestions {
-			var tags []string
-			if err := json.Unmarshal([]byte(q.Tags), &tags); err != nil {
-				log.Printf("Failed to parse tags for question %s: %v", q.Name, err)
-				tags = []string{} 
-			}
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
 
-			questions = append(questions, Question{
-				Name:       q.Name,
-				Date:       q.Date,
-				Difficulty: q.Difficulty,
-				Tags:       tags,
-			})
+	if seed {
+		err := aggregate.Seed(ctx, dynamoClient, statsTableName, sourceTable, snapshot.Version, questions)
+		if errors.Is(err, aggregate.ErrSeedConflict) {
+			slog.Info("Precomputed statistics changed during the scan, keeping them", "sourceTable", sourceTable)
+		} else if err != nil {
+			slog.Warn("Failed to seed precomputed statistics", "error", err)
+		}
+	}
+	if recalculate {
+		err := aggregate.Recalculate(ctx, dynamoClient, statsTableName, sourceTable, snapshot.Version, snapshot.StaleFrom, questions)
+		if errors.Is(err, aggregate.ErrSeedConflict) {
+			slog.Info("Precomputed statistics changed during the scan, leaving them stale", "sourceTable", sourceTable)
+		} else if err != nil {
+			slog.Warn("Failed to recalculate precomputed statistics", "error", err)
 		}
 	}
 
-	return questions, nil
+	allPerDay := make(map[string]int)
+	for _, q := range questions {
+		allPerDay[q.Date]++
+	}
+	questions = model.FilterQuestions(questions, dateRange)
+
+	start := time.Now()
+	_, segment := tracing.Start(ctx, "generateStatistics")
+	stats := legacystats.Generate(questions, tagAliases)
+	segment.End(nil)
+	metrics.Emit(ctx, metrics.Duration(metrics.StatsGenerationMs, time.Since(start)))
+	stats.GoalProgress = legacystats.TrackGoal(ctx, dynamoClient, goalsTableName, allPerDay)
+	slog.Debug("Generated stats", "stats", stats)
+
+	response := statsCache.Put(cacheKey, awsutil.JSONResponse(200, stats))
+	return awsutil.Compress(event, awsutil.WithETag(event, response)), nil
 }
 
+// snapshotResponse serves the precomputed statistics
+func snapshotResponse(ctx context.Context, event events.APIGatewayProxyRequest, cacheKey string, snapshot aggregate.Snapshot) events.APIGatewayProxyResponse {
+	// The counters are kept per raw tag, so they are folded here
+	tags := alias.NewCanonicalizer(tagAliases)
+	snapshot.Totals.FoldTags(tags)
+	stats := Statistics{QuestionTotals: snapshot.Totals, QuestionsCrackedPerDay: snapshot.PerDay, MergedKeys: tags.MergedKeys()}
+	stats.GoalProgress = legacystats.TrackGoal(ctx, dynamoClient, goalsTableName, snapshot.PerDay)
+	response := statsCache.Put(cacheKey, awsutil.JSONResponse(200, stats))
+	return awsutil.Compress(event, awsutil.WithETag(event, response))
+}
 
-func generateStatistics(questions []Question) Statistics {
-	stats := Statistics{
-		QuestionsCrackedPerDay:			make(map[string]int),
-		QuestionsCrackedPerDifficulty:		make(map[string]int),
-		QuestionsCrackedPerTag:			make(map[string]int),
-		TotalQuestionsCracked:			0,
+// recalculateRange rebuilds the daily counters of a snapshot marked stale
+// from a date, reading only the questions solved or reviewed since. It
+// returns the recalculated snapshot, or false to fall back to the full scan.
+func recalculateRange(ctx context.Context, sourceTable string, snapshot aggregate.Snapshot) (aggregate.Snapshot, bool) {
+	questions, err := questionStore.FetchSince(ctx, snapshot.StaleFrom)
+	if err != nil {
+		slog.Warn("Failed to read the questions since the stale date, scanning instead", "staleFrom", snapshot.StaleFrom, "error", err)
+		return aggregate.Snapshot{}, false
 	}
-
-	for _, q := range questions {
-		stats.QuestionsCrackedPerDay[q.Date]++
-		
-		stats.QuestionsCrackedPerDifficulty[q.Difficulty]++
-		
-		for _, tag := range q.Tags {
-			stats.QuestionsCrackedPerTag[tag]++
-		}
-		
-		stats.TotalQuestionsCracked++
+	err = aggregate.Recalculate(ctx, dynamoClient, statsTableName, sourceTable, snapshot.Version, snapshot.StaleFrom, questions)
+	if err != nil {
+		slog.Warn("Failed to recalculate precomputed statistics, scanning instead", "staleFrom", snapshot.StaleFrom, "error", err)
+		return aggregate.Snapshot{}, false
+	}
+	fresh, ok, err := aggregate.Load(ctx, dynamoClient, statsTableName, sourceTable)
+	if err != nil || !ok || fresh.Stale {
+		return aggregate.Snapshot{}, false
 	}
-	return stats
+	return fresh, true
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
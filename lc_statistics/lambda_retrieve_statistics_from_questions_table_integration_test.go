@@ -0,0 +1,87 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+)
+
+// storedQuestion is an item as the add handlers write it, legacy date aside
+func storedQuestion(name, date, difficulty string, tags ...string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: difficulty},
+		"tags":                 model.TagsAttributeValue(tags),
+	}
+}
+
+// Run against DynamoDB Local with
+//
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test -tags integration \
+//		lambda_retrieve_statistics_from_questions_table.go lambda_retrieve_statistics_from_questions_table_integration_test.go
+func TestIntegrationStatistics(t *testing.T) {
+	statsSchema := dynamotest.Schema{Table: aggregate.DefaultStatsTable, Hash: aggregate.KeyAttribute}
+	client := dynamotest.Local(t, dynamotest.QuestionsSchema, statsSchema)
+	dynamoClient = client
+	tableName = dynamotest.QuestionsSchema.Table
+	statsTableName = statsSchema.Table
+	questionStore = &store.DynamoQuestionStore{Client: client, Table: tableName}
+	ctx := context.Background()
+
+	for _, item := range []map[string]types.AttributeValue{
+		storedQuestion("two-sum", "2024-03-01", "Easy", "Array"),
+		storedQuestion("clone-graph", "03/03/2024", "Medium", "Graph"),
+		storedQuestion("word-ladder", "2024-03-03", "Hard", "Graph", "BFS"),
+	} {
+		_, err := client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item})
+		if err != nil {
+			t.Fatalf("PutItem: %v", err)
+		}
+	}
+
+	get := func() Statistics {
+		t.Helper()
+		response, err := Handler(ctx, events.APIGatewayProxyRequest{})
+		if err != nil || response.StatusCode != 200 {
+			t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+		}
+		var stats Statistics
+		if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+			t.Fatalf("unmarshal body: %v", err)
+		}
+		return stats
+	}
+
+	// The first request scans and seeds the aggregate, the second reads it
+	scanned := get()
+	if _, ok, err := aggregate.Load(ctx, client, statsTableName, tableName); err != nil || !ok {
+		t.Fatalf("aggregate not seeded: %v, %v", ok, err)
+	}
+	counted := get()
+
+	for name, stats := range map[string]Statistics{"scanned": scanned, "counted": counted} {
+		if stats.TotalQuestionsCracked != 3 {
+			t.Errorf("%s total = %d, want 3", name, stats.TotalQuestionsCracked)
+		}
+		if want := map[string]int{"2024-03-01": 1, "2024-03-03": 2}; !reflect.DeepEqual(stats.QuestionsCrackedPerDay, want) {
+			t.Errorf("%s per day = %v, want %v", name, stats.QuestionsCrackedPerDay, want)
+		}
+	}
+	if !reflect.DeepEqual(scanned.QuestionTotals, counted.QuestionTotals) {
+		t.Errorf("counted totals %+v, want the scanned %+v", counted.QuestionTotals, scanned.QuestionTotals)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/aggregate"
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+)
+
+func questionItem(name, date, difficulty string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"question_name":        &types.AttributeValueMemberS{Value: name},
+		"question_solved_date": &types.AttributeValueMemberS{Value: date},
+		"difficulty":           &types.AttributeValueMemberS{Value: difficulty},
+		"tags":                 &types.AttributeValueMemberSS{Value: []string{"Graph"}},
+	}
+}
+
+func TestHandlerRecalculatesStaleStatistics(t *testing.T) {
+	ctx := context.Background()
+	items := []map[string]types.AttributeValue{
+		questionItem("two-sum", "2024-03-01", "Easy"),
+		questionItem("clone-graph", "2024-03-03", "Medium"),
+		questionItem("word-ladder", "2024-03-08", "Hard"),
+	}
+	all, err := model.QuestionsFromItems(items)
+	if err != nil {
+		t.Fatalf("QuestionsFromItems: %v", err)
+	}
+
+	// The stats were seeded with every question, then clone-graph was deleted
+	// by a stream record the consumer could not apply
+	fake := dynamotest.New(tableName, items[0], items[2])
+	fake.Keys = map[string][]string{statsTableName: {aggregate.KeyAttribute}}
+	dynamoClient = fake
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+	if err := aggregate.Seed(ctx, fake, statsTableName, tableName, 0, all); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if err := aggregate.MarkStale(ctx, fake, statsTableName, tableName, ""); err != nil {
+		t.Fatalf("MarkStale: %v", err)
+	}
+
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var stats Statistics
+	if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if stats.TotalQuestionsCracked != 2 || stats.QuestionsCrackedPerDay["2024-03-03"] != 0 {
+		t.Errorf("stats = %+v, want them from the scan", stats)
+	}
+
+	remaining := []model.Question{all[0], all[2]}
+	snapshot, _, err := aggregate.Load(ctx, fake, statsTableName, tableName)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := aggregate.FromQuestions(remaining)
+	if snapshot.Stale {
+		t.Error("statistics still stale after the request")
+	}
+	if !reflect.DeepEqual(snapshot.Totals, want.Totals) || !reflect.DeepEqual(snapshot.PerDay, want.PerDay) {
+		t.Errorf("snapshot = %+v %v, want %+v %v", snapshot.Totals, snapshot.PerDay, want.Totals, want.PerDay)
+	}
+
+	// The next request is served from the counters again
+	scans := fake.Count("Scan")
+	statsCache = cache.New(0)
+	if response, err := Handler(ctx, events.APIGatewayProxyRequest{}); err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if got := fake.Count("Scan"); got != scans {
+		t.Errorf("scanned %d more times, want the counters served", got-scans)
+	}
+}
+
+func TestHandlerRecalculatesBackdatedEdit(t *testing.T) {
+	ctx := context.Background()
+	before := []map[string]types.AttributeValue{
+		questionItem("two-sum", "2024-03-01", "Easy"),
+		questionItem("clone-graph", "2024-03-03", "Medium"),
+		questionItem("word-ladder", "2024-03-08", "Hard"),
+	}
+	seeded, err := model.QuestionsFromItems(before)
+	if err != nil {
+		t.Fatalf("QuestionsFromItems: %v", err)
+	}
+
+	// word-ladder was moved back to 2024-03-02 by an edit, which marked the
+	// statistics stale from its new date
+	after := []map[string]types.AttributeValue{before[0], before[1], questionItem("word-ladder", "2024-03-02", "Hard")}
+	edited, err := model.QuestionsFromItems(after)
+	if err != nil {
+		t.Fatalf("QuestionsFromItems: %v", err)
+	}
+	fake := dynamotest.New(tableName, after...)
+	fake.Keys = map[string][]string{statsTableName: {aggregate.KeyAttribute}}
+	dynamoClient = fake
+	questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+	if err := aggregate.Seed(ctx, fake, statsTableName, tableName, 0, seeded); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if err := aggregate.MarkStaleIfBackdated(ctx, fake, statsTableName, tableName, "2024-03-02"); err != nil {
+		t.Fatalf("MarkStaleIfBackdated: %v", err)
+	}
+
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var stats Statistics
+	if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	want := aggregate.FromQuestions(edited)
+	if !reflect.DeepEqual(stats.QuestionsCrackedPerDay, want.PerDay) {
+		t.Errorf("per day = %v, want %v", stats.QuestionsCrackedPerDay, want.PerDay)
+	}
+	if got := fake.Count("Scan"); got != 1 {
+		t.Errorf("scanned %d times, want only the range read", got)
+	}
+
+	snapshot, _, err := aggregate.Load(ctx, fake, statsTableName, tableName)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if snapshot.Stale {
+		t.Error("statistics still stale after the request")
+	}
+	if !reflect.DeepEqual(snapshot.Totals, want.Totals) || !reflect.DeepEqual(snapshot.PerDay, want.PerDay) {
+		t.Errorf("snapshot = %+v %v, want %+v %v", snapshot.Totals, snapshot.PerDay, want.Totals, want.PerDay)
+	}
+}
+
+// Two tenants of one deployment read their own tables, aggregates and cache
+// entries, however the tenant is resolved
+func TestHandlerIsolatesTenants(t *testing.T) {
+	t.Setenv(tenant.EnvTenants, "alice,bob")
+
+	requests := map[string]func(name string) events.APIGatewayProxyRequest{
+		"header": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Headers: map[string]string{tenant.Header: name}}
+		},
+		"path parameter": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{PathParameters: map[string]string{"tenant": name}}
+		},
+		"path segment": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Path: "/" + name + "/statistics"}
+		},
+		"subdomain": func(name string) events.APIGatewayProxyRequest {
+			return events.APIGatewayProxyRequest{Headers: map[string]string{"Host": name + ".veet.example.com"}}
+		},
+	}
+	for resolution, request := range requests {
+		t.Run(resolution, func(t *testing.T) {
+			fake := dynamotest.New("alice_"+tableName,
+				questionItem("two-sum", "2024-03-01", "Easy"),
+				questionItem("clone-graph", "2024-03-03", "Medium"),
+			)
+			fake.Tables["bob_"+tableName] = []map[string]types.AttributeValue{questionItem("word-ladder", "2024-03-08", "Hard")}
+			fake.Keys = map[string][]string{statsTableName: {aggregate.KeyAttribute}}
+			dynamoClient = fake
+			questionStore = &store.DynamoQuestionStore{Client: fake, Table: tableName}
+			statsCache = cache.New(time.Minute)
+			t.Cleanup(func() { statsCache = cache.New(0) })
+
+			// Twice each, so the second answers come from the aggregate and
+			// the cache
+			for round := 0; round < 2; round++ {
+				for name, want := range map[string]map[string]int{
+					"alice": {"Easy": 1, "Medium": 1},
+					"bob":   {"Hard": 1},
+				} {
+					response, err := Handler(context.Background(), request(name))
+					if err != nil || response.StatusCode != 200 {
+						t.Fatalf("Handler %s = %d %s, %v", name, response.StatusCode, response.Body, err)
+					}
+					var stats Statistics
+					if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+						t.Fatalf("unmarshal body: %v", err)
+					}
+					if !reflect.DeepEqual(stats.QuestionsCrackedPerDifficulty, want) {
+						t.Errorf("round %d, %s's difficulties = %v, want %v", round, name, stats.QuestionsCrackedPerDifficulty, want)
+					}
+				}
+			}
+
+			for name, want := range map[string]int{"alice": 2, "bob": 1} {
+				snapshot, ok, err := aggregate.Load(context.Background(), fake, statsTableName, name+"_"+tableName)
+				if err != nil || !ok || snapshot.Totals.TotalQuestionsCracked != want {
+					t.Errorf("%s's aggregate = %+v, %v, %v, want %d questions", name, snapshot.Totals, ok, err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/store"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+var questionStore store.QuestionStore
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	questionStore, err = store.NewDynamoQuestionStore(context.TODO(), tableName)
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler returns the questions whose name starts with ?prefix=, ignoring case
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	prefix := event.QueryStringParameters["prefix"]
+	var fields validation.Fields
+	fields.Require("prefix", prefix)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	// Scan everything: begins_with is case-sensitive and there is no
+	// lowercased name index to query instead
+	questions, err := questionStore.FetchAll(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch questions", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	return awsutil.JSONResponse(200, matchPrefix(questions, prefix)), nil
+}
+
+func matchPrefix(questions []model.Question, prefix string) []model.Question {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	matches := []model.Question{}
+	for _, q := range questions {
+		if strings.HasPrefix(strings.ToLower(q.Name), prefix) {
+			matches = append(matches, q)
+		}
+	}
+	return matches
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+type Request struct {
+	Flag  string `json:"flag"`
+	Value *bool  `json:"value"`
+}
+
+type Response struct {
+	Name    string `json:"name"`
+	Flag    string `json:"flag"`
+	Value   bool   `json:"value"`
+	Version int    `json:"version"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "veet_code_questions_table"
+
+var tableName = awsutil.TableName(awsutil.QuestionsTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler serves POST /questions/{name}/flags, setting one boolean flag in a
+// single conditional update so concurrent sessions cannot clobber each other
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	var request Request
+	err = json.Unmarshal([]byte(event.Body), &request)
+	if err != nil {
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+
+	name := event.PathParameters["name"]
+	var fields validation.Fields
+	fields.Require("name", name)
+	request.validate(&fields)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	version, err := setFlag(ctx, name, request.Flag, *request.Value)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "question not found"), nil
+	}
+	if err != nil {
+		slog.Error("Failed to set flag", "flag", request.Flag, "question", name, "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to update the question"), nil
+	}
+
+	// Every flag change goes through here, which makes this the audit trail
+	slog.Info("Flag set", "flag", request.Flag, "question", name, "value", *request.Value, "version", version)
+
+	return awsutil.JSONResponse(200, Response{
+		Name:    name,
+		Flag:    request.Flag,
+		Value:   *request.Value,
+		Version: version,
+	}), nil
+}
+
+func (r Request) validate(fields *validation.Fields) {
+	fields.Require("flag", r.Flag)
+	if _, ok := model.BooleanFlags[r.Flag]; r.Flag != "" && !ok {
+		fields.Add("flag", fmt.Sprintf("unknown flag %q", r.Flag))
+	}
+	if r.Value == nil {
+		fields.Add("value", "is required")
+	}
+}
+
+// setFlag returns the new version of the question. It fails with
+// ConditionalCheckFailedException when the user has no such question.
+func setFlag(ctx context.Context, name, flag string, value bool) (int, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tenant.Table(ctx, tableName)),
+		Key:                 model.QuestionKey(ctx, name),
+		UpdateExpression:    aws.String("SET #flag = :value, #version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_exists(question_name)"),
+		ExpressionAttributeNames: map[string]string{
+			"#flag":    model.BooleanFlags[flag],
+			"#version": model.VersionAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberBOOL{Value: value},
+			":zero":  &types.AttributeValueMemberN{Value: "0"},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+
+	output, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	version, ok := output.Attributes[model.VersionAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("update returned no %s attribute", model.VersionAttribute)
+	}
+	return strconv.Atoi(version.Value)
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
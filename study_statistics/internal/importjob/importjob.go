@@ -0,0 +1,154 @@
+package importjob
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+// TableEnv names the table bulk imports report their progress to. Progress
+// is only reported when it is set.
+const TableEnv = "IMPORT_JOBS_TABLE_NAME"
+
+// KeyAttribute is the table's key, the job ID scoped to the user
+const KeyAttribute = "job_id"
+
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	// StatusPartial means some studies were still unprocessed after retrying
+	StatusPartial = "partial"
+	StatusFailed  = "failed"
+)
+
+// Job is the progress of one bulk import, kept in an item of its own so it
+// can be read while the import runs
+type Job struct {
+	ID      string `json:"id" dynamodbav:"-"`
+	Status  string `json:"status" dynamodbav:"status"`
+	Total   int    `json:"total" dynamodbav:"total"`
+	Written int    `json:"written" dynamodbav:"written"`
+	Unsaved int    `json:"unsaved" dynamodbav:"unsaved"`
+	// WritesPerSecond is the effective write rate after the last chunk, lower
+	// than the budget while DynamoDB pushes back; 0 when writes are unpaced
+	WritesPerSecond float64 `json:"writesPerSecond" dynamodbav:"writes_per_second"`
+	// UpdatedAt is when the item was last written, in RFC3339 UTC
+	UpdatedAt string `json:"updatedAt" dynamodbav:"updated_at"`
+}
+
+// Tracker writes the progress of a job as the import goes. A nil Tracker, as
+// returned when no table is configured, does nothing.
+type Tracker struct {
+	client awsutil.DynamoAPI
+	table  string
+	now    func() time.Time
+	job    Job
+}
+
+// Start records a running job of total studies and returns its tracker, or
+// nil when table is empty
+func Start(ctx context.Context, client awsutil.DynamoAPI, table, id string, total int, now func() time.Time) *Tracker {
+	if table == "" {
+		return nil
+	}
+
+	t := &Tracker{client: client, table: table, now: now, job: Job{ID: id, Status: StatusRunning, Total: total}}
+	t.save(ctx)
+	return t
+}
+
+// ID returns the job ID, or "" for a nil tracker
+func (t *Tracker) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.job.ID
+}
+
+// Progress records how many studies are written so far and the current
+// effective rate
+func (t *Tracker) Progress(ctx context.Context, written, unsaved int, writesPerSecond float64) {
+	if t == nil {
+		return
+	}
+	t.job.Written = written
+	t.job.Unsaved = unsaved
+	t.job.WritesPerSecond = writesPerSecond
+	t.save(ctx)
+}
+
+// Finish records the outcome: failed when err is set, partial when studies
+// were left unsaved, completed otherwise
+func (t *Tracker) Finish(ctx context.Context, err error) {
+	if t == nil {
+		return
+	}
+	switch {
+	case err != nil:
+		t.job.Status = StatusFailed
+	case t.job.Unsaved > 0:
+		t.job.Status = StatusPartial
+	default:
+		t.job.Status = StatusCompleted
+	}
+	t.save(ctx)
+}
+
+// save replaces the job item. Progress is informational, so a failed write
+// is logged and the import carries on.
+func (t *Tracker) save(ctx context.Context) {
+	t.job.UpdatedAt = t.now().UTC().Format(time.RFC3339)
+
+	item, err := attributevalue.MarshalMap(t.job)
+	if err != nil {
+		slog.Warn("Failed to marshal import job", "jobId", t.job.ID, "error", err)
+		return
+	}
+	item[KeyAttribute] = key(ctx, t.job.ID)[KeyAttribute]
+
+	_, err = t.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tenant.Table(ctx, t.table)),
+		Item:      item,
+	})
+	if err != nil {
+		slog.Warn("Failed to record import job progress", "jobId", t.job.ID, "error", err)
+	}
+}
+
+func key(ctx context.Context, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		KeyAttribute: &types.AttributeValueMemberS{Value: user.Key(ctx, id)},
+	}
+}
+
+// Load reads a job of the tenant and user in ctx, reporting false when there
+// is none
+func Load(ctx context.Context, client awsutil.DynamoAPI, table, id string) (Job, bool, error) {
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tenant.Table(ctx, table)),
+		Key:       key(ctx, id),
+	})
+	if err != nil {
+		return Job{}, false, fmt.Errorf("failed to get import job item: %w", err)
+	}
+	if output.Item == nil {
+		return Job{}, false, nil
+	}
+
+	var job Job
+	if err := attributevalue.UnmarshalMap(output.Item, &job); err != nil {
+		return Job{}, false, fmt.Errorf("failed to unmarshal import job item: %w", err)
+	}
+	job.ID = id
+	return job, true, nil
+}
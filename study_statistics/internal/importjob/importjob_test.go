@@ -0,0 +1,90 @@
+package importjob
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/user"
+)
+
+const table = "import_jobs_table"
+
+func fixedNow() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+func TestTrackerRecordsProgress(t *testing.T) {
+	tests := []struct {
+		name       string
+		written    int
+		unsaved    int
+		err        error
+		wantStatus string
+	}{
+		{"all written", 60, 0, nil, StatusCompleted},
+		{"some unprocessed", 55, 5, nil, StatusPartial},
+		{"write failed", 25, 0, errors.New("boom"), StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(table)
+			fake.Keys = map[string][]string{table: {KeyAttribute}}
+			ctx := user.WithUser(context.Background(), "alice")
+
+			job := Start(ctx, fake, table, "job-1", 60, fixedNow)
+			if got, ok, _ := Load(ctx, fake, table, "job-1"); !ok || got.Status != StatusRunning {
+				t.Fatalf("after Start: %+v, %v, want a running job", got, ok)
+			}
+
+			job.Progress(ctx, tt.written, tt.unsaved, 12.5)
+			job.Finish(ctx, tt.err)
+
+			got, ok, err := Load(ctx, fake, table, "job-1")
+			if err != nil || !ok {
+				t.Fatalf("Load = %v, %v", ok, err)
+			}
+			want := Job{ID: "job-1", Status: tt.wantStatus, Total: 60, Written: tt.written, Unsaved: tt.unsaved, WritesPerSecond: 12.5, UpdatedAt: "2024-03-01T12:00:00Z"}
+			if got != want {
+				t.Errorf("job = %+v, want %+v", got, want)
+			}
+			if n := len(fake.Items(table)); n != 1 {
+				t.Errorf("table holds %d items, want the job replaced in place", n)
+			}
+		})
+	}
+}
+
+func TestLoadIsScopedToUser(t *testing.T) {
+	fake := dynamotest.New(table)
+	fake.Keys = map[string][]string{table: {KeyAttribute}}
+	Start(user.WithUser(context.Background(), "alice"), fake, table, "job-1", 1, fixedNow)
+
+	if _, ok, err := Load(user.WithUser(context.Background(), "bob"), fake, table, "job-1"); ok || err != nil {
+		t.Errorf("bob's Load = %v, %v, want no job", ok, err)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	job := Start(context.Background(), nil, "", "job-1", 1, fixedNow)
+	if job != nil {
+		t.Fatalf("Start without a table = %+v, want nil", job)
+	}
+	job.Progress(context.Background(), 1, 0, 0)
+	job.Finish(context.Background(), nil)
+	if job.ID() != "" {
+		t.Errorf("ID = %q, want empty", job.ID())
+	}
+}
+
+func TestFailedWritesAreNotFatal(t *testing.T) {
+	fake := dynamotest.New(table)
+	fake.Err = func(string, int) error { return errors.New("boom") }
+
+	job := Start(context.Background(), fake, table, "job-1", 1, fixedNow)
+	job.Progress(context.Background(), 1, 0, 0)
+	job.Finish(context.Background(), nil)
+	if got := fake.Count("PutItem"); got != 3 {
+		t.Errorf("PutItem called %d times, want every update attempted", got)
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"veet-code-go/internal/calendar"
+)
+
+// DateLayout is the dd/MM/yyyy layout study_date is stored with
+const DateLayout = calendar.DayFirstLayout
+
+// IDAttribute is the sort key of studies_table, under study_theme. The table
+// used to be keyed by theme and study_date, so a second session of a theme on
+// the same day replaced the first; every study now gets its own ID instead.
+const IDAttribute = "study_id"
+
+// NewStudyID returns a random (version 4) UUID
+func NewStudyID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// LegacyStudyID is the ID of a study written before IDs existed, when a theme
+// and date named at most one study: the date itself. The legacy migration
+// stores it on those studies, so they can still be addressed by date.
+func LegacyStudyID(date string) string {
+	return date
+}
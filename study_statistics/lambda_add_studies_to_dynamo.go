@@ -4,119 +4,284 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"log/slog"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/importjob"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/quota"
+	"veet-code-go/internal/ratelimit"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
 )
 
 type Request struct {
 	Studies []Study `json:"studies"`
+	// WritesPerSecond overrides the write budget, up to maxWritesPerSecond.
+	// It is a pointer so an explicit 0 can be told apart from no override.
+	WritesPerSecond *float64 `json:"writesPerSecond"`
+}
+
+// parseRequest accepts the {"studies": [...]} object or a bare array of studies
+func parseRequest(body string) (Request, error) {
+	var request Request
+	if strings.HasPrefix(strings.TrimSpace(body), "[") {
+		err := json.Unmarshal([]byte(body), &request.Studies)
+		return request, err
+	}
+
+	err := json.Unmarshal([]byte(body), &request)
+	return request, err
 }
 
 type Study struct {
-	StudyTheme   string `json:"theme"`
-	StudyDate    string `json:"date"`
-	StudyMinutes string `json:"minutes"`
+	// ID is assigned on write; any sent by the client is replaced
+	ID           string                 `json:"id,omitempty"`
+	StudyTheme   string                 `json:"theme"`
+	StudyDate    string                 `json:"date"`
+	StudyMinutes studytime.MinutesInput `json:"minutes"`
 }
 
-var dynamoClient *dynamodb.Client
-const tableName = "studies_table"
+var dynamoClient awsutil.DynamoAPI
+var writeLimiter *ratelimit.Limiter
+
+// maxWritesPerSecond caps the budget a request can ask for
+var maxWritesPerSecond float64 = ratelimit.DefaultMaxWritesPerSecond
+
+// writeClock paces the limiter and the backoff between retries
+var writeClock = ratelimit.SystemClock
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
+
+// jobsTableName is empty unless imports should report their progress
+var jobsTableName = awsutil.TableName(importjob.TableEnv, "")
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to load AWS SDK config: %v", err))
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
 
-	dynamoClient = dynamodb.NewFromConfig(cfg)
+	writeLimiter, err = ratelimit.FromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load write rate limit: %v", err)
+	}
+	maxWritesPerSecond, err = ratelimit.MaxFromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load write rate limit: %v", err)
+	}
 }
 
-func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (map[string]interface{}, error) {
+// Handler writes a batch of studies. With ?strict=true it also refuses a
+// batch whose studies of one date add up to more than studytime.MaxMinutes.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	slog.Debug("Raw event", "event", event)
 
-	fmt.Println("Raw Event:", event)
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
 
-	var request Request
-	err := json.Unmarshal([]byte(event.Body), &request)
+	request, err := parseRequest(event.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal request body: %v", err)
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
 	}
 
-	fmt.Println("Received Studies:", request.Studies)
+	if verr := validation.CheckBatch(len(request.Studies)); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
 
-	err = putMultipleItemsToDynamoDB(request.Studies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to add items to DynamoDB: %v", err)
+	now := time.Now()
+	for i := range request.Studies {
+		request.Studies[i].StudyDate = calendar.DateOrToday(request.Studies[i].StudyDate, model.DateLayout, now)
 	}
 
-	successMessage := fmt.Sprintf("%d studies successfully added to DynamoDB.", len(request.Studies))
+	// Validate every study before writing any of them
+	var fields validation.Fields
+	for i, study := range request.Studies {
+		study.validate(&fields, fmt.Sprintf("studies[%d].", i))
+	}
+	if request.WritesPerSecond != nil && *request.WritesPerSecond <= 0 {
+		fields.Add("writesPerSecond", "must be greater than 0")
+	}
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+	if event.QueryStringParameters["strict"] == "true" {
+		validateDailyTotals(&fields, request.Studies)
+		if verr := fields.Err(); verr != nil {
+			return awsutil.JSONResponse(400, verr.Envelope()), nil
+		}
+	}
 
-	headers := map[string]string{
-		"Access-Control-Allow-Origin":      "*",           
-		"Access-Control-Allow-Methods":     "POST, OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+	for i := range request.Studies {
+		request.Studies[i].ID = model.NewStudyID()
 	}
 
-	body, err := json.Marshal(map[string]string{
-		"message": successMessage,
-	})
+	slog.Debug("Received studies", "studies", request.Studies)
+
+	limiter := ratelimit.ForRequest(request.WritesPerSecond, maxWritesPerSecond, writeLimiter, writeClock)
+
+	job := importjob.Start(ctx, dynamoClient, jobsTableName, model.NewStudyID(), len(request.Studies), writeClock.Now)
+	unsaved, err := putMultipleItemsToDynamoDB(ctx, request.Studies, limiter, job)
+	job.Finish(ctx, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response body: %v", err)
+		slog.Error("Failed to add items to DynamoDB", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to add the studies"), nil
+	}
+	metrics.Emit(ctx, metrics.Count(metrics.StudiesWritten, len(request.Studies)-len(unsaved)))
+
+	var warnings quota.Warnings
+	warnings.Check(quota.BatchSize, len(request.Studies))
+
+	isUnsaved := make(map[string]bool, len(unsaved))
+	for _, study := range unsaved {
+		isUnsaved[study.ID] = true
+	}
+	ids := []string{}
+	for _, study := range request.Studies {
+		if !isUnsaved[study.ID] {
+			ids = append(ids, study.ID)
+		}
+	}
+
+	status := 200
+	body := map[string]any{
+		"message": fmt.Sprintf("%d studies successfully added to DynamoDB.", len(request.Studies)),
+		"ids":     ids,
+	}
+	if len(unsaved) > 0 {
+		status = 207
+		body["message"] = fmt.Sprintf("%d of %d studies added to DynamoDB.", len(request.Studies)-len(unsaved), len(request.Studies))
+		body["unsaved"] = unsaved
+	}
+	if job != nil {
+		body["jobId"] = job.ID()
+	}
+	return awsutil.WarnedResponse(status, body, warnings), nil
+}
+
+// validate records missing or out-of-range fields, prefixing field names with prefix
+func (s Study) validate(fields *validation.Fields, prefix string) {
+	fields.Require(prefix+"theme", s.StudyTheme)
+	fields.Require(prefix+"date", s.StudyDate)
+	if s.StudyDate != "" {
+		if err := calendar.CheckNewDate(s.StudyDate, time.Now(), model.DateLayout); err != nil {
+			fields.Add(prefix+"date", err.Error())
+		}
+	}
+	fields.Require(prefix+"minutes", string(s.StudyMinutes))
+	if strings.TrimSpace(string(s.StudyMinutes)) != "" {
+		if _, err := studytime.ParseMinutes(string(s.StudyMinutes)); err != nil {
+			fields.Add(prefix+"minutes", err.Error())
+		}
+	}
+}
+
+// validateDailyTotals rejects dates whose studies in the batch add up to more
+// than a day. Studies already stored for those dates are not counted.
+func validateDailyTotals(fields *validation.Fields, studies []Study) {
+	var dates []string
+	minutesPerDate := make(map[string]int)
+	for _, study := range studies {
+		minutes, _ := studytime.ParseMinutes(string(study.StudyMinutes))
+		if _, ok := minutesPerDate[study.StudyDate]; !ok {
+			dates = append(dates, study.StudyDate)
+		}
+		minutesPerDate[study.StudyDate] += minutes
 	}
 
-	return map[string]interface{}{
-		"statusCode": 200,
-		"headers":    headers,
-		"body": string(body),
-	}, nil
+	for _, date := range dates {
+		if minutesPerDate[date] > studytime.MaxMinutes {
+			fields.Add("studies", fmt.Sprintf("add up to %d minutes on %s, more than the %d in a day", minutesPerDate[date], date, studytime.MaxMinutes))
+		}
+	}
 }
 
-func putMultipleItemsToDynamoDB(studies []Study) error {
+// putMultipleItemsToDynamoDB returns the studies DynamoDB still left
+// unprocessed after every retry, reporting progress to job after each chunk
+func putMultipleItemsToDynamoDB(ctx context.Context, studies []Study, limiter *ratelimit.Limiter, job *importjob.Tracker) ([]Study, error) {
 	var writeRequests []types.WriteRequest
+	unsaved := []Study{}
 
 	for _, study := range studies {
-		minutes, err := strconv.Atoi(study.StudyMinutes)
+		minutes, err := studytime.ParseMinutes(string(study.StudyMinutes))
 		if err != nil {
-			return fmt.Errorf("invalid minutes_of_study: %v", err)
+			return nil, fmt.Errorf("invalid minutes_of_study: %v", err)
+		}
+
+		item := map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: study.StudyTheme},
+			model.IDAttribute:  &types.AttributeValueMemberS{Value: study.ID},
+			"study_date":       &types.AttributeValueMemberS{Value: study.StudyDate},
+			"minutes_of_study": &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
 		}
+		user.Stamp(ctx, item)
 
 		writeRequests = append(writeRequests, types.WriteRequest{
-			PutRequest: &types.PutRequest{
-				Item: map[string]types.AttributeValue{
-					"study_theme":    &types.AttributeValueMemberS{Value: study.StudyTheme},
-					"study_date":     &types.AttributeValueMemberS{Value: study.StudyDate},
-					"minutes_of_study": &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
-				},
-			},
+			PutRequest: &types.PutRequest{Item: item},
 		})
 	}
 
-	// Batch write with a maximum of 25 items per request (DynamoDB limit)
-	const maxBatchSize = 25
-	for i := 0; i < len(writeRequests); i += maxBatchSize {
-		end := i + maxBatchSize
-		if end > len(writeRequests) {
-			end = len(writeRequests)
-		}
-
-		input := &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]types.WriteRequest{
-				tableName: writeRequests[i:end],
-			},
-		}
+	table := tenant.Table(ctx, tableName)
+	for i := 0; i < len(writeRequests); i += awsutil.MaxBatchWriteSize {
+		end := min(i+awsutil.MaxBatchWriteSize, len(writeRequests))
 
-		_, err := dynamoClient.BatchWriteItem(context.TODO(), input)
+		unprocessed, err := awsutil.WriteBatch(ctx, dynamoClient, table, writeRequests[i:end], limiter, writeClock)
 		if err != nil {
-			return fmt.Errorf("failed to batch write items to DynamoDB: %v", err)
+			return nil, err
 		}
+		for _, writeRequest := range unprocessed {
+			unsaved = append(unsaved, studyFromItem(writeRequest.PutRequest.Item))
+		}
+
+		slog.Info("Wrote studies", "written", end, "total", len(writeRequests), "writesPerSecond", limiter.Rate())
+		job.Progress(ctx, end-len(unsaved), len(unsaved), limiter.Rate())
 	}
 
-	return nil
+	return unsaved, nil
+}
+
+// studyFromItem recovers the request shape of an item DynamoDB did not write
+func studyFromItem(item map[string]types.AttributeValue) Study {
+	var study Study
+	if v, ok := item[model.IDAttribute].(*types.AttributeValueMemberS); ok {
+		study.ID = v.Value
+	}
+	if v, ok := item["study_theme"].(*types.AttributeValueMemberS); ok {
+		study.StudyTheme = v.Value
+	}
+	if v, ok := item["study_date"].(*types.AttributeValueMemberS); ok {
+		study.StudyDate = v.Value
+	}
+	if v, ok := item["minutes_of_study"].(*types.AttributeValueMemberN); ok {
+		study.StudyMinutes = studytime.MinutesInput(v.Value)
+	}
+	return study
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
@@ -0,0 +1,63 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+// Run against DynamoDB Local with
+//
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test -tags integration \
+//		lambda_add_studies_to_dynamo.go lambda_add_studies_to_dynamo_integration_test.go
+func TestIntegrationAddStudies(t *testing.T) {
+	client := dynamotest.Local(t, dynamotest.StudiesSchema)
+	dynamoClient = client
+	tableName = dynamotest.StudiesSchema.Table
+	jobsTableName = ""
+	ctx := context.Background()
+
+	// More than one BatchWriteItem chunk, on two themes sharing dates
+	var studies []string
+	for i := 0; i < 30; i++ {
+		theme := []string{"Go", "SQL"}[i%2]
+		studies = append(studies, fmt.Sprintf(`{"theme":%q,"date":"%02d/03/2024","minutes":%d}`, theme, 1+i/2, 10+i))
+	}
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{Body: "[" + strings.Join(studies, ",") + "]"})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+
+	output, err := client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String(tableName)})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(output.Items) != 30 {
+		t.Fatalf("table holds %d studies, want 30", len(output.Items))
+	}
+	minutes := 0
+	for _, item := range output.Items {
+		study := studyFromItem(item)
+		if study.ID == "" {
+			t.Errorf("study %+v stored without an id", study)
+		}
+		n, err := strconv.Atoi(string(study.StudyMinutes))
+		if err != nil {
+			t.Errorf("study %+v stored without whole minutes", study)
+		}
+		minutes += n
+	}
+	if want := 30*10 + 29*30/2; minutes != want {
+		t.Errorf("stored %d minutes, want %d", minutes, want)
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/importjob"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/quota"
+	"veet-code-go/internal/ratelimit"
+	"veet-code-go/internal/validation"
+)
+
+func newStudiesFake() *dynamotest.Fake {
+	fake := dynamotest.New(tableName)
+	fake.Keys = map[string][]string{tableName: {"study_theme", model.IDAttribute}}
+	return fake
+}
+
+func studyRequests(n int) []Study {
+	studies := make([]Study, n)
+	for i := range studies {
+		studies[i] = Study{ID: fmt.Sprintf("id-%03d", i), StudyTheme: "Go", StudyDate: "01/03/2024", StudyMinutes: "30"}
+	}
+	return studies
+}
+
+func TestPutMultipleItemsToDynamoDBChunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		studies    int
+		wantWrites int
+	}{
+		{"single study", 1, 1},
+		{"exactly one chunk", 25, 1},
+		{"chunks of 25", 60, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newStudiesFake()
+			dynamoClient = fake
+
+			unsaved, err := putMultipleItemsToDynamoDB(context.Background(), studyRequests(tt.studies), nil, nil)
+			if err != nil {
+				t.Fatalf("putMultipleItemsToDynamoDB: %v", err)
+			}
+			if len(unsaved) != 0 {
+				t.Errorf("unsaved = %+v, want none", unsaved)
+			}
+			if got := fake.Count("BatchWriteItem"); got != tt.wantWrites {
+				t.Errorf("BatchWriteItem called %d times, want %d", got, tt.wantWrites)
+			}
+			if got := len(fake.Items(tableName)); got != tt.studies {
+				t.Errorf("table holds %d items, want %d", got, tt.studies)
+			}
+		})
+	}
+}
+
+func TestPutMultipleItemsToDynamoDBFailures(t *testing.T) {
+	t.Run("invalid minutes", func(t *testing.T) {
+		fake := newStudiesFake()
+		dynamoClient = fake
+
+		studies := studyRequests(3)
+		studies[1].StudyMinutes = "forever"
+		if _, err := putMultipleItemsToDynamoDB(context.Background(), studies, nil, nil); err == nil {
+			t.Fatal("putMultipleItemsToDynamoDB succeeded, want an error")
+		}
+		if fake.Count("BatchWriteItem") != 0 {
+			t.Error("BatchWriteItem called for an invalid batch")
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		fake := newStudiesFake()
+		fake.Err = func(operation string, _ int) error {
+			if operation == "BatchWriteItem" {
+				return errors.New("boom")
+			}
+			return nil
+		}
+		dynamoClient = fake
+
+		if _, err := putMultipleItemsToDynamoDB(context.Background(), studyRequests(3), nil, nil); err == nil {
+			t.Fatal("putMultipleItemsToDynamoDB succeeded, want an error")
+		}
+	})
+}
+
+// fakeClock records sleeps instead of taking them
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	previous := writeClock
+	writeClock = clock
+	t.Cleanup(func() { writeClock = previous })
+	return clock
+}
+
+// throttleFirst fails the first n batch writes with a throughput error
+func throttleFirst(n int) func(string, int) error {
+	return func(operation string, call int) error {
+		if operation == "BatchWriteItem" && call <= n {
+			return &types.ProvisionedThroughputExceededException{}
+		}
+		return nil
+	}
+}
+
+func TestWriteBatchBacksOffWithoutLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		throttled   int
+		wantSleeps  int
+		wantUnsaved int
+	}{
+		{"no push back", 0, 0, 0},
+		{"throttled twice", 2, 2, 0},
+		{"throttled on every attempt", awsutil.MaxBatchAttempts, awsutil.MaxBatchAttempts - 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := useFakeClock(t)
+			fake := newStudiesFake()
+			fake.Err = throttleFirst(tt.throttled)
+			dynamoClient = fake
+
+			unsaved, err := putMultipleItemsToDynamoDB(context.Background(), studyRequests(3), nil, nil)
+			if err != nil {
+				t.Fatalf("putMultipleItemsToDynamoDB: %v", err)
+			}
+			if len(unsaved) != tt.wantUnsaved {
+				t.Errorf("unsaved %d studies, want %d", len(unsaved), tt.wantUnsaved)
+			}
+			if len(clock.slept) != tt.wantSleeps {
+				t.Fatalf("slept %d times, want %d", len(clock.slept), tt.wantSleeps)
+			}
+			for i, d := range clock.slept {
+				ceiling := min(ratelimit.BaseBackoff<<i, ratelimit.MaxBackoff)
+				if d < ceiling/2 || d > ceiling {
+					t.Errorf("backoff %d = %v, want within [%v, %v]", i+1, d, ceiling/2, ceiling)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteBatchBacksOffOnUnprocessedItems(t *testing.T) {
+	clock := useFakeClock(t)
+	fake := newStudiesFake()
+	fake.Unprocessed = func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		if n == 1 {
+			return map[string][]types.WriteRequest{tableName: requests[tableName][:2]}
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	limiter := ratelimit.NewWithClock(100, clock)
+	unsaved, err := putMultipleItemsToDynamoDB(context.Background(), studyRequests(5), limiter, nil)
+	if err != nil || len(unsaved) != 0 {
+		t.Fatalf("putMultipleItemsToDynamoDB = %+v, %v, want everything written", unsaved, err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] > ratelimit.BaseBackoff {
+		t.Errorf("slept %v, want a single first backoff", clock.slept)
+	}
+	// Halved by the unprocessed items, then a tenth of the budget recovered
+	if got := limiter.Rate(); got != 60 {
+		t.Errorf("rate = %v, want 60", got)
+	}
+}
+
+func TestWriteBatchBackoffHonoursContext(t *testing.T) {
+	useFakeClock(t)
+	fake := newStudiesFake()
+	ctx, cancel := context.WithCancel(context.Background())
+	fake.Err = func(operation string, _ int) error {
+		cancel()
+		return &types.ProvisionedThroughputExceededException{}
+	}
+	dynamoClient = fake
+
+	_, err := putMultipleItemsToDynamoDB(ctx, studyRequests(3), nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if got := fake.Count("BatchWriteItem"); got != 1 {
+		t.Errorf("BatchWriteItem called %d times, want no retry after cancellation", got)
+	}
+}
+
+func TestHandlerReportsJobProgress(t *testing.T) {
+	const jobsTable = "import_jobs_table"
+	useFakeClock(t)
+	jobsTableName = jobsTable
+	t.Cleanup(func() { jobsTableName = "" })
+
+	fake := newStudiesFake()
+	fake.Keys[jobsTable] = []string{importjob.KeyAttribute}
+	// The second chunk is throttled once, halving the rate
+	fake.Err = func(operation string, call int) error {
+		if operation == "BatchWriteItem" && call == 2 {
+			return &types.ProvisionedThroughputExceededException{}
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	body, _ := json.Marshal(Request{Studies: studyRequests(60), WritesPerSecond: aws.Float64(40)})
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var decoded struct {
+		JobID string `json:"jobId"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &decoded); err != nil || decoded.JobID == "" {
+		t.Fatalf("response %s has no jobId", response.Body)
+	}
+
+	type progress struct {
+		status  string
+		written int
+		rate    float64
+	}
+	var got []progress
+	for _, call := range fake.Calls {
+		input, ok := call.Input.(*dynamodb.PutItemInput)
+		if !ok || aws.ToString(input.TableName) != jobsTable {
+			continue
+		}
+		var job importjob.Job
+		if err := attributevalue.UnmarshalMap(input.Item, &job); err != nil {
+			t.Fatalf("unmarshal job: %v", err)
+		}
+		got = append(got, progress{job.Status, job.Written, job.WritesPerSecond})
+	}
+	want := []progress{
+		{importjob.StatusRunning, 0, 0},
+		{importjob.StatusRunning, 25, 40},
+		// Halved by the throttle, then a tenth of the budget recovered
+		{importjob.StatusRunning, 50, 24},
+		{importjob.StatusRunning, 60, 28},
+		{importjob.StatusCompleted, 60, 28},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("job updates = %+v, want %+v", got, want)
+	}
+
+	job, ok, _ := importjob.Load(context.Background(), fake, jobsTable, decoded.JobID)
+	if !ok || job.Status != importjob.StatusCompleted || job.Total != 60 {
+		t.Errorf("stored job = %+v, %v, want the completed job of 60", job, ok)
+	}
+}
+
+func TestHandlerWithoutJobsTable(t *testing.T) {
+	useFakeClock(t)
+	fake := newStudiesFake()
+	dynamoClient = fake
+
+	body, _ := json.Marshal(Request{Studies: studyRequests(2)})
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if strings.Contains(response.Body, "jobId") || fake.Count("PutItem") != 0 {
+		t.Errorf("response %s, %d PutItem calls, want no job tracked", response.Body, fake.Count("PutItem"))
+	}
+}
+
+func TestHandlerWritesPerSecond(t *testing.T) {
+	const jobsTable = "import_jobs_table"
+	tests := []struct {
+		name            string
+		writesPerSecond *float64
+		wantStatus      int
+		wantRate        float64
+	}{
+		{"no override", nil, 200, 0},
+		{"within the cap", aws.Float64(20), 200, 20},
+		{"at the cap", aws.Float64(50), 200, 50},
+		{"above the cap is clamped", aws.Float64(5000), 200, 50},
+		{"zero", aws.Float64(0), 400, 0},
+		{"negative", aws.Float64(-1), 400, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeClock(t)
+			jobsTableName, maxWritesPerSecond = jobsTable, 50
+			t.Cleanup(func() { jobsTableName, maxWritesPerSecond = "", ratelimit.DefaultMaxWritesPerSecond })
+			fake := newStudiesFake()
+			fake.Keys[jobsTable] = []string{importjob.KeyAttribute}
+			dynamoClient = fake
+
+			body, _ := json.Marshal(Request{Studies: studyRequests(3), WritesPerSecond: tt.writesPerSecond})
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Fatalf("Handler = %d %s, %v, want %d", response.StatusCode, response.Body, err, tt.wantStatus)
+			}
+			if tt.wantStatus != 200 {
+				if !strings.Contains(response.Body, "writesPerSecond") || fake.Count("BatchWriteItem") != 0 {
+					t.Errorf("response %s, want a writesPerSecond field error and nothing written", response.Body)
+				}
+				return
+			}
+
+			jobs := fake.Items(jobsTable)
+			if len(jobs) != 1 {
+				t.Fatalf("%d jobs stored, want 1", len(jobs))
+			}
+			var job importjob.Job
+			if err := attributevalue.UnmarshalMap(jobs[0], &job); err != nil {
+				t.Fatalf("unmarshal job: %v", err)
+			}
+			if job.WritesPerSecond != tt.wantRate {
+				t.Errorf("writes per second = %v, want %v", job.WritesPerSecond, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestHandlerRetriesUnprocessedItems(t *testing.T) {
+	useFakeClock(t)
+	fake := newStudiesFake()
+	// DynamoDB hands back a shrinking share of the chunk on the first calls
+	fake.Unprocessed = func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		if pending := requests[tableName]; n <= 2 && len(pending) > 1 {
+			return map[string][]types.WriteRequest{tableName: pending[len(pending)/2:]}
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	body, _ := json.Marshal(Request{Studies: studyRequests(10)})
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if got := fake.Count("BatchWriteItem"); got != 3 {
+		t.Errorf("BatchWriteItem called %d times, want the unprocessed items retried twice", got)
+	}
+	if got := len(fake.Items(tableName)); got != 10 {
+		t.Errorf("table holds %d items, want 10", got)
+	}
+}
+
+func TestHandlerPartialSuccess(t *testing.T) {
+	useFakeClock(t)
+	fake := newStudiesFake()
+	// From the second chunk on, the last study is never processed
+	fake.Unprocessed = func(n int, requests map[string][]types.WriteRequest) map[string][]types.WriteRequest {
+		if pending := requests[tableName]; n >= 2 {
+			return map[string][]types.WriteRequest{tableName: pending[len(pending)-1:]}
+		}
+		return nil
+	}
+	dynamoClient = fake
+
+	studies := studyRequests(30)
+	body, _ := json.Marshal(Request{Studies: studies})
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil || response.StatusCode != 207 {
+		t.Fatalf("Handler = %d %s, %v, want 207", response.StatusCode, response.Body, err)
+	}
+
+	var decoded struct {
+		Message string   `json:"message"`
+		IDs     []string `json:"ids"`
+		Unsaved []Study  `json:"unsaved"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &decoded); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if decoded.Message != "29 of 30 studies added to DynamoDB." {
+		t.Errorf("message = %q", decoded.Message)
+	}
+	if len(decoded.IDs) != 29 || len(decoded.Unsaved) != 1 {
+		t.Fatalf("%d ids and %d unsaved, want 29 and 1", len(decoded.IDs), len(decoded.Unsaved))
+	}
+	unsaved := decoded.Unsaved[0]
+	if unsaved.StudyTheme != "Go" || unsaved.StudyDate != "01/03/2024" || unsaved.StudyMinutes != "30" {
+		t.Errorf("unsaved = %+v, want the study as it was sent", unsaved)
+	}
+	for _, id := range decoded.IDs {
+		if id == unsaved.ID {
+			t.Errorf("unsaved study %s is also listed as saved", id)
+		}
+	}
+	if got := fake.Count("BatchWriteItem"); got != 1+awsutil.MaxBatchAttempts {
+		t.Errorf("BatchWriteItem called %d times, want the first chunk once and the second %d times", got, awsutil.MaxBatchAttempts)
+	}
+}
+
+func TestStudyFromItem(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"study_theme":      &types.AttributeValueMemberS{Value: "Go"},
+		model.IDAttribute:  &types.AttributeValueMemberS{Value: "id-1"},
+		"study_date":       &types.AttributeValueMemberS{Value: "01/03/2024"},
+		"minutes_of_study": &types.AttributeValueMemberN{Value: "30"},
+	}
+	want := Study{ID: "id-1", StudyTheme: "Go", StudyDate: "01/03/2024", StudyMinutes: "30"}
+	if got := studyFromItem(item); got != want {
+		t.Errorf("studyFromItem = %+v, want %+v", got, want)
+	}
+}
+
+// errorFields decodes the error envelope of a response into its code and the
+// names of its offending fields
+func errorFields(t *testing.T, response events.APIGatewayProxyResponse) (string, []string) {
+	t.Helper()
+	var body struct {
+		Error validation.Error `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("invalid error body %s: %v", response.Body, err)
+	}
+	var fields []string
+	for _, field := range body.Error.Fields {
+		fields = append(fields, field.Field)
+	}
+	return body.Error.Code, fields
+}
+
+// TestHandlerEmptyPayloads covers the payloads every write endpoint handles the
+// same way through the validation package
+func TestHandlerEmptyPayloads(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []string
+	}{
+		{"empty", "", validation.CodeEmptyBody, nil},
+		{"blank", " \n\t", validation.CodeEmptyBody, nil},
+		{"null", "null", validation.CodeEmptyBody, nil},
+		{"null with whitespace", " null ", validation.CodeEmptyBody, nil},
+		{"empty object", "{}", validation.CodeEmptyBatch, nil},
+		{"empty array", "[]", validation.CodeEmptyBatch, nil},
+		{"empty studies", `{"studies":[]}`, validation.CodeEmptyBatch, nil},
+		{"array of empty objects", "[{},{}]", validation.CodeValidationFailed, []string{"studies[0].theme", "studies[0].minutes", "studies[1].theme", "studies[1].minutes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newStudiesFake()
+			dynamoClient = fake
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 400 {
+				t.Fatalf("Handler = %d %s, %v, want 400", response.StatusCode, response.Body, err)
+			}
+			code, fields := errorFields(t, response)
+			if code != tt.wantCode || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("error %s %v, want %s %v", code, fields, tt.wantCode, tt.wantFields)
+			}
+			if n := fake.Count("PutItem") + fake.Count("BatchWriteItem"); n != 0 {
+				t.Errorf("%d writes, want none", n)
+			}
+		})
+	}
+}
+
+func TestHandlerBatchSizeWarning(t *testing.T) {
+	useFakeClock(t)
+	tests := []struct {
+		name string
+		size int
+		want string
+	}{
+		{"just below the threshold", 399, ""},
+		{"at the threshold", 400, "batch-size 400/500"},
+		{"at the limit", 500, "batch-size 500/500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamoClient = newStudiesFake()
+			body, _ := json.Marshal(Request{Studies: studyRequests(tt.size)})
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+
+			if got := response.Headers[quota.WarningHeader]; got != tt.want {
+				t.Errorf("%s = %q, want %q", quota.WarningHeader, got, tt.want)
+			}
+			var payload struct {
+				Warnings []string `json:"warnings"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &payload); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+			if !reflect.DeepEqual(payload.Warnings, want) {
+				t.Errorf("warnings = %v, want %v", payload.Warnings, want)
+			}
+		})
+	}
+
+	dynamoClient = newStudiesFake()
+	body, _ := json.Marshal(Request{Studies: studyRequests(501)})
+	response, _ := Handler(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if response.StatusCode != 400 || response.Headers[quota.WarningHeader] != "" {
+		t.Errorf("501 studies = %d with warning %q, want 400 without one", response.StatusCode, response.Headers[quota.WarningHeader])
+	}
+}
@@ -4,100 +4,142 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"log/slog"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
 )
 
 type Request struct {
-	StudyTheme       string   `json:"theme"`
-	StudyDate       string   `json:"date"`
-	StudyMinutes string   `json:"minutes"`
+	StudyTheme   string                 `json:"theme"`
+	StudyDate    string                 `json:"date"`
+	StudyMinutes studytime.MinutesInput `json:"minutes"`
 }
 
-var dynamoClient  *dynamodb.Client
-const tableName = "studies_table"
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to load AWS SDK config: %v", err))
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-
-	dynamoClient = dynamodb.NewFromConfig(cfg)
 }
 
-func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (map[string]interface{}, error) {
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	slog.Debug("Raw event", "event", event)
 
-	fmt.Println("Raw Event:", event)
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
 
 	var request Request
-	err := json.Unmarshal([]byte(event.Body), &request)
+	err = json.Unmarshal([]byte(event.Body), &request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal request body: %v", err)
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+
+	request.StudyDate = calendar.DateOrToday(request.StudyDate, model.DateLayout, time.Now())
+
+	var fields validation.Fields
+	request.validate(&fields, "")
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
 	}
 
-	fmt.Println("Study Theme: ", request.StudyTheme)
-	fmt.Println("Study Date: ", request.StudyDate)
-	fmt.Println("Minutes of Study: ", request.StudyMinutes)
+	slog.Debug("Received study", "theme", request.StudyTheme, "date", request.StudyDate, "minutes", request.StudyMinutes)
 
 	message := fmt.Sprintf("Study Theme: %s, Study Date: %s, Minutes of Study: %s", request.StudyTheme, request.StudyDate, request.StudyMinutes)
-	
-	err = putItemToDynamoDB(request)
+
+	id := model.NewStudyID()
+	err = putItemToDynamoDB(ctx, id, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add item to DynamoDB: %v", err)
+		slog.Error("Failed to add item to DynamoDB", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to add the study"), nil
 	}
+	metrics.Emit(ctx, metrics.Count(metrics.StudiesWritten, 1))
 
 	successMessage := "Study successfully added to DynamoDB."
 	fullMessage := fmt.Sprintf("%s %s", successMessage, message)
 
-	headers := map[string]string{
-		"Access-Control-Allow-Origin":      "*",           
-		"Access-Control-Allow-Methods":     "POST, OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
-	}
-
-	body, err := json.Marshal(map[string]string{
+	return awsutil.JSONResponse(200, map[string]string{
 		"message": fullMessage,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response body: %v", err)
-	}
+		"id":      id,
+	}), nil
+}
 
-	return map[string]interface{}{
-		"statusCode": 200,
-		"headers":    headers,
-		"body": string(body),
-	}, nil
+// validate records missing or out-of-range fields, prefixing field names with prefix
+func (r Request) validate(fields *validation.Fields, prefix string) {
+	fields.Require(prefix+"theme", r.StudyTheme)
+	fields.Require(prefix+"date", r.StudyDate)
+	if r.StudyDate != "" {
+		if err := calendar.CheckNewDate(r.StudyDate, time.Now(), model.DateLayout); err != nil {
+			fields.Add(prefix+"date", err.Error())
+		}
+	}
+	fields.Require(prefix+"minutes", string(r.StudyMinutes))
+	if strings.TrimSpace(string(r.StudyMinutes)) != "" {
+		if _, err := studytime.ParseMinutes(string(r.StudyMinutes)); err != nil {
+			fields.Add(prefix+"minutes", err.Error())
+		}
+	}
 }
 
-func putItemToDynamoDB(request Request) error {
-	minutes, err := strconv.Atoi(request.StudyMinutes)
+// putItemToDynamoDB stores the study under a new id, so further sessions of
+// the same theme and date are kept alongside it
+func putItemToDynamoDB(ctx context.Context, id string, request Request) error {
+	minutes, err := studytime.ParseMinutes(string(request.StudyMinutes))
 	if err != nil {
-    		return fmt.Errorf("invalid minutes_of_study: %v", err)
+		return fmt.Errorf("invalid minutes_of_study: %v", err)
 	}
 
 	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(tenant.Table(ctx, tableName)),
 		Item: map[string]types.AttributeValue{
-			"study_theme":       	&types.AttributeValueMemberS{Value: request.StudyTheme},
-			"study_date": 		&types.AttributeValueMemberS{Value: request.StudyDate},
-			"minutes_of_study":     &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
+			"study_theme":      &types.AttributeValueMemberS{Value: request.StudyTheme},
+			model.IDAttribute:  &types.AttributeValueMemberS{Value: id},
+			"study_date":       &types.AttributeValueMemberS{Value: request.StudyDate},
+			"minutes_of_study": &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
 		},
 	}
 
-	_, err = dynamoClient.PutItem(context.TODO(), input)
+	user.Stamp(ctx, input.Item)
+
+	_, err = dynamoClient.PutItem(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to put item in DynamoDB: %v", err)
+		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
 	}
 	return nil
 }
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
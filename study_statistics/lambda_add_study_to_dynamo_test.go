@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/validation"
+)
+
+func TestPutItemToDynamoDB(t *testing.T) {
+	tests := []struct {
+		name        string
+		minutes     string
+		wantMinutes string
+		wantErr     bool
+	}{
+		{"number", "45", "45", false},
+		{"padded string", " 30 ", "30", false},
+		{"zero", "0", "", true},
+		{"not a number", "half an hour", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName)
+			dynamoClient = fake
+
+			request := Request{StudyTheme: "Go", StudyDate: "01/03/2024", StudyMinutes: studytime.MinutesInput(tt.minutes)}
+			err := putItemToDynamoDB(context.Background(), "id-1", request)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("putItemToDynamoDB succeeded, want an error")
+				}
+				if fake.Count("PutItem") != 0 {
+					t.Error("PutItem called for an invalid study")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("putItemToDynamoDB: %v", err)
+			}
+
+			want := []map[string]types.AttributeValue{{
+				"study_theme":      &types.AttributeValueMemberS{Value: "Go"},
+				"study_id":         &types.AttributeValueMemberS{Value: "id-1"},
+				"study_date":       &types.AttributeValueMemberS{Value: "01/03/2024"},
+				"minutes_of_study": &types.AttributeValueMemberN{Value: tt.wantMinutes},
+			}}
+			if got := fake.Items(tableName); !reflect.DeepEqual(got, want) {
+				t.Errorf("items = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerWritesStudy(t *testing.T) {
+	t.Run("stored under the returned id", func(t *testing.T) {
+		fake := dynamotest.New(tableName)
+		dynamoClient = fake
+
+		response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"theme":"Go","date":"01/03/2024","minutes":"45"}`})
+		if err != nil || response.StatusCode != 200 {
+			t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+		}
+		var body map[string]string
+		if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+			t.Fatalf("invalid body %s: %v", response.Body, err)
+		}
+		items := fake.Items(tableName)
+		if len(items) != 1 || !reflect.DeepEqual(items[0]["study_id"], &types.AttributeValueMemberS{Value: body["id"]}) {
+			t.Errorf("items = %#v, want one with id %q", items, body["id"])
+		}
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		fake := dynamotest.New(tableName)
+		fake.Err = func(string, int) error { return errors.New("boom") }
+		dynamoClient = fake
+
+		response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: `{"theme":"Go","date":"01/03/2024","minutes":45}`})
+		if err != nil {
+			t.Fatalf("Handler: %v", err)
+		}
+		if response.StatusCode != 500 {
+			t.Errorf("status = %d, want 500", response.StatusCode)
+		}
+	})
+}
+
+// errorFields decodes the error envelope of a response into its code and the
+// names of its offending fields
+func errorFields(t *testing.T, response events.APIGatewayProxyResponse) (string, []string) {
+	t.Helper()
+	var body struct {
+		Error validation.Error `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("invalid error body %s: %v", response.Body, err)
+	}
+	var fields []string
+	for _, field := range body.Error.Fields {
+		fields = append(fields, field.Field)
+	}
+	return body.Error.Code, fields
+}
+
+// TestHandlerEmptyPayloads covers the payloads every write endpoint handles the
+// same way through the validation package
+func TestHandlerEmptyPayloads(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []string
+	}{
+		{"empty", "", validation.CodeEmptyBody, nil},
+		{"blank", " \n\t", validation.CodeEmptyBody, nil},
+		{"null", "null", validation.CodeEmptyBody, nil},
+		{"null with whitespace", " null ", validation.CodeEmptyBody, nil},
+		{"empty object", "{}", validation.CodeValidationFailed, []string{"theme", "minutes"}},
+		{"array", "[]", awsutil.CodeInvalidBody, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName)
+			dynamoClient = fake
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != 400 {
+				t.Fatalf("Handler = %d %s, %v, want 400", response.StatusCode, response.Body, err)
+			}
+			code, fields := errorFields(t, response)
+			if code != tt.wantCode || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("error %s %v, want %s %v", code, fields, tt.wantCode, tt.wantFields)
+			}
+			if n := fake.Count("PutItem") + fake.Count("BatchWriteItem"); n != 0 {
+				t.Errorf("%d writes, want none", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler deletes the study identified by ?theme=&id=. Studies from before
+// ids can be named by ?date= instead, which is what their id is. The optional
+// ?minutes= only deletes it when the stored minutes match, guarding against
+// removing a record that changed since the client read it.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	theme := event.QueryStringParameters["theme"]
+	id := event.QueryStringParameters["id"]
+	if date := event.QueryStringParameters["date"]; id == "" && date != "" {
+		id = model.LegacyStudyID(date)
+	}
+	minutesValue := event.QueryStringParameters["minutes"]
+
+	var fields validation.Fields
+	fields.Require("theme", theme)
+	fields.Require("id", id)
+	minutes := 0
+	if minutesValue != "" {
+		if minutes, err = studytime.ParseMinutes(minutesValue); err != nil {
+			fields.Add("minutes", err.Error())
+		}
+	}
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	err = deleteItemFromDynamoDB(ctx, theme, id, minutes)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, fmt.Sprintf("no study of %q with id %s matched", theme, id)), nil
+	}
+	if err != nil {
+		slog.Error("Failed to delete item from DynamoDB", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to delete the study"), nil
+	}
+
+	slog.Info("Study deleted", "theme", theme, "id", id)
+	return awsutil.JSONResponse(200, map[string]string{
+		"message": fmt.Sprintf("Study successfully deleted from DynamoDB. Study Theme: %s, Study ID: %s", theme, id),
+	}), nil
+}
+
+// deleteItemFromDynamoDB fails with ConditionalCheckFailedException when no
+// study of the user has the key, or when minutes is set and differs from the
+// stored value
+func deleteItemFromDynamoDB(ctx context.Context, theme, id string, minutes int) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+		Key: map[string]types.AttributeValue{
+			"study_theme":     &types.AttributeValueMemberS{Value: theme},
+			model.IDAttribute: &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression: aws.String("attribute_exists(study_theme)"),
+	}
+	if minutes > 0 {
+		// Legacy items keep the minutes as a String, which never equals a Number
+		input.ConditionExpression = aws.String("attribute_exists(study_theme) AND (minutes_of_study = :minutes OR minutes_of_study = :legacyMinutes)")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":minutes":       &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
+			":legacyMinutes": &types.AttributeValueMemberS{Value: strconv.Itoa(minutes)},
+		}
+	}
+
+	user.ScopeDelete(ctx, input)
+
+	_, err := dynamoClient.DeleteItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete item from DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
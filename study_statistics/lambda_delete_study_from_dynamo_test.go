@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+func TestDeleteItemFromDynamoDBMinutesCondition(t *testing.T) {
+	tests := []struct {
+		name          string
+		minutes       int
+		wantCondition string
+		wantValues    map[string]types.AttributeValue
+	}{
+		{"without minutes", 0, "attribute_exists(study_theme)", nil},
+		{"with minutes, stored as a Number or a legacy String", 30,
+			"attribute_exists(study_theme) AND (minutes_of_study = :minutes OR minutes_of_study = :legacyMinutes)",
+			map[string]types.AttributeValue{
+				":minutes":       &types.AttributeValueMemberN{Value: "30"},
+				":legacyMinutes": &types.AttributeValueMemberS{Value: "30"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName)
+			dynamoClient = fake
+
+			if err := deleteItemFromDynamoDB(context.Background(), "Go", "01/03/2024", tt.minutes); err != nil {
+				t.Fatalf("deleteItemFromDynamoDB: %v", err)
+			}
+			input := fake.Calls[0].Input.(*dynamodb.DeleteItemInput)
+			if got := aws.ToString(input.ConditionExpression); got != tt.wantCondition {
+				t.Errorf("condition = %q, want %q", got, tt.wantCondition)
+			}
+			if !reflect.DeepEqual(input.ExpressionAttributeValues, tt.wantValues) {
+				t.Errorf("values = %#v, want %#v", input.ExpressionAttributeValues, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestHandlerDeletesByLegacyDate(t *testing.T) {
+	fake := dynamotest.New(tableName, map[string]types.AttributeValue{
+		"study_theme": &types.AttributeValueMemberS{Value: "Go"},
+		"study_id":    &types.AttributeValueMemberS{Value: "01/03/2024"},
+	})
+	dynamoClient = fake
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"theme": "Go", "date": "01/03/2024"},
+	})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	if got := len(fake.Items(tableName)); got != 0 {
+		t.Errorf("table holds %d items, want the study deleted", got)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/anomaly"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+)
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
+
+var dynamoClient awsutil.DynamoAPI
+
+type StudyRecord struct {
+	Date    string            `json:"date" dynamodbav:"study_date"`
+	Theme   string            `json:"theme" dynamodbav:"study_theme"`
+	Minutes studytime.Minutes `json:"minutes" dynamodbav:"minutes_of_study"`
+}
+
+type Anomaly struct {
+	Date      string        `json:"date"`
+	Minutes   int           `json:"minutes"`
+	Threshold float64       `json:"threshold"`
+	Severity  string        `json:"severity"`
+	Studies   []StudyRecord `json:"studies"`
+}
+
+type Report struct {
+	K         float64   `json:"k"`
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+var defaultK float64
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+
+	defaultK, err = anomaly.KFromEnv()
+	if err != nil {
+		log.Fatalf("Unable to load anomaly threshold: %v", err)
+	}
+}
+
+// Handler lists days with suspicious study minutes, along with the studies
+// recorded on them. ?k= overrides the threshold multiplier.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	k := defaultK
+	if value := event.QueryStringParameters["k"]; value != "" {
+		k, err = anomaly.ParseK(value)
+		if err != nil {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+		}
+	}
+
+	records, err := fetchStudyRecords(ctx)
+	if err != nil {
+		slog.Error("Failed to fetch records", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	return awsutil.JSONResponse(200, detectAnomalies(records, k)), nil
+}
+
+// fetchStudyRecords scans DynamoDB and returns a list of StudyRecord
+func fetchStudyRecords(ctx context.Context) ([]StudyRecord, error) {
+	var records []StudyRecord
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+	}
+	user.ScopeScan(ctx, input)
+
+	start := time.Now()
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		var pageRecords []StudyRecord
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageRecords)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
+		}
+
+		records = append(records, pageRecords...)
+	}
+
+	metrics.Scan(ctx, start, len(records))
+	return records, nil
+}
+
+func detectAnomalies(records []StudyRecord, k float64) Report {
+	studiesPerDay := make(map[time.Time][]StudyRecord)
+	minutes := make(map[time.Time]int)
+	for _, record := range records {
+		date, err := calendar.ParseDate(record.Date)
+		if err != nil {
+			slog.Warn("Skipping study with invalid date", "date", record.Date, "error", err)
+			continue
+		}
+		studiesPerDay[date] = append(studiesPerDay[date], record)
+		minutes[date] += int(record.Minutes)
+	}
+
+	var days []anomaly.Day
+	for date, total := range minutes {
+		days = append(days, anomaly.Day{Date: date, Value: total})
+	}
+
+	report := Report{K: k, Anomalies: []Anomaly{}}
+	for _, finding := range anomaly.Detect(days, k, studytime.MaxMinutes) {
+		report.Anomalies = append(report.Anomalies, Anomaly{
+			Date:      finding.Date.Format(model.DateLayout),
+			Minutes:   finding.Value,
+			Threshold: finding.Threshold,
+			Severity:  finding.Severity,
+			Studies:   studiesPerDay[finding.Date],
+		})
+	}
+	return report
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
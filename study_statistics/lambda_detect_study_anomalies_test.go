@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"veet-code-go/internal/anomaly"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+)
+
+// steadyStudies is one study a day from March 2024, around 30 minutes each
+func steadyStudies(days int) []StudyRecord {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	records := make([]StudyRecord, days)
+	for i := range records {
+		records[i] = StudyRecord{
+			Date:    start.AddDate(0, 0, i).Format(model.DateLayout),
+			Theme:   "go",
+			Minutes: studytime.Minutes([]int{28, 30, 32}[i%3]),
+		}
+	}
+	return records
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	// Each study is possible on its own; the days they add up to are not
+	high := []StudyRecord{
+		{Date: "21/03/2024", Theme: "go", Minutes: 120},
+		{Date: "21/03/2024", Theme: "sql", Minutes: 180},
+	}
+	impossible := []StudyRecord{
+		{Date: "25/03/2024", Theme: "go", Minutes: 600},
+		{Date: "25/03/2024", Theme: "sql", Minutes: 600},
+		{Date: "25/03/2024", Theme: "rust", Minutes: 300},
+	}
+	records := append(steadyStudies(20), impossible...)
+	records = append(records, high...)
+	records = append(records, StudyRecord{Date: "2024/03/22", Theme: "go", Minutes: 5000})
+
+	report := detectAnomalies(records, 5)
+	if report.K != 5 {
+		t.Errorf("k = %v, want 5", report.K)
+	}
+	want := []Anomaly{
+		{Date: "21/03/2024", Minutes: 300, Threshold: 40, Severity: anomaly.SeverityHigh, Studies: high},
+		{Date: "25/03/2024", Minutes: 1500, Threshold: studytime.MaxMinutes, Severity: anomaly.SeverityImpossible, Studies: impossible},
+	}
+	if !reflect.DeepEqual(report.Anomalies, want) {
+		t.Errorf("anomalies = %+v, want %+v", report.Anomalies, want)
+	}
+}
+
+func TestDetectAnomaliesSteady(t *testing.T) {
+	report := detectAnomalies(steadyStudies(30), anomaly.DefaultK)
+	if report.Anomalies == nil || len(report.Anomalies) != 0 {
+		t.Errorf("anomalies = %+v, want an empty list", report.Anomalies)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/tenant"
+)
+
+type Request struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type Failure struct {
+	StudyTheme string `json:"theme"`
+	StudyDate  string `json:"date"`
+	Error      string `json:"error"`
+}
+
+type Report struct {
+	DryRun   bool      `json:"dryRun"`
+	Migrated int       `json:"migrated"`
+	Skipped  int       `json:"skipped"`
+	Failed   int       `json:"failed"`
+	Failures []Failure `json:"failures"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
+
+// legacyTableEnv names the table keyed by study_theme and study_date to copy
+// studies from. It has no default: it can't be the table being migrated to.
+const legacyTableEnv = "LEGACY_STUDIES_TABLE_NAME"
+
+var legacyTableName = awsutil.TableName(legacyTableEnv, "")
+
+func init() {
+	if legacyTableName == "" || legacyTableName == tableName {
+		log.Fatalf("%s must name the table to migrate studies from, other than %s", legacyTableEnv, tableName)
+	}
+
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler copies every study of the legacy table, keyed by theme and date,
+// into the table keyed by theme and model.IDAttribute. Studies without an id
+// get model.LegacyStudyID; studies already in the new table are skipped, so
+// running it twice is safe. The legacy table is left as it is.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	var request Request
+	if event.Body != "" {
+		if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
+			slog.Warn("Failed to unmarshal request body", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+		}
+	}
+
+	report, err := migrateStudies(ctx, request.DryRun)
+	if err != nil {
+		slog.Error("Failed to migrate studies", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to migrate studies"), nil
+	}
+
+	return awsutil.JSONResponse(200, report), nil
+}
+
+func migrateStudies(ctx context.Context, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Failures: []Failure{}}
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tenant.Table(ctx, legacyTableName)),
+	}
+
+	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := awsutil.NextPage(ctx, paginator)
+		if err != nil {
+			return report, fmt.Errorf("failed to scan DynamoDB: %w", err)
+		}
+
+		for _, item := range page.Items {
+			theme, date := "", ""
+			if v, ok := item["study_theme"].(*types.AttributeValueMemberS); ok {
+				theme = v.Value
+			}
+			if v, ok := item["study_date"].(*types.AttributeValueMemberS); ok {
+				date = v.Value
+			}
+			if _, ok := item[model.IDAttribute]; !ok {
+				item[model.IDAttribute] = &types.AttributeValueMemberS{Value: model.LegacyStudyID(date)}
+			}
+
+			err = nil
+			if !dryRun {
+				err = copyStudy(ctx, item)
+			}
+
+			var conditionFailed *types.ConditionalCheckFailedException
+			switch {
+			case errors.As(err, &conditionFailed):
+				report.Skipped++
+			case err != nil:
+				slog.Error("Failed to migrate study", "theme", theme, "date", date, "error", err)
+				report.Failed++
+				report.Failures = append(report.Failures, Failure{StudyTheme: theme, StudyDate: date, Error: err.Error()})
+			default:
+				report.Migrated++
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// copyStudy puts the item into the new table unless a study with its theme
+// and id is there already, failing with ConditionalCheckFailedException then
+func copyStudy(ctx context.Context, item map[string]types.AttributeValue) error {
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(tenant.Table(ctx, tableName)),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(study_theme)"),
+	}
+
+	_, err := dynamoClient.PutItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to put item in DynamoDB: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(Handler))))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/importjob"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultJobsTableName = "import_jobs_table"
+
+var jobsTableName = awsutil.TableName(importjob.TableEnv, defaultJobsTableName)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler returns the progress of the bulk import identified by ?id=, the
+// jobId the import responded with. Jobs are scoped to their user, so another
+// user's job is reported as not found.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	id := event.QueryStringParameters["id"]
+	var fields validation.Fields
+	fields.Require("id", id)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	job, ok, err := importjob.Load(ctx, dynamoClient, jobsTableName, id)
+	if err != nil {
+		slog.Error("Failed to load import job", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to load the import job"), nil
+	}
+	if !ok {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, fmt.Sprintf("no import job with id %s", id)), nil
+	}
+
+	return awsutil.JSONResponse(200, job), nil
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -2,28 +2,46 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
+	"os"
 	"sort"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
 )
 
-const tableName = "studies_table"
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
 
-var dynamoClient *dynamodb.Client
+// statsCache survives between invocations of a warm container
+var statsCache = cache.FromEnv()
+
+var dynamoClient awsutil.DynamoAPI
 
 type StudyRecord struct {
-	Date    string `json:"date" dynamodbav:"study_date"`
-	Theme   string `json:"theme" dynamodbav:"study_theme"`
-	Minutes int    `json:"minutes" dynamodbav:"minutes_of_study"`
+	Date    string            `json:"date" dynamodbav:"study_date"`
+	Theme   string            `json:"theme" dynamodbav:"study_theme"`
+	Minutes studytime.Minutes `json:"minutes" dynamodbav:"minutes_of_study"`
 }
 
 type DayStatistic struct {
@@ -32,69 +50,132 @@ type DayStatistic struct {
 	Themes  map[string]int `json:"themes"`
 }
 
+// AverageStatistic is the mean daily minutes over the window ending at Date
+type AverageStatistic struct {
+	Date    string  `json:"date"`
+	Minutes float64 `json:"minutes"`
+}
+
+// CumulativeStatistic is the running total of minutes up to and including Date
+type CumulativeStatistic struct {
+	Date    string `json:"date"`
+	Minutes int    `json:"minutes"`
+}
+
 type Statistics struct {
-	TotalMinutesStudied   int                          `json:"totalMinutesStudied"`
-	TotalMinutesPerDay    []DayStatistic               `json:"totalMinutesPerDay"`
-	MinutesPerThemePerDay map[string]map[string]int    `json:"minutesPerThemePerDay"`
+	TotalMinutesStudied     int                   `json:"totalMinutesStudied"`
+	MinutesPerDay           []DayStatistic        `json:"minutesPerDay"`
+	CumulativeMinutesPerDay []CumulativeStatistic `json:"cumulativeMinutesPerDay"`
+	// MinutesPerWeek sums MinutesPerDay by ISO week, dated with the week's
+	// Monday. Weeks without studies are left out.
+	MinutesPerWeek []DayStatistic `json:"minutesPerWeek"`
+	// SevenDayMovingAverageMinutes covers every calendar day of the range
+	SevenDayMovingAverageMinutes []AverageStatistic        `json:"sevenDayMovingAverageMinutes"`
+	MinutesPerThemePerDay        map[string]map[string]int `json:"minutesPerThemePerDay"`
+	// CumulativeMinutesPerTheme is a date-ordered running total per theme
+	CumulativeMinutesPerTheme map[string][]CumulativeStatistic `json:"cumulativeMinutesPerTheme"`
+	WeekendSplit              WeekendSplit                     `json:"weekendSplit"`
+	// AverageMinutesPerCalendarDay also counts the days without studies
+	// between the first and last record. Both averages are 0 without records.
+	AverageMinutesPerActiveDay   float64 `json:"averageMinutesPerActiveDay"`
+	AverageMinutesPerCalendarDay float64 `json:"averageMinutesPerCalendarDay"`
+	// MinutesPerWeekday sums every record of the range by weekday, Monday to
+	// Sunday, zero-filled
+	MinutesPerWeekday map[string]int `json:"minutesPerWeekday"`
+	// RecordsSkipped counts records left out of the averages and weekdays
+	// because their date did not parse
+	RecordsSkipped int `json:"recordsSkipped"`
+	// MergedKeys lists the raw theme spellings folded into each canonical theme
+	MergedKeys map[string][]string `json:"mergedKeys"`
 }
 
+// WeekendSplit compares study time on weekdays and weekends. Averages are per
+// active day unless inactive days were requested.
+type WeekendSplit struct {
+	AverageWeekdayMinutes float64                 `json:"averageWeekdayMinutes"`
+	AverageWeekendMinutes float64                 `json:"averageWeekendMinutes"`
+	WeekendPercentage     float64                 `json:"weekendPercentage"`
+	PerTheme              map[string]WeekendSplit `json:"perTheme,omitempty"`
+}
+
+var weekendDays map[time.Weekday]bool
+
+// themeAliases come from THEME_ALIASES
+var themeAliases map[string]string
+
 func init() {
+	var err error
+	themeAliases, err = alias.Parse(os.Getenv(alias.EnvThemeAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvThemeAliases, err)
+	}
+
 	// Initialize DynamoDB client
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-	dynamoClient = dynamodb.NewFromConfig(cfg)
+
+	weekendDays, err = calendar.WeekendDays()
+	if err != nil {
+		log.Fatalf("Unable to load weekend days: %v", err)
+	}
 }
 
 // Handler processes the incoming event and returns the statistics
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	cacheKey := cache.Key(ctx, event)
+	if cached, ok := statsCache.Get(cacheKey, event); ok {
+		return awsutil.Compress(event, awsutil.WithETag(event, cached)), nil
+	}
+
+	dateRange, err := calendar.DateRangeFromQuery(event.QueryStringParameters)
+	if err != nil {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+	}
+
 	// Fetch study records from DynamoDB
 	records, err := fetchStudyRecords(ctx)
 	if err != nil {
-		log.Printf("Failed to fetch records: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
+		slog.Error("Failed to fetch records", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
 	}
 
+	records = filterRecords(records, dateRange)
+
 	// Generate statistics from records
-	stats := generateStatistics(records)
+	includeInactiveDays := event.QueryStringParameters["includeInactiveDays"] == "true"
+	start := time.Now()
+	_, segment := tracing.Start(ctx, "generateStatistics")
+	stats := generateStatistics(records, includeInactiveDays)
+	segment.End(nil)
+	metrics.Emit(ctx, metrics.Duration(metrics.StatsGenerationMs, time.Since(start)))
 
 	// Marshal statistics into JSON response
-	responseBody, err := json.Marshal(stats)
-	if err != nil {
-		log.Printf("Failed to marshal response: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
-	}
-
-	// Return the API response
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Headers: map[string]string{
-			"Content-Type":                   "application/json",
-			"Access-Control-Allow-Origin":    "*",
-			"Access-Control-Allow-Methods":   "GET, OPTIONS",
-			"Access-Control-Allow-Headers":   "Content-Type, Authorization",
-		},
-		Body: string(responseBody),
-	}, nil
+	response := statsCache.Put(cacheKey, awsutil.JSONResponse(200, stats))
+	return awsutil.Compress(event, awsutil.WithETag(event, response)), nil
 }
 
 // fetchStudyRecords scans DynamoDB and returns a list of StudyRecord
-func fetchStudyRecords(ctx context.Context) ([]StudyRecord, error) {
-	var records []StudyRecord
+func fetchStudyRecords(ctx context.Context) (records []StudyRecord, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchStudyRecords")
+	defer func() { segment.End(err) }()
+
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(tenant.Table(ctx, tableName)),
 	}
+	user.ScopeScan(ctx, input)
 
+	start := time.Now()
 	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		page, err := awsutil.NextPage(ctx, paginator)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
 		}
@@ -108,24 +189,32 @@ func fetchStudyRecords(ctx context.Context) ([]StudyRecord, error) {
 		records = append(records, pageRecords...)
 	}
 
+	metrics.Scan(ctx, start, len(records))
 	return records, nil
 }
 
 // generateStatistics processes the study records and calculates statistics
-func generateStatistics(records []StudyRecord) Statistics {
-	// Sort records by date
-	sort.Slice(records, func(i, j int) bool {
-		dateI, _ := time.Parse("02/01/2006", records[i].Date)
-		dateJ, _ := time.Parse("02/01/2006", records[j].Date)
+func generateStatistics(records []StudyRecord, includeInactiveDays bool) Statistics {
+	// Sort records by date, keeping input order within a day
+	sort.SliceStable(records, func(i, j int) bool {
+		dateI, _ := calendar.ParseDate(records[i].Date)
+		dateJ, _ := calendar.ParseDate(records[j].Date)
 		return dateI.Before(dateJ)
 	})
 
 	// Prepare data structures for statistics
 	themeMinutes := make(map[string]int)
 	minutesPerThemePerDay := make(map[string]map[string]int)
-	totalMinutesPerDay := []DayStatistic{}
+	cumulativeMinutesPerTheme := make(map[string][]CumulativeStatistic)
+	minutesPerDay := []DayStatistic{}
 	totalMinutesStudied := 0
 
+	// Themes are counted by canonical name
+	themes := alias.NewCanonicalizer(themeAliases)
+	for i := range records {
+		records[i].Theme = themes.Canonical(records[i].Theme)
+	}
+
 	// Process records to generate statistics
 	for _, record := range records {
 		// Ensure theme is initialized in the minutes per theme per day map
@@ -133,56 +222,272 @@ func generateStatistics(records []StudyRecord) Statistics {
 			minutesPerThemePerDay[record.Theme] = make(map[string]int)
 		}
 
-		// Update cumulative total for the theme
-		themeMinutes[record.Theme] += record.Minutes
-		minutesPerThemePerDay[record.Theme][record.Date] = themeMinutes[record.Theme]
+		// Only this day's minutes; the running total goes into its own series
+		minutesPerThemePerDay[record.Theme][record.Date] += int(record.Minutes)
+		themeMinutes[record.Theme] += int(record.Minutes)
+		addToCumulativeMinutes(cumulativeMinutesPerTheme, record, themeMinutes[record.Theme])
 
-		// Add to total minutes for the day or create a new entry
-		addToTotalMinutesPerDay(&totalMinutesPerDay, record)
+		// Add to the minutes of the day or create a new entry
+		addToMinutesPerDay(&minutesPerDay, record)
 
 		// Update global total minutes studied
-		totalMinutesStudied += record.Minutes
+		totalMinutesStudied += int(record.Minutes)
 	}
 
+	// Running total over the per-day sums
+	cumulativeMinutesPerDay := []CumulativeStatistic{}
+	runningTotal := 0
+	for _, day := range minutesPerDay {
+		runningTotal += day.Minutes
+		cumulativeMinutesPerDay = append(cumulativeMinutesPerDay, CumulativeStatistic{Date: day.Date, Minutes: runningTotal})
+	}
+
+	perActiveDay, perCalendarDay, perWeekday, skipped := dailyPatterns(records)
+
 	// Return the statistics
 	return Statistics{
-		TotalMinutesStudied:   totalMinutesStudied,
-		TotalMinutesPerDay:    totalMinutesPerDay,
-		MinutesPerThemePerDay: minutesPerThemePerDay,
+		TotalMinutesStudied:          totalMinutesStudied,
+		MinutesPerDay:                minutesPerDay,
+		CumulativeMinutesPerDay:      cumulativeMinutesPerDay,
+		MinutesPerWeek:               minutesPerWeek(minutesPerDay),
+		SevenDayMovingAverageMinutes: movingAverage(minutesPerDay, 7),
+		MinutesPerThemePerDay:        minutesPerThemePerDay,
+		CumulativeMinutesPerTheme:    cumulativeMinutesPerTheme,
+		WeekendSplit:                 weekendSplit(records, includeInactiveDays),
+		AverageMinutesPerActiveDay:   perActiveDay,
+		AverageMinutesPerCalendarDay: perCalendarDay,
+		MinutesPerWeekday:            perWeekday,
+		RecordsSkipped:               skipped,
+		MergedKeys:                   themes.MergedKeys(),
+	}
+}
+
+// minutesPerWeek relies on days being sorted by date. Days whose date does
+// not parse are left out, as in the other date-based series.
+func minutesPerWeek(days []DayStatistic) []DayStatistic {
+	weeks := []DayStatistic{}
+	for _, day := range days {
+		date, err := calendar.ParseDate(day.Date)
+		if err != nil {
+			continue
+		}
+		monday := date.AddDate(0, 0, -(int(date.Weekday())+6)%7).Format(model.DateLayout)
+
+		if len(weeks) == 0 || weeks[len(weeks)-1].Date != monday {
+			weeks = append(weeks, DayStatistic{Date: monday, Themes: make(map[string]int)})
+		}
+		week := &weeks[len(weeks)-1]
+		week.Minutes += day.Minutes
+		for theme, minutes := range day.Themes {
+			week.Themes[theme] += minutes
+		}
+	}
+	return weeks
+}
+
+// dailyPatterns averages the minutes per day with records and per calendar
+// day from the first record to the last, and sums them by weekday. Records
+// with an unparseable date are counted as skipped instead.
+func dailyPatterns(records []StudyRecord) (perActiveDay, perCalendarDay float64, perWeekday map[string]int, skipped int) {
+	perWeekday = make(map[string]int, 7)
+	for day := time.Monday; day < time.Monday+7; day++ {
+		perWeekday[(day % 7).String()] = 0
+	}
+
+	activeDays := make(map[time.Time]bool)
+	var first, last time.Time
+	total := 0
+	for _, record := range records {
+		date, err := calendar.ParseDate(record.Date)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+		activeDays[date] = true
+		total += int(record.Minutes)
+		perWeekday[date.Weekday().String()] += int(record.Minutes)
+	}
+
+	if len(activeDays) > 0 {
+		perActiveDay = math.Round(float64(total)/float64(len(activeDays))*100) / 100
+		calendarDays := calendar.DaysBetween(first, last) + 1
+		perCalendarDay = math.Round(float64(total)/float64(calendarDays)*100) / 100
+	}
+	return perActiveDay, perCalendarDay, perWeekday, skipped
+}
+
+// weekendSplit splits the minutes of every record, and of every theme, into
+// weekday and weekend buckets. With includeInactiveDays, every calendar day
+// between the first and last record counts towards the averages.
+func weekendSplit(records []StudyRecord, includeInactiveDays bool) WeekendSplit {
+	minutesPerDay := make(map[time.Time]int)
+	minutesPerThemePerDay := make(map[string]map[time.Time]int)
+	var first, last time.Time
+
+	for _, record := range records {
+		date, err := calendar.ParseDate(record.Date)
+		if err != nil {
+			slog.Warn("Skipping study with invalid date", "date", record.Date, "error", err)
+			continue
+		}
+
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+
+		if _, ok := minutesPerThemePerDay[record.Theme]; !ok {
+			minutesPerThemePerDay[record.Theme] = make(map[time.Time]int)
+		}
+		minutesPerDay[date] += int(record.Minutes)
+		minutesPerThemePerDay[record.Theme][date] += int(record.Minutes)
 	}
+
+	var allDays []time.Time
+	if includeInactiveDays && !first.IsZero() {
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			allDays = append(allDays, day)
+		}
+	}
+
+	split := splitMinutes(minutesPerDay, allDays)
+	split.PerTheme = make(map[string]WeekendSplit)
+	for theme, themeMinutesPerDay := range minutesPerThemePerDay {
+		split.PerTheme[theme] = splitMinutes(themeMinutesPerDay, allDays)
+	}
+	return split
 }
 
-func addToTotalMinutesPerDay(totalMinutesPerDay *[]DayStatistic, record StudyRecord) {
-	// Check if the date already exists in the totalMinutesPerDay slice
-	var found bool
-	for i := range *totalMinutesPerDay {
-		if (*totalMinutesPerDay)[i].Date == record.Date {
-			// Add the current day's minutes to the total of the previous day
-			(*totalMinutesPerDay)[i].Minutes += record.Minutes
-			(*totalMinutesPerDay)[i].Themes[record.Theme] += record.Minutes
-			found = true
-			break
+// splitMinutes averages over allDays when given, otherwise over the days
+// with any minutes
+func splitMinutes(minutesPerDay map[time.Time]int, allDays []time.Time) WeekendSplit {
+	days := allDays
+	if days == nil {
+		for day, minutes := range minutesPerDay {
+			if minutes > 0 {
+				days = append(days, day)
+			}
 		}
 	}
 
-	// If not found, create a new DayStatistic entry
-	if !found {
-		// If there's a previous day, add its minutes to the current day's total
-		var previousDayMinutes int
-		if len(*totalMinutesPerDay) > 0 {
-			previousDayMinutes = (*totalMinutesPerDay)[len(*totalMinutesPerDay)-1].Minutes
+	var weekdayMinutes, weekendMinutes, weekdayCount, weekendCount int
+	for _, day := range days {
+		if weekendDays[day.Weekday()] {
+			weekendMinutes += minutesPerDay[day]
+			weekendCount++
+		} else {
+			weekdayMinutes += minutesPerDay[day]
+			weekdayCount++
 		}
+	}
 
-		// Add the current day's minutes to the previous day's total
-		*totalMinutesPerDay = append(*totalMinutesPerDay, DayStatistic{
-			Date:    record.Date,
-			Minutes: previousDayMinutes + record.Minutes,
-			Themes:  map[string]int{record.Theme: record.Minutes},
-		})
+	var split WeekendSplit
+	if weekdayCount > 0 {
+		split.AverageWeekdayMinutes = roundToTenth(float64(weekdayMinutes) / float64(weekdayCount))
+	}
+	if weekendCount > 0 {
+		split.AverageWeekendMinutes = roundToTenth(float64(weekendMinutes) / float64(weekendCount))
 	}
+	if total := weekdayMinutes + weekendMinutes; total > 0 {
+		split.WeekendPercentage = roundToTenth(float64(weekendMinutes) * 100 / float64(total))
+	}
+	return split
+}
+
+func roundToTenth(value float64) float64 {
+	return math.Round(value*10) / 10
 }
 
+// addToMinutesPerDay adds the record to its day's entry, which holds only that
+// day's minutes
+func addToMinutesPerDay(minutesPerDay *[]DayStatistic, record StudyRecord) {
+	for i := range *minutesPerDay {
+		if (*minutesPerDay)[i].Date == record.Date {
+			(*minutesPerDay)[i].Minutes += int(record.Minutes)
+			(*minutesPerDay)[i].Themes[record.Theme] += int(record.Minutes)
+			return
+		}
+	}
+
+	*minutesPerDay = append(*minutesPerDay, DayStatistic{
+		Date:    record.Date,
+		Minutes: int(record.Minutes),
+		Themes:  map[string]int{record.Theme: int(record.Minutes)},
+	})
+}
+
+// addToCumulativeMinutes sets the theme's running total for the record's day,
+// relying on records being sorted by date
+func addToCumulativeMinutes(cumulative map[string][]CumulativeStatistic, record StudyRecord, total int) {
+	series := cumulative[record.Theme]
+	if last := len(series) - 1; last >= 0 && series[last].Date == record.Date {
+		series[last].Minutes = total
+		return
+	}
+	cumulative[record.Theme] = append(series, CumulativeStatistic{Date: record.Date, Minutes: total})
+}
+
+// filterRecords keeps the studies within dateRange
+func filterRecords(studies []StudyRecord, dateRange calendar.DateRange) []StudyRecord {
+	filtered := []StudyRecord{}
+	for _, study := range studies {
+		if dateRange.ContainsDate(study.Date) {
+			filtered = append(filtered, study)
+		}
+	}
+	return filtered
+}
+
+// movingAverage materializes every calendar day between the first and last
+// entry, gap days as zero minutes, and averages each day with the window-1
+// days before it. The first days average over the history available.
+func movingAverage(minutesPerDay []DayStatistic, window int) []AverageStatistic {
+	minutes := make(map[time.Time]int)
+	var first, last time.Time
+	for _, day := range minutesPerDay {
+		date, err := calendar.ParseDate(day.Date)
+		if err != nil {
+			continue
+		}
+		minutes[date] += day.Minutes
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
+
+	averages := []AverageStatistic{}
+	if first.IsZero() {
+		return averages
+	}
+
+	var daily []int
+	sum := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		daily = append(daily, minutes[day])
+		sum += minutes[day]
+		if len(daily) > window {
+			sum -= daily[len(daily)-window-1]
+		}
+
+		days := min(len(daily), window)
+		average := math.Round(float64(sum)/float64(days)*100) / 100
+		averages = append(averages, AverageStatistic{Date: day.Format(model.DateLayout), Minutes: average})
+	}
+	return averages
+}
 
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
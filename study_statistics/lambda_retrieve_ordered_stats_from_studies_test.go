@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/dynamotest"
+)
+
+func TestGenerateStatisticsDailySeries(t *testing.T) {
+	tests := []struct {
+		name           string
+		records        []StudyRecord
+		wantDays       []DayStatistic
+		wantCumulative []CumulativeStatistic
+	}{
+		{"no records", nil, []DayStatistic{}, []CumulativeStatistic{}},
+		{"one record a day", []StudyRecord{
+			{"01/03/2024", "go", 30},
+			{"02/03/2024", "go", 20},
+		}, []DayStatistic{
+			{"01/03/2024", 30, map[string]int{"go": 30}},
+			{"02/03/2024", 20, map[string]int{"go": 20}},
+		}, []CumulativeStatistic{
+			{"01/03/2024", 30},
+			{"02/03/2024", 50},
+		}},
+		// Only the first record of a day used to carry the previous total
+		{"several records a day", []StudyRecord{
+			{"01/03/2024", "go", 30},
+			{"01/03/2024", "sql", 10},
+			{"02/03/2024", "go", 20},
+			{"02/03/2024", "go", 5},
+			{"02/03/2024", "sql", 15},
+		}, []DayStatistic{
+			{"01/03/2024", 40, map[string]int{"go": 30, "sql": 10}},
+			{"02/03/2024", 40, map[string]int{"go": 25, "sql": 15}},
+		}, []CumulativeStatistic{
+			{"01/03/2024", 40},
+			{"02/03/2024", 80},
+		}},
+		{"out of order", []StudyRecord{
+			{"03/03/2024", "go", 10},
+			{"01/03/2024", "sql", 25},
+			{"10/02/2024", "go", 5},
+			{"01/03/2024", "go", 15},
+			{"03/03/2024", "sql", 20},
+		}, []DayStatistic{
+			{"10/02/2024", 5, map[string]int{"go": 5}},
+			{"01/03/2024", 40, map[string]int{"sql": 25, "go": 15}},
+			{"03/03/2024", 30, map[string]int{"go": 10, "sql": 20}},
+		}, []CumulativeStatistic{
+			{"10/02/2024", 5},
+			{"01/03/2024", 45},
+			{"03/03/2024", 75},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := generateStatistics(tt.records, false)
+			if !reflect.DeepEqual(stats.MinutesPerDay, tt.wantDays) {
+				t.Errorf("minutes per day = %+v, want %+v", stats.MinutesPerDay, tt.wantDays)
+			}
+			if !reflect.DeepEqual(stats.CumulativeMinutesPerDay, tt.wantCumulative) {
+				t.Errorf("cumulative minutes per day = %+v, want %+v", stats.CumulativeMinutesPerDay, tt.wantCumulative)
+			}
+			if n := len(tt.wantCumulative); n > 0 && stats.CumulativeMinutesPerDay[n-1].Minutes != stats.TotalMinutesStudied {
+				t.Errorf("cumulative ends at %d, want the total %d", stats.CumulativeMinutesPerDay[n-1].Minutes, stats.TotalMinutesStudied)
+			}
+		})
+	}
+}
+
+func TestGenerateStatisticsThemeSeries(t *testing.T) {
+	stats := generateStatistics([]StudyRecord{
+		{"02/03/2024", "go", 20},
+		{"01/03/2024", "go", 30},
+		{"02/03/2024", "sql", 15},
+		{"02/03/2024", "go", 5},
+	}, false)
+
+	wantPerDay := map[string]map[string]int{
+		"go":  {"01/03/2024": 30, "02/03/2024": 25},
+		"sql": {"02/03/2024": 15},
+	}
+	if !reflect.DeepEqual(stats.MinutesPerThemePerDay, wantPerDay) {
+		t.Errorf("minutes per theme per day = %v, want %v", stats.MinutesPerThemePerDay, wantPerDay)
+	}
+	wantCumulative := map[string][]CumulativeStatistic{
+		"go":  {{"01/03/2024", 30}, {"02/03/2024", 55}},
+		"sql": {{"02/03/2024", 15}},
+	}
+	if !reflect.DeepEqual(stats.CumulativeMinutesPerTheme, wantCumulative) {
+		t.Errorf("cumulative minutes per theme = %v, want %v", stats.CumulativeMinutesPerTheme, wantCumulative)
+	}
+}
+
+func TestHandlerOrdersScannedStudies(t *testing.T) {
+	study := func(theme, date, minutes string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: theme},
+			"study_date":       &types.AttributeValueMemberS{Value: date},
+			"minutes_of_study": &types.AttributeValueMemberN{Value: minutes},
+		}
+	}
+	fake := dynamotest.New(tableName,
+		study("Go", "02/03/2024", "20"),
+		study("SQL", "01/03/2024", "10"),
+		study("Go", "01/03/2024", "30"),
+		study("Go", "02/03/2024", "5"),
+	)
+	// One item a page, so the records arrive as scanned
+	fake.PageSize = 1
+	dynamoClient = fake
+	statsCache = cache.New(0)
+
+	response, err := Handler(context.Background(), events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var stats Statistics
+	if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+
+	wantDays := []DayStatistic{
+		{"01/03/2024", 40, map[string]int{"go": 30, "sql": 10}},
+		{"02/03/2024", 25, map[string]int{"go": 25}},
+	}
+	if !reflect.DeepEqual(stats.MinutesPerDay, wantDays) {
+		t.Errorf("minutes per day = %+v, want %+v", stats.MinutesPerDay, wantDays)
+	}
+	wantCumulative := []CumulativeStatistic{{"01/03/2024", 40}, {"02/03/2024", 65}}
+	if !reflect.DeepEqual(stats.CumulativeMinutesPerDay, wantCumulative) {
+		t.Errorf("cumulative minutes per day = %+v, want %+v", stats.CumulativeMinutesPerDay, wantCumulative)
+	}
+}
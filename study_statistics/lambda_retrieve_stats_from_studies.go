@@ -2,87 +2,115 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"time"
+
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"veet-code-go/internal/alias"
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/cache"
+	"veet-code-go/internal/calendar"
+	"veet-code-go/internal/goal"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/studystats"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
 )
 
-type Study struct {
-	StudyTheme    string `dynamodbav:"study_theme"`
-	StudyDate     string `dynamodbav:"study_date"`
-	MinutesOfStudy int    `dynamodbav:"minutes_of_study"`
-}
+// Statistics is the response, shared with the dashboard in lc_statistics
+type Statistics = studystats.Statistics
 
-type Statistics struct {
-	StudiesPerDay             map[string]int `json:"studiesPerDay"`
-	StudiesPerTheme           map[string]int `json:"studiesPerTheme"`
-	TotalMinutesStudied       int            `json:"totalMinutesStudied"`
-	TotalMinutesPerDay        map[string]int `json:"totalMinutesPerDay"`
-}
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
 
-var dynamoClient *dynamodb.Client
-const tableName = "studies_table"
+var goalsTableName = awsutil.TableName(goal.TableEnv, goal.DefaultTable)
+
+// statsCache survives between invocations of a warm container
+var statsCache = cache.FromEnv()
+
+// themeAliases come from THEME_ALIASES
+var themeAliases map[string]string
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	themeAliases, err = alias.Parse(os.Getenv(alias.EnvThemeAliases))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", alias.EnvThemeAliases, err)
+	}
+
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
 		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-	dynamoClient = dynamodb.NewFromConfig(cfg)
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	studies, err := fetchAllStudies(ctx)
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	cacheKey := cache.Key(ctx, event)
+	if cached, ok := statsCache.Get(cacheKey, event); ok {
+		return awsutil.Compress(event, awsutil.WithETag(event, cached)), nil
+	}
+
+	dateRange, err := calendar.DateRangeFromQuery(event.QueryStringParameters)
 	if err != nil {
-		log.Printf("Failed to fetch studies: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
 	}
 
-	stats := generateStatistics(studies)
-	statsJSON, err := json.MarshalIndent(stats, "", "  ")
+	studies, err := fetchAllStudies(ctx)
 	if err != nil {
-		log.Printf("Failed to marshal stats: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
+		slog.Error("Failed to fetch studies", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Headers: map[string]string{
-			"Content-Type":                   "application/json",
-			"Access-Control-Allow-Origin":    "*",
-			"Access-Control-Allow-Methods":   "GET, OPTIONS",
-			"Access-Control-Allow-Headers":   "Content-Type, Authorization",
-		},
-		Body: string(statsJSON),
-	}, nil
+	allStudies := studies
+	studies = studystats.Filter(studies, dateRange)
+
+	start := time.Now()
+	_, segment := tracing.Start(ctx, "generateStatistics")
+	stats := studystats.Generate(studies, themeAliases)
+	segment.End(nil)
+	metrics.Emit(ctx, metrics.Duration(metrics.StatsGenerationMs, time.Since(start)))
+	stats.GoalProgress = studystats.TrackGoal(ctx, dynamoClient, goalsTableName, allStudies)
+	response := statsCache.Put(cacheKey, awsutil.JSONResponse(200, stats))
+	return awsutil.Compress(event, awsutil.WithETag(event, response)), nil
 }
 
-func fetchAllStudies(ctx context.Context) ([]Study, error) {
-	var studies []Study
+func fetchAllStudies(ctx context.Context) (studies []studystats.Study, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllStudies")
+	defer func() { segment.End(err) }()
+
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(tenant.Table(ctx, tableName)),
 	}
+	user.ScopeScan(ctx, input)
 
+	start := time.Now()
 	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		page, err := awsutil.NextPage(ctx, paginator)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
 		}
 
-		var pageStudies []Study
+		var pageStudies []studystats.Study
 		err = attributevalue.UnmarshalListOfMaps(page.Items, &pageStudies)
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
@@ -91,30 +119,10 @@ func fetchAllStudies(ctx context.Context) ([]Study, error) {
 		studies = append(studies, pageStudies...)
 	}
 
+	metrics.Scan(ctx, start, len(studies))
 	return studies, nil
 }
 
-func generateStatistics(studies []Study) Statistics {
-	stats := Statistics{
-		StudiesPerDay:       make(map[string]int),
-		StudiesPerTheme:     make(map[string]int),
-		TotalMinutesStudied: 0,
-		TotalMinutesPerDay:  make(map[string]int),
-	}
-
-	for _, study := range studies {
-		stats.StudiesPerDay[study.StudyDate]++
-		stats.StudiesPerTheme[study.StudyTheme]++
-
-		stats.TotalMinutesStudied += study.MinutesOfStudy
-
-		stats.TotalMinutesPerDay[study.StudyDate] += study.MinutesOfStudy
-	}
-
-	return stats
-}
-
 func main() {
-	lambda.Start(Handler)
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
 }
-
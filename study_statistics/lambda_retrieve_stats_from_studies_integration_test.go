@@ -0,0 +1,65 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+// Run against DynamoDB Local with
+//
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test -tags integration \
+//		lambda_retrieve_stats_from_studies.go lambda_retrieve_stats_from_studies_integration_test.go
+func TestIntegrationStudyStatistics(t *testing.T) {
+	client := dynamotest.Local(t, dynamotest.StudiesSchema)
+	dynamoClient = client
+	tableName = dynamotest.StudiesSchema.Table
+	ctx := context.Background()
+
+	study := func(theme, id, date string, minutes types.AttributeValue) types.WriteRequest {
+		return types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: theme},
+			"study_id":         &types.AttributeValueMemberS{Value: id},
+			"study_date":       &types.AttributeValueMemberS{Value: date},
+			"minutes_of_study": minutes,
+		}}}
+	}
+	_, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{tableName: {
+		study("Go", "a", "01/03/2024", &types.AttributeValueMemberN{Value: "30"}),
+		study("Go", "b", "02/03/2024", &types.AttributeValueMemberN{Value: "45"}),
+		// Written before minutes were stored as a Number
+		study("SQL", "c", "02/03/2024", &types.AttributeValueMemberS{Value: "60"}),
+	}}})
+	if err != nil {
+		t.Fatalf("BatchWriteItem: %v", err)
+	}
+
+	response, err := Handler(ctx, events.APIGatewayProxyRequest{})
+	if err != nil || response.StatusCode != 200 {
+		t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+	}
+	var stats Statistics
+	if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+
+	if stats.TotalMinutesStudied != 135 {
+		t.Errorf("total minutes = %d, want 135", stats.TotalMinutesStudied)
+	}
+	// Themes are folded to their canonical spelling
+	if want := map[string]int{"go": 2, "sql": 1}; !reflect.DeepEqual(stats.StudiesPerTheme, want) {
+		t.Errorf("per theme = %v, want %v", stats.StudiesPerTheme, want)
+	}
+	if stats.MostStudiedTheme != "go" || stats.BusiestDay != "02/03/2024" {
+		t.Errorf("most studied %q, busiest day %q, want go and 02/03/2024", stats.MostStudiedTheme, stats.BusiestDay)
+	}
+}
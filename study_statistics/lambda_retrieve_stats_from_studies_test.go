@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+func TestHandlerFiltersByDateRange(t *testing.T) {
+	dynamoClient = dynamotest.New(tableName,
+		map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: "Go"},
+			"study_date":       &types.AttributeValueMemberS{Value: "01/03/2024"},
+			"minutes_of_study": &types.AttributeValueMemberN{Value: "30"},
+		},
+		map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: "SQL"},
+			"study_date":       &types.AttributeValueMemberS{Value: "05/03/2024"},
+			"minutes_of_study": &types.AttributeValueMemberS{Value: "45"},
+		},
+	)
+
+	tests := []struct {
+		name      string
+		params    map[string]string
+		wantTotal int
+	}{
+		{"whole history", nil, 75},
+		{"from", map[string]string{"from": "02/03/2024"}, 45},
+		{"ISO to", map[string]string{"to": "2024-03-04"}, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: tt.params})
+			if err != nil || response.StatusCode != 200 {
+				t.Fatalf("Handler = %d %s, %v", response.StatusCode, response.Body, err)
+			}
+			var stats Statistics
+			if err := json.Unmarshal([]byte(response.Body), &stats); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if stats.TotalMinutesStudied != tt.wantTotal {
+				t.Errorf("total minutes = %d, want %d", stats.TotalMinutesStudied, tt.wantTotal)
+			}
+		})
+	}
+
+	response, _ := Handler(context.Background(), events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"from": "05/03/2024", "to": "01/03/2024"}})
+	if response.StatusCode != 400 {
+		t.Errorf("inverted range = %d, want 400", response.StatusCode)
+	}
+}
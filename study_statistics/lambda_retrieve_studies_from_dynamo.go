@@ -2,77 +2,161 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/metrics"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/tracing"
+	"veet-code-go/internal/user"
 )
 
 type Study struct {
-	StudyTheme   string `dynamodbav:"study_theme"`
-	StudyDate    string `dynamodbav:"study_date"`
-	StudyMinutes string `dynamodbav:"minutes_of_study"`
+	// StudyID of studies written before ids is filled in with LegacyStudyID
+	StudyID      string            `json:"id" dynamodbav:"study_id"`
+	StudyTheme   string            `json:"theme" dynamodbav:"study_theme"`
+	StudyDate    string            `json:"date" dynamodbav:"study_date"`
+	StudyMinutes studytime.Minutes `json:"minutes" dynamodbav:"minutes_of_study"`
 }
 
-var dynamoClient *dynamodb.Client
-const tableName = "studies_table"
+// Orders of the ?sort parameter; SortDateAsc is the default
+const (
+	SortDateAsc     = "date_asc"
+	SortDateDesc    = "date_desc"
+	SortMinutesDesc = "minutes_desc"
+)
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
 
 func init() {
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("sa-east-1"))
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
 	if err != nil {
-		panic(fmt.Sprintf("Unable to load AWS SDK config: %v", err))
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
 	}
-
-	dynamoClient = dynamodb.NewFromConfig(cfg)
 }
 
 func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Printf("Raw Event: %+v", event)
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
 
-	studies, err := fetchAllStudies(ctx)
+	slog.Debug("Raw event", "event", event)
+
+	csvFormat := event.QueryStringParameters["format"] == "csv"
+
+	order := event.QueryStringParameters["sort"]
+	switch order {
+	case "":
+		order = SortDateAsc
+	case SortDateAsc, SortDateDesc, SortMinutesDesc:
+	default:
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("invalid sort %q: use %s, %s or %s", order, SortDateAsc, SortDateDesc, SortMinutesDesc)), nil
+	}
+
+	limit, err := awsutil.ParseLimit(event.QueryStringParameters["limit"])
 	if err != nil {
-		log.Printf("Failed to fetch studies: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+	}
+	if limit > 0 {
+		startKey, err := awsutil.DecodePageToken(event.QueryStringParameters["nextToken"])
+		if err != nil {
+			return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, err.Error()), nil
+		}
+
+		page, err := fetchStudiesPage(ctx, limit, startKey)
+		if err != nil {
+			slog.Error("Failed to fetch studies", "error", err)
+			return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+		}
+		sortStudies(page.Items, order)
+		if csvFormat {
+			response := studiesCSV(page.Items)
+			if page.NextToken != "" {
+				response.Headers[awsutil.NextTokenHeader] = page.NextToken
+			}
+			return response, nil
+		}
+		return awsutil.JSONResponse(200, page), nil
 	}
 
-	responseBody, err := json.Marshal(studies)
+	studies, err := fetchAllStudies(ctx)
 	if err != nil {
-		log.Printf("Failed to marshal response: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       "Internal Server Error",
-		}, nil
+		slog.Error("Failed to fetch studies", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to read from the database"), nil
+	}
+
+	sortStudies(studies, order)
+	if csvFormat {
+		return studiesCSV(studies), nil
+	}
+
+	return awsutil.JSONResponse(200, studies), nil
+}
+
+func studiesCSV(studies []Study) events.APIGatewayProxyResponse {
+	rows := make([][]string, 0, len(studies))
+	for _, study := range studies {
+		rows = append(rows, []string{study.StudyTheme, study.StudyDate, strconv.Itoa(int(study.StudyMinutes)), study.StudyID})
 	}
+	return awsutil.CSVResponse(200, "studies.csv", []string{"theme", "date", "minutes", "id"}, rows)
+}
+
+// sortStudies orders studies in place. Dates use the 02/01/2006 layout, and
+// studies whose date doesn't parse go last. Ties keep scan order.
+// A paged response only sorts the studies of its page.
+func sortStudies(studies []Study, order string) {
+	sort.SliceStable(studies, func(i, j int) bool {
+		if order == SortMinutesDesc {
+			return studies[i].StudyMinutes > studies[j].StudyMinutes
+		}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Headers: map[string]string{
-			"Content-Type":                 "application/json",
-			"Access-Control-Allow-Origin":  "*",
-			"Access-Control-Allow-Methods": "GET, OPTIONS",
-		},
-		Body: string(responseBody),
-	}, nil
+		dateI, errI := time.Parse(model.DateLayout, studies[i].StudyDate)
+		dateJ, errJ := time.Parse(model.DateLayout, studies[j].StudyDate)
+		if errI != nil || errJ != nil {
+			return errI == nil && errJ != nil
+		}
+		if order == SortDateDesc {
+			return dateI.After(dateJ)
+		}
+		return dateI.Before(dateJ)
+	})
 }
 
-func fetchAllStudies(ctx context.Context) ([]Study, error) {
-	var studies []Study
+func fetchAllStudies(ctx context.Context) (studies []Study, err error) {
+	ctx, segment := tracing.Start(ctx, "fetchAllStudies")
+	defer func() { segment.End(err) }()
+
 	input := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
+		TableName: aws.String(tenant.Table(ctx, tableName)),
 	}
+	user.ScopeScan(ctx, input)
 
+	start := time.Now()
 	paginator := dynamodb.NewScanPaginator(dynamoClient, input)
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		page, err := awsutil.NextPage(ctx, paginator)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan DynamoDB: %w", err)
 		}
@@ -83,13 +167,51 @@ func fetchAllStudies(ctx context.Context) ([]Study, error) {
 			return nil, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
 		}
 
+		setLegacyIDs(pageStudies)
 		studies = append(studies, pageStudies...)
 	}
 
+	metrics.Scan(ctx, start, len(studies))
 	return studies, nil
 }
 
-func main() {
-	lambda.Start(Handler)
+// fetchStudiesPage runs a single scan of at most limit items
+func fetchStudiesPage(ctx context.Context, limit int32, startKey map[string]types.AttributeValue) (awsutil.Page[Study], error) {
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(tenant.Table(ctx, tableName)),
+		Limit:             aws.Int32(limit),
+		ExclusiveStartKey: startKey,
+	}
+	user.ScopeScan(ctx, input)
+
+	output, err := dynamoClient.Scan(ctx, input)
+	if err != nil {
+		return awsutil.Page[Study]{}, fmt.Errorf("failed to scan DynamoDB: %w", err)
+	}
+
+	studies := []Study{}
+	err = attributevalue.UnmarshalListOfMaps(output.Items, &studies)
+	if err != nil {
+		return awsutil.Page[Study]{}, fmt.Errorf("failed to unmarshal DynamoDB items: %w", err)
+	}
+	setLegacyIDs(studies)
+
+	nextToken, err := awsutil.EncodePageToken(output.LastEvaluatedKey)
+	if err != nil {
+		return awsutil.Page[Study]{}, err
+	}
+	return awsutil.Page[Study]{Items: studies, NextToken: nextToken}, nil
 }
 
+// setLegacyIDs gives studies written before ids the id they are migrated with
+func setLegacyIDs(studies []Study) {
+	for i := range studies {
+		if studies[i].StudyID == "" {
+			studies[i].StudyID = model.LegacyStudyID(studies[i].StudyDate)
+		}
+	}
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+)
+
+func studyItem(theme, id, date string, minutes types.AttributeValue) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"study_theme":      &types.AttributeValueMemberS{Value: theme},
+		"study_date":       &types.AttributeValueMemberS{Value: date},
+		"minutes_of_study": minutes,
+	}
+	if id != "" {
+		item["study_id"] = &types.AttributeValueMemberS{Value: id}
+	}
+	return item
+}
+
+func TestFetchAllStudiesPaginates(t *testing.T) {
+	items := []map[string]types.AttributeValue{
+		studyItem("Go", "a", "01/01/2024", &types.AttributeValueMemberN{Value: "30"}),
+		studyItem("Go", "b", "02/01/2024", &types.AttributeValueMemberN{Value: "45"}),
+		studyItem("SQL", "", "03/01/2024", &types.AttributeValueMemberN{Value: "60"}),
+	}
+	want := []Study{
+		{StudyID: "a", StudyTheme: "Go", StudyDate: "01/01/2024", StudyMinutes: 30},
+		{StudyID: "b", StudyTheme: "Go", StudyDate: "02/01/2024", StudyMinutes: 45},
+		// Studies written before ids get their date as id
+		{StudyID: "03/01/2024", StudyTheme: "SQL", StudyDate: "03/01/2024", StudyMinutes: 60},
+	}
+
+	tests := []struct {
+		name      string
+		pageSize  int
+		wantScans int
+	}{
+		{"single page", 0, 1},
+		{"page per item", 1, 3},
+		{"last page partial", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName, items...)
+			fake.PageSize = tt.pageSize
+			dynamoClient = fake
+
+			studies, err := fetchAllStudies(context.Background())
+			if err != nil {
+				t.Fatalf("fetchAllStudies: %v", err)
+			}
+			if got := fake.Count("Scan"); got != tt.wantScans {
+				t.Errorf("Scan called %d times, want %d", got, tt.wantScans)
+			}
+			if !reflect.DeepEqual(studies, want) {
+				t.Errorf("studies = %+v, want %+v", studies, want)
+			}
+		})
+	}
+}
+
+func TestFetchAllStudiesMalformedItems(t *testing.T) {
+	valid := studyItem("Go", "a", "01/01/2024", &types.AttributeValueMemberN{Value: "30"})
+
+	tests := []struct {
+		name    string
+		item    map[string]types.AttributeValue
+		wantErr bool
+	}{
+		{"number that isn't whole", studyItem("Go", "b", "02/01/2024", &types.AttributeValueMemberN{Value: "12.5"}), true},
+		{"minutes of the wrong type", studyItem("Go", "b", "02/01/2024", &types.AttributeValueMemberBOOL{Value: true}), true},
+		{"date of the wrong type", map[string]types.AttributeValue{
+			"study_theme":      &types.AttributeValueMemberS{Value: "Go"},
+			"study_date":       &types.AttributeValueMemberM{},
+			"minutes_of_study": &types.AttributeValueMemberN{Value: "10"},
+		}, true},
+		{"legacy string that isn't a number", studyItem("Go", "b", "02/01/2024", &types.AttributeValueMemberS{Value: "lots"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName, valid, tt.item)
+			fake.PageSize = 1
+			dynamoClient = fake
+
+			studies, err := fetchAllStudies(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("fetchAllStudies = %+v, want an error", studies)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetchAllStudies: %v", err)
+			}
+			if len(studies) != 2 || studies[1].StudyMinutes != 0 {
+				t.Errorf("studies = %+v, want the second one with 0 minutes", studies)
+			}
+		})
+	}
+}
+
+func TestFetchAllStudiesMixedMinutesEncodings(t *testing.T) {
+	dynamoClient = dynamotest.New(tableName,
+		studyItem("Go", "a", "01/01/2024", &types.AttributeValueMemberN{Value: "30"}),
+		studyItem("Go", "", "02/01/2024", &types.AttributeValueMemberS{Value: "45"}),
+		studyItem("SQL", "c", "03/01/2024", &types.AttributeValueMemberS{Value: " 15 "}),
+		studyItem("SQL", "d", "04/01/2024", &types.AttributeValueMemberNULL{Value: true}),
+	)
+
+	studies, err := fetchAllStudies(context.Background())
+	if err != nil {
+		t.Fatalf("fetchAllStudies: %v", err)
+	}
+	var minutes []int
+	for _, study := range studies {
+		minutes = append(minutes, int(study.StudyMinutes))
+	}
+	if want := []int{30, 45, 15, 0}; !reflect.DeepEqual(minutes, want) {
+		t.Errorf("minutes = %v, want %v", minutes, want)
+	}
+}
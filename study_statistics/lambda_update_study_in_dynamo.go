@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/awsutil"
+	"veet-code-go/internal/logging"
+	"veet-code-go/internal/model"
+	"veet-code-go/internal/studytime"
+	"veet-code-go/internal/tenant"
+	"veet-code-go/internal/user"
+	"veet-code-go/internal/validation"
+)
+
+// Modes of an update: ModeSet replaces the stored minutes, ModeAdd adds to them
+const (
+	ModeSet = "set"
+	ModeAdd = "add"
+)
+
+// Request names the study by theme and id. Studies from before ids can be
+// named by their date instead, which is what their id is.
+type Request struct {
+	StudyTheme   string                 `json:"theme"`
+	StudyID      string                 `json:"id"`
+	StudyDate    string                 `json:"date"`
+	StudyMinutes studytime.MinutesInput `json:"minutes"`
+	// Mode defaults to ModeSet
+	Mode string `json:"mode"`
+}
+
+var dynamoClient awsutil.DynamoAPI
+
+const defaultTableName = "studies_table"
+
+var tableName = awsutil.TableName(awsutil.StudiesTableEnv, defaultTableName)
+
+func init() {
+	var err error
+	dynamoClient, err = awsutil.NewDynamoClient(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load AWS SDK config: %v", err)
+	}
+}
+
+// Handler changes the minutes of the study keyed by theme and id, answering
+// with the stored value after the update. Studies that don't exist are not
+// created.
+func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, err := tenant.FromRequest(ctx, event)
+	if err != nil {
+		slog.Warn("Failed to resolve tenant", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, "unknown tenant"), nil
+	}
+
+	slog.Debug("Raw event", "event", event)
+
+	if verr := validation.CheckBody(event.Body); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+
+	var request Request
+	err = json.Unmarshal([]byte(event.Body), &request)
+	if err != nil {
+		slog.Warn("Failed to unmarshal request body", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidBody, "invalid request body"), nil
+	}
+	if request.Mode == "" {
+		request.Mode = ModeSet
+	}
+	if request.StudyID == "" && request.StudyDate != "" {
+		request.StudyID = model.LegacyStudyID(request.StudyDate)
+	}
+
+	var fields validation.Fields
+	request.validate(&fields)
+	if verr := fields.Err(); verr != nil {
+		return awsutil.JSONResponse(400, verr.Envelope()), nil
+	}
+	minutes, _ := studytime.ParseMinutes(string(request.StudyMinutes))
+
+	updated, err := updateItemInDynamoDB(ctx, request, minutes)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) && request.Mode == ModeAdd && user.Owns(ctx, conditionFailed.Item) {
+		if legacy, ok := conditionFailed.Item["minutes_of_study"].(*types.AttributeValueMemberS); ok {
+			updated, err = addToLegacyMinutes(ctx, request, legacy, minutes)
+		}
+	}
+	if errors.As(err, &conditionFailed) && (len(conditionFailed.Item) == 0 || !user.Owns(ctx, conditionFailed.Item)) {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeNotFound, fmt.Sprintf("no study of %q with id %s", request.StudyTheme, request.StudyID)), nil
+	}
+	if errors.As(err, &conditionFailed) || errors.Is(err, errDayFull) {
+		return awsutil.ErrorResponse(ctx, awsutil.CodeInvalidParameter, fmt.Sprintf("adding %d minutes would exceed %d for the day", minutes, studytime.MaxMinutes)), nil
+	}
+	if err != nil {
+		slog.Error("Failed to update item in DynamoDB", "error", err)
+		return awsutil.ErrorResponse(ctx, awsutil.CodeDatabaseError, "failed to update the study"), nil
+	}
+
+	slog.Info("Study updated", "theme", request.StudyTheme, "id", request.StudyID, "mode", request.Mode, "minutes", updated)
+	return awsutil.JSONResponse(200, map[string]any{
+		"message": fmt.Sprintf("Study successfully updated in DynamoDB. Study Theme: %s, Study ID: %s, Minutes of Study: %d", request.StudyTheme, request.StudyID, updated),
+		"id":      request.StudyID,
+		"minutes": updated,
+	}), nil
+}
+
+func (r Request) validate(fields *validation.Fields) {
+	fields.Require("theme", r.StudyTheme)
+	fields.Require("id", r.StudyID)
+	fields.Require("minutes", string(r.StudyMinutes))
+	if r.StudyMinutes != "" {
+		if _, err := studytime.ParseMinutes(string(r.StudyMinutes)); err != nil {
+			fields.Add("minutes", err.Error())
+		}
+	}
+	if r.Mode != ModeSet && r.Mode != ModeAdd {
+		fields.Add("mode", fmt.Sprintf("must be %q or %q", ModeSet, ModeAdd))
+	}
+}
+
+// updateItemInDynamoDB returns the minutes stored after the update. It fails
+// with ConditionalCheckFailedException, carrying the stored item when there
+// is one, if the study doesn't exist, belongs to another user or adding would
+// go past MaxMinutes.
+func updateItemInDynamoDB(ctx context.Context, request Request, minutes int) (int, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+		Key: map[string]types.AttributeValue{
+			"study_theme":     &types.AttributeValueMemberS{Value: request.StudyTheme},
+			model.IDAttribute: &types.AttributeValueMemberS{Value: request.StudyID},
+		},
+		UpdateExpression:    aws.String("SET minutes_of_study = :minutes"),
+		ConditionExpression: aws.String("attribute_exists(study_theme)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minutes": &types.AttributeValueMemberN{Value: strconv.Itoa(minutes)},
+		},
+		ReturnValues:                        types.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if request.Mode == ModeAdd {
+		// A String from before minutes were stored as a Number never compares
+		// with :limit, so the update fails and addToLegacyMinutes takes over
+		input.UpdateExpression = aws.String("ADD minutes_of_study :minutes")
+		input.ConditionExpression = aws.String("attribute_exists(study_theme) AND (attribute_not_exists(minutes_of_study) OR minutes_of_study <= :limit)")
+		input.ExpressionAttributeValues[":limit"] = &types.AttributeValueMemberN{Value: strconv.Itoa(studytime.MaxMinutes - minutes)}
+	}
+	user.ScopeUpdate(ctx, input)
+
+	output, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+
+	return storedMinutes(output)
+}
+
+// errDayFull is returned when adding would take a study past MaxMinutes
+var errDayFull = errors.New("minutes would exceed the day")
+
+// addToLegacyMinutes adds minutes to a study whose minutes_of_study is still
+// the String of a legacy item, storing the sum as a Number. The update only
+// applies while the String is unchanged, and fails with
+// ConditionalCheckFailedException otherwise.
+func addToLegacyMinutes(ctx context.Context, request Request, stored *types.AttributeValueMemberS, minutes int) (int, error) {
+	var current studytime.Minutes
+	if err := current.UnmarshalDynamoDBAttributeValue(stored); err != nil {
+		return 0, err
+	}
+	total := int(current) + minutes
+	if total > studytime.MaxMinutes {
+		return 0, errDayFull
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tenant.Table(ctx, tableName)),
+		Key: map[string]types.AttributeValue{
+			"study_theme":     &types.AttributeValueMemberS{Value: request.StudyTheme},
+			model.IDAttribute: &types.AttributeValueMemberS{Value: request.StudyID},
+		},
+		UpdateExpression:    aws.String("SET minutes_of_study = :total"),
+		ConditionExpression: aws.String("minutes_of_study = :stored"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":total":  &types.AttributeValueMemberN{Value: strconv.Itoa(total)},
+			":stored": stored,
+		},
+		ReturnValues:                        types.ReturnValueUpdatedNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	user.ScopeUpdate(ctx, input)
+
+	output, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	}
+	return storedMinutes(output)
+}
+
+func storedMinutes(output *dynamodb.UpdateItemOutput) (int, error) {
+	stored, ok := output.Attributes["minutes_of_study"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("update returned no minutes_of_study")
+	}
+	return strconv.Atoi(stored.Value)
+}
+
+func main() {
+	lambda.Start(logging.WithRequestIDs(awsutil.WithCORS(awsutil.WithDeadline(user.Require(Handler)))))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"veet-code-go/internal/dynamotest"
+	"veet-code-go/internal/model"
+)
+
+func studyItem(minutes types.AttributeValue) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"study_theme":     &types.AttributeValueMemberS{Value: "Graphs"},
+		model.IDAttribute: &types.AttributeValueMemberS{Value: "s-1"},
+	}
+	if minutes != nil {
+		item["minutes_of_study"] = minutes
+	}
+	return item
+}
+
+func TestHandlerUpdatesMinutes(t *testing.T) {
+	n := func(value string) types.AttributeValue { return &types.AttributeValueMemberN{Value: value} }
+	s := func(value string) types.AttributeValue { return &types.AttributeValueMemberS{Value: value} }
+
+	tests := []struct {
+		name        string
+		stored      types.AttributeValue
+		body        string
+		wantStatus  int
+		wantMinutes types.AttributeValue
+	}{
+		{"set", n("30"), `{"theme":"Graphs","id":"s-1","minutes":45}`, 200, n("45")},
+		{"add", n("30"), `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 200, n("75")},
+		{"add past the day", n("1400"), `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 400, n("1400")},
+		{"add without stored minutes", nil, `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 200, n("45")},
+		// Legacy items kept the minutes as a String, which is converted
+		{"add to legacy minutes", s("30"), `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 200, n("75")},
+		{"add to unreadable legacy minutes", s("half an hour"), `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 200, n("45")},
+		{"add past the day to legacy minutes", s("1400"), `{"theme":"Graphs","id":"s-1","minutes":45,"mode":"add"}`, 400, s("1400")},
+		{"add to a missing study", nil, `{"theme":"Graphs","id":"s-2","minutes":45,"mode":"add"}`, 404, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := dynamotest.New(tableName, studyItem(tt.stored))
+			fake.Keys = map[string][]string{tableName: {"study_theme", model.IDAttribute}}
+			dynamoClient = fake
+
+			response, err := Handler(context.Background(), events.APIGatewayProxyRequest{Body: tt.body})
+			if err != nil || response.StatusCode != tt.wantStatus {
+				t.Fatalf("Handler = %d %s, %v, want %d", response.StatusCode, response.Body, err, tt.wantStatus)
+			}
+			if got := fake.Tables[tableName][0]["minutes_of_study"]; !reflect.DeepEqual(got, tt.wantMinutes) {
+				t.Errorf("stored minutes = %v, want %v", got, tt.wantMinutes)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var body struct {
+				Minutes int `json:"minutes"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("unmarshal body: %v", err)
+			}
+			if want := tt.wantMinutes.(*types.AttributeValueMemberN).Value; strconv.Itoa(body.Minutes) != want {
+				t.Errorf("minutes = %d, want %s", body.Minutes, want)
+			}
+		})
+	}
+}